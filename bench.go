@@ -0,0 +1,98 @@
+package main
+
+import (
+	"Compression_Upc/bench"
+	"Compression_Upc/huffman"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runBench measures every codec over the synthetic corpus and writes the report.
+func runBench(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("bench", "", s)
+	size := fs.Int("size", bench.DefaultSampleSize, "tamaño de cada muestra del corpus en bytes")
+	seed := fs.Int64("seed", 1, "semilla del corpus; la misma semilla genera los mismos datos")
+	samples := fs.String("samples", "", "muestras a medir separadas por comas: "+strings.Join(bench.SampleNames(), ", "))
+	codecs := fs.String("codecs", "", "codecs a medir separados por comas (por defecto todos): "+methodNames())
+	blockSize := fs.Int("b", huffman.DefaultBlockSize, "tamaño de bloque en bytes")
+	minTime := fs.Duration("time", bench.DefaultMinTime, "tiempo mínimo de cada medición")
+	format := fs.String("format", "markdown", "formato del reporte: "+strings.Join(bench.Formats, ", "))
+	output := fs.String("o", "", "escribe el reporte en `archivo` en lugar de la salida estándar")
+	verbose := fs.Bool("v", false, "muestra cada medición al terminarla")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(s, "bench", "no recibe archivos, mide un corpus generado")
+	}
+	if !slices.Contains(bench.Formats, *format) {
+		return usageError(s, "bench", "formato desconocido %q (use %s)", *format, strings.Join(bench.Formats, ", "))
+	}
+	if *size <= 0 {
+		return usageError(s, "bench", "el tamaño de las muestras debe ser positivo")
+	}
+	if *blockSize <= 0 || *blockSize > huffman.MaxBlockSize {
+		return usageError(s, "bench", "el tamaño de bloque debe estar entre 1 y %d", huffman.MaxBlockSize)
+	}
+	corpus, err := bench.Select(bench.Corpus(*size, *seed), splitList(*samples))
+	if err != nil {
+		return usageError(s, "bench", "%v", err)
+	}
+	cfg := bench.Config{Codecs: splitList(*codecs), BlockSize: *blockSize, MinTime: *minTime}
+	for _, name := range cfg.Codecs {
+		if _, ok := huffman.CodecByName(name); !ok && name != huffman.CodecAuto {
+			return usageError(s, "bench", "método desconocido %q (use %s)", name, methodNames())
+		}
+	}
+	if *verbose {
+		cfg.Progress = func(r bench.Result) {
+			fmt.Fprintf(s.stderr, "%-12s %-12s %.3f  %.1f MB/s  %.1f MB/s\n", r.Sample, r.Codec, r.Ratio, r.CompressMBps, r.DecompressMBps)
+		}
+	}
+
+	start := time.Now()
+	report, err := bench.Run(ctx, corpus, cfg)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(s.stderr, "%s: interrumpido\n", progName)
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintf(s.stderr, "%s bench: %v\n", progName, err)
+		return exitError
+	}
+	if *verbose {
+		fmt.Fprintf(s.stderr, "%d mediciones en %s\n", len(report.Results), time.Since(start).Round(time.Millisecond))
+	}
+
+	out := s.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %v\n", progName, err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := report.Write(out, *format); err != nil {
+		fmt.Fprintf(s.stderr, "%s: %v\n", progName, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// splitList splits a comma-separated flag, ignoring empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
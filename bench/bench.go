@@ -0,0 +1,245 @@
+package bench
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// DefaultMinTime is how long each measurement repeats when Config.MinTime is 0.
+const DefaultMinTime = 200 * time.Millisecond
+
+// Config selects what Run measures.
+type Config struct {
+	// Codecs are the codec names to measure, CodecAuto included. Empty means
+	// every registered codec followed by auto.
+	Codecs []string
+	// BlockSize is passed to the container writer. 0 uses huffman.DefaultBlockSize.
+	BlockSize int
+	// MinTime is how long compression and decompression of each sample are
+	// repeated to get a stable throughput.
+	MinTime time.Duration
+	// Progress, if not nil, receives each result as soon as it is measured.
+	Progress func(Result)
+}
+
+// Result is the measurement of one codec over one sample. Totals use
+// TotalSample as the sample name.
+type Result struct {
+	Sample         string  `json:"sample"`
+	Codec          string  `json:"codec"`
+	OriginalSize   int64   `json:"originalSize"`
+	CompressedSize int64   `json:"compressedSize"`
+	Ratio          float64 `json:"ratio"` // compressed size over original size, as in the API
+	CompressMBps   float64 `json:"compressMBps"`
+	DecompressMBps float64 `json:"decompressMBps"`
+	// CompressAlloc and DecompressAlloc are the bytes allocated by one
+	// compression or decompression of the sample.
+	CompressAlloc   uint64 `json:"compressAllocBytes"`
+	DecompressAlloc uint64 `json:"decompressAllocBytes"`
+
+	compressTime, decompressTime time.Duration
+}
+
+// TotalSample is the sample name of the per-codec totals.
+const TotalSample = "total"
+
+// Report holds every result of a run and the totals of each codec over the
+// whole corpus.
+type Report struct {
+	SampleSize int      `json:"sampleSize"`
+	BlockSize  int      `json:"blockSize"`
+	Results    []Result `json:"results"`
+	Totals     []Result `json:"totals"`
+}
+
+// CodecNames lists every registered codec followed by auto.
+func CodecNames() []string {
+	var names []string
+	for _, c := range huffman.Codecs() {
+		names = append(names, c.Name())
+	}
+	return append(names, huffman.CodecAuto)
+}
+
+// Run compresses and decompresses every sample with every codec, checking
+// that the output matches the input. It stops with ctx.Err() when ctx is canceled.
+func Run(ctx context.Context, samples []Sample, cfg Config) (*Report, error) {
+	codecs := cfg.Codecs
+	if len(codecs) == 0 {
+		codecs = CodecNames()
+	}
+	for _, name := range codecs {
+		if _, ok := huffman.CodecByName(name); !ok && name != huffman.CodecAuto {
+			return nil, fmt.Errorf("bench: unknown codec %q", name)
+		}
+	}
+	if cfg.BlockSize == 0 {
+		cfg.BlockSize = huffman.DefaultBlockSize
+	}
+	if cfg.MinTime <= 0 {
+		cfg.MinTime = DefaultMinTime
+	}
+
+	report := &Report{BlockSize: cfg.BlockSize, Results: []Result{}, Totals: []Result{}}
+	if len(samples) > 0 {
+		report.SampleSize = len(samples[0].Data)
+	}
+	for _, sample := range samples {
+		for _, codec := range codecs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result, err := measureCodec(sample, huffman.Options{Codec: codec, BlockSize: cfg.BlockSize}, cfg.MinTime)
+			if err != nil {
+				return nil, fmt.Errorf("bench: %s on %s: %w", codec, sample.Name, err)
+			}
+			report.Results = append(report.Results, result)
+			if cfg.Progress != nil {
+				cfg.Progress(result)
+			}
+		}
+	}
+	report.Totals = totals(codecs, report.Results)
+	return report, nil
+}
+
+// Compress compresses data in a container with opts.
+func Compress(data []byte, opts huffman.Options) ([]byte, error) {
+	return compress(context.Background(), data, opts)
+}
+
+// Decompress reads a whole container.
+func Decompress(compressed []byte) ([]byte, error) {
+	return decompress(context.Background(), compressed)
+}
+
+func compress(ctx context.Context, data []byte, opts huffman.Options) ([]byte, error) {
+	var out bytes.Buffer
+	zw, err := huffman.NewWriterContext(ctx, &out, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func decompress(ctx context.Context, compressed []byte) ([]byte, error) {
+	zr, err := huffman.NewReaderContext(ctx, bytes.NewReader(compressed), huffman.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func measureCodec(sample Sample, opts huffman.Options, minTime time.Duration) (Result, error) {
+	result := Result{Sample: sample.Name, Codec: opts.Codec, OriginalSize: int64(len(sample.Data))}
+
+	var compressed []byte
+	perOp, alloc, err := measure(minTime, func() (err error) {
+		compressed, err = Compress(sample.Data, opts)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	result.compressTime, result.CompressAlloc = perOp, alloc
+	result.CompressedSize = int64(len(compressed))
+
+	var decompressed []byte
+	perOp, alloc, err = measure(minTime, func() (err error) {
+		decompressed, err = Decompress(compressed)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	if !bytes.Equal(decompressed, sample.Data) {
+		return result, fmt.Errorf("decompressed data does not match the sample")
+	}
+	result.decompressTime, result.DecompressAlloc = perOp, alloc
+	result.finish()
+	return result, nil
+}
+
+// measure runs fn once to warm up and then in rounds of growing size until
+// the rounds add up to minTime. It returns the time and the bytes allocated
+// per call.
+func measure(minTime time.Duration, fn func() error) (time.Duration, uint64, error) {
+	if err := fn(); err != nil {
+		return 0, 0, err
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	calls := 0
+	for round := 1; ; round *= 2 {
+		for i := 0; i < round; i++ {
+			if err := fn(); err != nil {
+				return 0, 0, err
+			}
+		}
+		calls += round
+		if time.Since(start) >= minTime {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return elapsed / time.Duration(calls), (after.TotalAlloc - before.TotalAlloc) / uint64(calls), nil
+}
+
+func (r *Result) finish() {
+	if r.OriginalSize > 0 {
+		r.Ratio = float64(r.CompressedSize) / float64(r.OriginalSize)
+	}
+	r.CompressMBps = mbps(r.OriginalSize, r.compressTime)
+	r.DecompressMBps = mbps(r.OriginalSize, r.decompressTime)
+}
+
+// mbps is the throughput in millions of original bytes per second, the unit
+// of testing.B's MB/s.
+func mbps(size int64, perOp time.Duration) float64 {
+	if perOp <= 0 {
+		return 0
+	}
+	return float64(size) / 1e6 / perOp.Seconds()
+}
+
+// totals adds the results of each codec over all samples. Throughput is the
+// total size over the total time, so larger samples weigh more, and the
+// allocations are the largest of any sample.
+func totals(codecs []string, results []Result) []Result {
+	list := make([]Result, 0, len(codecs))
+	for _, codec := range codecs {
+		total := Result{Sample: TotalSample, Codec: codec}
+		samples := 0
+		for _, r := range results {
+			if r.Codec != codec {
+				continue
+			}
+			samples++
+			total.OriginalSize += r.OriginalSize
+			total.CompressedSize += r.CompressedSize
+			total.compressTime += r.compressTime
+			total.decompressTime += r.decompressTime
+			total.CompressAlloc = max(total.CompressAlloc, r.CompressAlloc)
+			total.DecompressAlloc = max(total.DecompressAlloc, r.DecompressAlloc)
+		}
+		if samples > 0 {
+			total.finish()
+			list = append(list, total)
+		}
+	}
+	return list
+}
@@ -0,0 +1,189 @@
+package bench
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCorpus(t *testing.T) {
+	a, b := Corpus(64<<10, 1), Corpus(64<<10, 1)
+	if len(a) != len(SampleNames()) {
+		t.Fatalf("Expected %d samples, got %d", len(SampleNames()), len(a))
+	}
+	for i := range a {
+		if len(a[i].Data) != 64<<10 {
+			t.Errorf("%s has %d bytes", a[i].Name, len(a[i].Data))
+		}
+		if !bytes.Equal(a[i].Data, b[i].Data) {
+			t.Errorf("%s is not deterministic", a[i].Name)
+		}
+	}
+	if other := Corpus(64<<10, 2); bytes.Equal(a[0].Data, other[0].Data) {
+		t.Error("The seed does not change the corpus")
+	}
+
+	selected, err := Select(a, []string{"random", "text"})
+	if err != nil || len(selected) != 2 || selected[0].Name != "text" {
+		t.Errorf("Unexpected selection %v: %v", selected, err)
+	}
+	if _, err := Select(a, []string{"nope"}); err == nil {
+		t.Error("Expected an error for an unknown sample")
+	}
+}
+
+func TestRun(t *testing.T) {
+	samples, _ := Select(Corpus(32<<10, 1), []string{"text", "low-entropy", "random"})
+	var progress int
+	report, err := Run(context.Background(), samples, Config{MinTime: 1, Progress: func(Result) { progress++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codecs := CodecNames()
+	if len(report.Results) != len(samples)*len(codecs) || progress != len(report.Results) {
+		t.Fatalf("Expected %d results, got %d", len(samples)*len(codecs), len(report.Results))
+	}
+	if len(report.Totals) != len(codecs) {
+		t.Errorf("Expected a total per codec, got %d", len(report.Totals))
+	}
+
+	ratios := make(map[string]float64)
+	sizes := make(map[string]int64)
+	for _, r := range report.Results {
+		ratios[r.Sample+"/"+r.Codec] = r.Ratio
+		sizes[r.Sample+"/"+r.Codec] = r.CompressedSize
+		if r.CompressMBps <= 0 || r.DecompressMBps <= 0 || r.CompressAlloc == 0 {
+			t.Errorf("Missing measurements in %+v", r)
+		}
+	}
+	// Huffman needs about 1.4 bits for the low-entropy sample and cannot shrink random bytes
+	if ratio := ratios["low-entropy/huffman"]; ratio > 0.25 {
+		t.Errorf("Low-entropy ratio with huffman is %.3f", ratio)
+	}
+	if ratio := ratios["random/auto"]; ratio < 1 || ratio > 1.01 {
+		t.Errorf("Random ratio with auto is %.3f", ratio)
+	}
+	// Equally optimal trees can give code tables a few bytes apart, so auto
+	// may lose to huffman by a small slack per block
+	blocks := (int64(len(samples[0].Data)) + huffman.DefaultBlockSize - 1) / huffman.DefaultBlockSize
+	if auto, plain := sizes["text/auto"], sizes["text/huffman"]; auto > plain+16*blocks {
+		t.Errorf("auto compressed text to %d bytes, huffman to %d", auto, plain)
+	}
+
+	if _, err := Run(context.Background(), samples, Config{Codecs: []string{"zip"}}); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, samples, Config{}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	samples, _ := Select(Corpus(4<<10, 1), []string{"text"})
+	report, err := Run(context.Background(), samples, Config{Codecs: []string{"huffman", "rle"}, MinTime: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var md bytes.Buffer
+	report.Write(&md, "markdown")
+	if !strings.Contains(md.String(), "| text | huffman |") || !strings.Contains(md.String(), "| total | rle |") {
+		t.Errorf("Unexpected markdown:\n%s", md.String())
+	}
+
+	var out bytes.Buffer
+	report.Write(&out, "csv")
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 1+2+2 || rows[0][0] != "sample" {
+		t.Errorf("Unexpected csv %v: %v", rows, err)
+	}
+
+	out.Reset()
+	report.Write(&out, "json")
+	var decoded Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Results) != 2 || decoded.Results[0].Ratio != report.Results[0].Ratio {
+		t.Errorf("Unexpected json %+v: %v", decoded, err)
+	}
+
+	if err := report.Write(&out, "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	samples, _ := Select(Corpus(64<<10, 1), []string{"bitmap"})
+	data := samples[0].Data
+	report, err := Compare(context.Background(), data, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != len(CodecNames()) || report.Results[0].Codec != "stored" {
+		t.Fatalf("Expected a result per codec in order, got %+v", report.Results)
+	}
+	if report.Entropy <= 0 || report.Entropy >= 8 || report.EntropyBound >= int64(len(data)) {
+		t.Errorf("Unexpected entropy %.3f, bound %d", report.Entropy, report.EntropyBound)
+	}
+	for _, r := range report.Results {
+		if r.BitsPerSymbol != 8*r.Ratio || r.CompressMs <= 0 {
+			t.Errorf("Unexpected result %+v", r)
+		}
+	}
+	// The fax-like sample has long runs, so rle-huffman or auto win
+	if report.Best != "rle-huffman" && report.Best != "auto" {
+		t.Errorf("Expected rle-huffman to be best, got %s", report.Best)
+	}
+
+	if _, err := Compare(context.Background(), data, []string{"zip"}, 0); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+}
+
+// benchCorpus is smaller than the default so the whole set of benchmarks
+// runs in a few seconds with the default -benchtime.
+var benchCorpus = Corpus(256<<10, 1)
+
+func BenchmarkCompress(b *testing.B) {
+	for _, sample := range benchCorpus {
+		for _, codec := range CodecNames() {
+			b.Run(sample.Name+"/"+codec, func(b *testing.B) {
+				opts := huffman.Options{Codec: codec}
+				b.SetBytes(int64(len(sample.Data)))
+				b.ReportAllocs()
+				var compressed []byte
+				for b.Loop() {
+					var err error
+					if compressed, err = Compress(sample.Data, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(compressed))/float64(len(sample.Data)), "ratio")
+			})
+		}
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	for _, sample := range benchCorpus {
+		for _, codec := range CodecNames() {
+			b.Run(sample.Name+"/"+codec, func(b *testing.B) {
+				compressed, err := Compress(sample.Data, huffman.Options{Codec: codec})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(sample.Data)))
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := Decompress(compressed); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package bench
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Comparison is the result of one codec over a single file.
+type Comparison struct {
+	Codec          string  `json:"codec"`
+	CompressedSize int64   `json:"compressedSize"`
+	Ratio          float64 `json:"ratio"`         // compressed size over original size
+	BitsPerSymbol  float64 `json:"bitsPerSymbol"` // compressed bits per original byte, container included
+	CompressMs     float64 `json:"compressMs"`
+	DecompressMs   float64 `json:"decompressMs"`
+}
+
+// CompareReport compares every codec over one file against its entropy.
+type CompareReport struct {
+	OriginalSize int64 `json:"originalSize"`
+	// Entropy is the order-0 Shannon entropy of the file in bits per symbol,
+	// the least a single Huffman table could average.
+	Entropy float64 `json:"entropy"`
+	// EntropyBound is the size in bytes the file would have at Entropy bits
+	// per symbol, without tables or headers.
+	EntropyBound int64        `json:"entropyBound"`
+	Best         string       `json:"best"` // codec with the smallest output
+	Results      []Comparison `json:"results"`
+}
+
+// Compare compresses data with each codec at the same time, once each, and
+// decompresses the output to time it and check it. Codecs defaults to
+// CodecNames. Timings of parallel runs compete for the CPU, so they are
+// meant to compare codecs with each other rather than to measure throughput;
+// Run does that.
+func Compare(ctx context.Context, data []byte, codecs []string, blockSize int) (*CompareReport, error) {
+	if len(codecs) == 0 {
+		codecs = CodecNames()
+	}
+	for _, name := range codecs {
+		if _, ok := huffman.CodecByName(name); !ok && name != huffman.CodecAuto {
+			return nil, fmt.Errorf("bench: unknown codec %q", name)
+		}
+	}
+
+	report := &CompareReport{OriginalSize: int64(len(data)), Results: make([]Comparison, len(codecs))}
+	frequencies := make(map[byte]int)
+	for _, b := range data {
+		frequencies[b]++
+	}
+	report.Entropy = huffman.Entropy(frequencies)
+	report.EntropyBound = int64(math.Ceil(report.Entropy * float64(len(data)) / 8))
+
+	errs := make([]error, len(codecs))
+	var wg sync.WaitGroup
+	for i, codec := range codecs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Results[i], errs[i] = compareCodec(ctx, data, huffman.Options{Codec: codec, BlockSize: blockSize})
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("bench: %s: %w", codecs[i], err)
+		}
+	}
+
+	best := report.Results[0]
+	for _, r := range report.Results[1:] {
+		if r.CompressedSize < best.CompressedSize {
+			best = r
+		}
+	}
+	report.Best = best.Codec
+	return report, nil
+}
+
+func compareCodec(ctx context.Context, data []byte, opts huffman.Options) (Comparison, error) {
+	result := Comparison{Codec: opts.Codec}
+	start := time.Now()
+	compressed, err := compress(ctx, data, opts)
+	if err != nil {
+		return result, err
+	}
+	result.CompressMs = milliseconds(time.Since(start))
+
+	start = time.Now()
+	decompressed, err := decompress(ctx, compressed)
+	if err != nil {
+		return result, err
+	}
+	result.DecompressMs = milliseconds(time.Since(start))
+	if !bytes.Equal(decompressed, data) {
+		return result, fmt.Errorf("decompressed data does not match the input")
+	}
+
+	result.CompressedSize = int64(len(compressed))
+	if len(data) > 0 {
+		result.Ratio = float64(len(compressed)) / float64(len(data))
+		result.BitsPerSymbol = 8 * result.Ratio
+	}
+	return result, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,258 @@
+// Package bench measures every registered codec over a synthetic corpus and
+// reports compression ratio, throughput and memory. The corpus is generated
+// from a fixed seed so runs on different machines compress the same bytes.
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// DefaultSampleSize is the size of each corpus sample when none is given.
+const DefaultSampleSize = 1 << 20
+
+// Sample is one file of the corpus.
+type Sample struct {
+	Name string
+	// Description says what real files the sample imitates.
+	Description string
+	Data        []byte
+}
+
+type generator struct {
+	name        string
+	description string
+	fill        func(rng *rand.Rand, size int) []byte
+}
+
+// generators imitate the kinds of files found in the Canterbury and Silesia
+// corpora: prose, source code, markup, spreadsheets, executables, fax images
+// and incompressible data.
+var generators = []generator{
+	{"text", "English-like prose with a Zipf word distribution", genText},
+	{"source", "Go-like source code with indentation and repeated keywords", genSource},
+	{"markup", "XML records with repeated tags and numeric fields", genMarkup},
+	{"records", "Fixed-size little-endian binary records, like a database table", genRecords},
+	{"executable", "Machine-code-like bytes with frequent opcodes and small offsets", genExecutable},
+	{"bitmap", "Black and white scanlines with long runs, like a fax image", genBitmap},
+	{"low-entropy", "Four symbols with a skewed distribution", genLowEntropy},
+	{"random", "Uniformly random bytes", genRandom},
+}
+
+// Corpus generates every sample with size bytes from seed. The same size and
+// seed always produce the same bytes.
+func Corpus(size int, seed int64) []Sample {
+	if size <= 0 {
+		size = DefaultSampleSize
+	}
+	samples := make([]Sample, len(generators))
+	for i, g := range generators {
+		// Each sample has its own source so adding one does not change the others
+		rng := rand.New(rand.NewSource(seed + int64(i)))
+		samples[i] = Sample{Name: g.name, Description: g.description, Data: g.fill(rng, size)[:size]}
+	}
+	return samples
+}
+
+// SampleNames lists the names accepted by Select.
+func SampleNames() []string {
+	names := make([]string, len(generators))
+	for i, g := range generators {
+		names[i] = g.name
+	}
+	return names
+}
+
+// Select keeps the samples named in names, in the corpus order. An empty
+// list keeps every sample.
+func Select(samples []Sample, names []string) ([]Sample, error) {
+	if len(names) == 0 {
+		return samples, nil
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var selected []Sample
+	for _, s := range samples {
+		if wanted[s.Name] {
+			selected = append(selected, s)
+			delete(wanted, s.Name)
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("bench: unknown sample %q (available: %s)", name, strings.Join(SampleNames(), ", "))
+	}
+	return selected, nil
+}
+
+// zipf picks indexes of a vocabulary of n entries, the first ones more often.
+func zipf(rng *rand.Rand, n int) *rand.Zipf {
+	return rand.NewZipf(rng, 1.2, 1, uint64(n-1))
+}
+
+var words = strings.Fields(`the of and to a in is it that was he for on are as with his they at be this
+from have or by one had not but what all were when we there can an your which their said if do will
+each about how up out them then she many some so these would other into has more her two like him see
+time could no make than first been its who now people my made over did down only way find use may water
+long little very after words called just where most know get through back much before go good new write
+our used me man too any day same right look think also around another came come work three word must
+because does part even place well such here take why things help put years different away again off
+went old number great tell men say small every found still between name should home big give air line
+set own under read last never us left end along while might next sound below saw something thought both
+few those always looked show large often together asked house world going want school important until
+form food keep children feet land side without boy once animals life enough took sometimes four head
+above kind began almost live page got earth need far hand high year mother light parts country father`)
+
+func genText(rng *rand.Rand, size int) []byte {
+	var b strings.Builder
+	pick := zipf(rng, len(words))
+	sentence := 0
+	for b.Len() < size {
+		word := words[pick.Uint64()]
+		if sentence == 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		b.WriteString(word)
+		sentence++
+		switch {
+		case sentence > 6 && rng.Intn(8) == 0:
+			b.WriteString(". ")
+			sentence = 0
+			if rng.Intn(6) == 0 {
+				b.WriteString("\n\n")
+			}
+		case rng.Intn(15) == 0:
+			b.WriteString(", ")
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return []byte(b.String())
+}
+
+var identifiers = []string{"err", "data", "size", "buf", "i", "n", "result", "codes", "node", "block",
+	"reader", "writer", "offset", "count", "value", "key", "index", "frame", "opts", "ctx"}
+
+func genSource(rng *rand.Rand, size int) []byte {
+	var b strings.Builder
+	ident := func() string { return identifiers[rng.Intn(len(identifiers))] }
+	for b.Len() < size {
+		fmt.Fprintf(&b, "// %s returns the %s of the %s.\n", ident(), ident(), ident())
+		fmt.Fprintf(&b, "func %s(%s []byte, %s int) (int, error) {\n", ident(), ident(), ident())
+		for lines := 2 + rng.Intn(8); lines > 0; lines-- {
+			indent := strings.Repeat("\t", 1+rng.Intn(3))
+			switch rng.Intn(5) {
+			case 0:
+				fmt.Fprintf(&b, "%sif %s != nil {\n%s\treturn 0, %s\n%s}\n", indent, ident(), indent, ident(), indent)
+			case 1:
+				fmt.Fprintf(&b, "%sfor %s := 0; %s < len(%s); %s++ {\n%s}\n", indent, ident(), ident(), ident(), ident(), indent)
+			case 2:
+				fmt.Fprintf(&b, "%s%s := %s(%s, %d)\n", indent, ident(), ident(), ident(), rng.Intn(256))
+			default:
+				fmt.Fprintf(&b, "%s%s += %s[%s]\n", indent, ident(), ident(), ident())
+			}
+		}
+		fmt.Fprintf(&b, "\treturn %s, nil\n}\n\n", ident())
+	}
+	return []byte(b.String())
+}
+
+func genMarkup(rng *rand.Rand, size int) []byte {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<catalog>\n")
+	pick := zipf(rng, len(words))
+	for id := 1; b.Len() < size; id++ {
+		fmt.Fprintf(&b, "  <item id=\"%d\">\n    <name>%s %s</name>\n", id, words[pick.Uint64()], words[pick.Uint64()])
+		fmt.Fprintf(&b, "    <price currency=\"EUR\">%d.%02d</price>\n    <stock>%d</stock>\n", rng.Intn(500), rng.Intn(100), rng.Intn(1000))
+		fmt.Fprintf(&b, "  </item>\n")
+	}
+	b.WriteString("</catalog>\n")
+	return []byte(b.String())
+}
+
+func genRecords(rng *rand.Rand, size int) []byte {
+	// id, timestamp, small category, price as float64, quantity and padding
+	const recordSize = 32
+	out := make([]byte, 0, size+recordSize)
+	record := make([]byte, recordSize)
+	timestamp := uint64(1700000000)
+	for id := uint32(1); len(out) < size; id++ {
+		timestamp += uint64(rng.Intn(60))
+		binary.LittleEndian.PutUint32(record[0:], id)
+		binary.LittleEndian.PutUint64(record[4:], timestamp)
+		binary.LittleEndian.PutUint16(record[12:], uint16(rng.Intn(12)))
+		binary.LittleEndian.PutUint64(record[14:], math.Float64bits(float64(rng.Intn(10000))/100))
+		binary.LittleEndian.PutUint32(record[22:], uint32(rng.Intn(50)))
+		clear(record[26:])
+		out = append(out, record...)
+	}
+	return out
+}
+
+func genExecutable(rng *rand.Rand, size int) []byte {
+	opcodes := []byte{0x48, 0x89, 0x8b, 0xe8, 0xc3, 0x0f, 0x85, 0x84, 0x83, 0xff, 0x74, 0x75, 0x31, 0xc0, 0x5d, 0x55}
+	out := make([]byte, 0, size+8)
+	for len(out) < size {
+		switch rng.Intn(10) {
+		case 0, 1:
+			// Relative call or jump with a 32-bit offset that is usually small
+			out = append(out, 0xe8)
+			out = binary.LittleEndian.AppendUint32(out, uint32(int32(rng.NormFloat64()*2000)))
+		case 2:
+			out = append(out, byte(rng.Intn(256)))
+		case 3:
+			out = append(out, 0, 0, 0, 0)
+		default:
+			out = append(out, opcodes[int(math.Abs(rng.NormFloat64())*4)%len(opcodes)])
+		}
+	}
+	return out
+}
+
+func genBitmap(rng *rand.Rand, size int) []byte {
+	// 1728 pixels per scanline as in a G3 fax, one bit per pixel
+	const lineBytes = 1728 / 8
+	out := make([]byte, 0, size+lineBytes)
+	line := make([]byte, lineBytes)
+	for len(out) < size {
+		clear(line)
+		if rng.Intn(3) > 0 {
+			// A line of text: a few short dark strokes on a white page
+			for strokes := rng.Intn(20); strokes > 0; strokes-- {
+				start := rng.Intn(lineBytes)
+				for i := start; i < min(lineBytes, start+1+rng.Intn(6)); i++ {
+					line[i] = 0xff
+				}
+			}
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
+func genLowEntropy(rng *rand.Rand, size int) []byte {
+	out := make([]byte, size)
+	for i := range out {
+		switch r := rng.Intn(100); {
+		case r < 70:
+			out[i] = 'a'
+		case r < 90:
+			out[i] = 'b'
+		case r < 98:
+			out[i] = 'c'
+		default:
+			out[i] = 'd'
+		}
+	}
+	return out
+}
+
+func genRandom(rng *rand.Rand, size int) []byte {
+	out := make([]byte, size)
+	rng.Read(out)
+	return out
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Formats lists the names accepted by Report.Write.
+var Formats = []string{"markdown", "csv", "json"}
+
+// Write writes the report as markdown, csv or json.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "markdown", "md":
+		return r.WriteMarkdown(w)
+	case "csv":
+		return r.WriteCSV(w)
+	case "json":
+		return r.WriteJSON(w)
+	}
+	return fmt.Errorf("bench: unknown report format %q", format)
+}
+
+// WriteMarkdown writes a table per sample and codec followed by the totals of each codec.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "## Results\n\nSamples of %d bytes, blocks of %d bytes.\n\n", r.SampleSize, r.BlockSize)
+	writeTable(w, r.Results)
+	fmt.Fprintf(w, "\n## Totals\n\nThe allocation columns show the largest of any sample.\n\n")
+	writeTable(w, r.Totals)
+	return nil
+}
+
+func writeTable(w io.Writer, results []Result) {
+	fmt.Fprintln(w, "| Sample | Codec | Original | Compressed | Ratio | Compress MB/s | Decompress MB/s | Compress alloc | Decompress alloc |")
+	fmt.Fprintln(w, "|---|---|---:|---:|---:|---:|---:|---:|---:|")
+	for _, res := range results {
+		fmt.Fprintf(w, "| %s | %s | %d | %d | %.3f | %.1f | %.1f | %s | %s |\n", res.Sample, res.Codec,
+			res.OriginalSize, res.CompressedSize, res.Ratio, res.CompressMBps, res.DecompressMBps,
+			formatBytes(res.CompressAlloc), formatBytes(res.DecompressAlloc))
+	}
+}
+
+// WriteCSV writes one row per result and then the totals, whose sample is TotalSample.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"sample", "codec", "original_size", "compressed_size", "ratio",
+		"compress_mbps", "decompress_mbps", "compress_alloc_bytes", "decompress_alloc_bytes"})
+	for _, list := range [][]Result{r.Results, r.Totals} {
+		for _, res := range list {
+			cw.Write([]string{res.Sample, res.Codec,
+				strconv.FormatInt(res.OriginalSize, 10), strconv.FormatInt(res.CompressedSize, 10),
+				strconv.FormatFloat(res.Ratio, 'f', 4, 64),
+				strconv.FormatFloat(res.CompressMBps, 'f', 2, 64), strconv.FormatFloat(res.DecompressMBps, 'f', 2, 64),
+				strconv.FormatUint(res.CompressAlloc, 10), strconv.FormatUint(res.DecompressAlloc, 10)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the whole report as an indented JSON object.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,744 @@
+package main
+
+import (
+	"Compression_Upc/huffman"
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// huffExt is the extension of compressed files.
+const huffExt = ".huff"
+
+// fileCommand holds what compress and decompress share: which files to
+// process, where the output goes and what happens to the input. Like gzip,
+// the input is removed after a successful operation unless -k is given or
+// the output goes to stdout.
+type fileCommand struct {
+	name      string // "compress" or "decompress"
+	output    string
+	keep      bool
+	force     bool
+	recursive bool
+	verbose   bool
+	opts      huffman.Options
+	s         streams
+}
+
+func (c *fileCommand) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.output, "o", "", "escribe la salida en `archivo` (\"-\" es la salida estándar); solo con una entrada")
+	fs.BoolVar(&c.keep, "k", false, "conserva los archivos de entrada")
+	fs.BoolVar(&c.force, "f", false, "sobrescribe las salidas existentes y escribe en una terminal")
+	fs.BoolVar(&c.recursive, "r", false, "recorre los directorios recursivamente")
+	fs.BoolVar(&c.verbose, "v", false, "muestra el resultado de cada archivo")
+}
+
+// newFlagSet returns a flag set for command whose usage is printed in Spanish.
+func newFlagSet(command, args string, s streams) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(s.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(s.stderr, "Uso: %s %s [opciones] %s\nOpciones:\n", progName, command, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and reports the exit code to use if parsing ended
+// the command.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK, false
+	}
+	if err != nil {
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// usageError reports a wrong invocation of command.
+func usageError(s streams, command, format string, args ...any) int {
+	fmt.Fprintf(s.stderr, "%s %s: %s\n", progName, command, fmt.Sprintf(format, args...))
+	return exitUsage
+}
+
+// password returns the -password flag or, to keep it out of ps, the
+// HUFFMAN_PASSWORD environment variable.
+func password(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv("HUFFMAN_PASSWORD")
+}
+
+func runCompress(ctx context.Context, args []string, s streams) int {
+	c := &fileCommand{name: "compress", s: s}
+	fs := newFlagSet("compress", "[archivos...]", s)
+	c.flags(fs)
+	method := fs.String("m", "huffman", "método de compresión: "+methodNames())
+	blockSize := fs.Int("b", huffman.DefaultBlockSize, "tamaño de bloque en bytes")
+	parity := fs.Int("parity", 0, "fragmentos de paridad Reed-Solomon por grupo de bloques")
+	minVariance := fs.Bool("min-variance", false, "construye árboles de varianza mínima: mismo tamaño, longitudes de código más parejas")
+	pass := fs.String("password", "", "cifra la salida con esta contraseña (o HUFFMAN_PASSWORD)")
+	signKey := fs.String("signkey", "", "firma la salida con la clave privada de este `archivo`")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	c.opts = huffman.Options{Codec: *method, BlockSize: *blockSize, Parity: *parity, MinVariance: *minVariance, Password: password(*pass)}
+	if *method != huffman.CodecAuto {
+		if _, ok := huffman.CodecByName(*method); !ok {
+			return usageError(s, c.name, "método desconocido %q (use %s)", *method, methodNames())
+		}
+	}
+	if *parity < 0 || *parity > huffman.MaxParity {
+		return usageError(s, c.name, "la paridad debe estar entre 0 y %d", huffman.MaxParity)
+	}
+	if *blockSize <= 0 || *blockSize > huffman.MaxBlockSize {
+		return usageError(s, c.name, "el tamaño de bloque debe estar entre 1 y %d", huffman.MaxBlockSize)
+	}
+	if *signKey != "" {
+		key, err := loadSigningKey(*signKey)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %v\n", progName, *signKey, err)
+			return exitError
+		}
+		c.opts.SigningKey = key
+	}
+	return c.run(ctx, fs.Args())
+}
+
+func runDecompress(ctx context.Context, args []string, s streams) int {
+	c := &fileCommand{name: "decompress", s: s}
+	fs := newFlagSet("decompress", "[archivos.huff...]", s)
+	c.flags(fs)
+	pass := fs.String("password", "", "contraseña de los archivos cifrados (o HUFFMAN_PASSWORD)")
+	pubKey := fs.String("pubkey", "", "exige que los archivos estén firmados con la clave pública de este `archivo`")
+	skipCorrupt := fs.Bool("skip-corrupt", false, "descarta los bloques dañados en lugar de fallar")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	c.opts = huffman.Options{Password: password(*pass), SkipCorrupt: *skipCorrupt}
+	if *pubKey != "" {
+		key, err := loadPublicKey(*pubKey)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %v\n", progName, *pubKey, err)
+			return exitError
+		}
+		c.opts.VerifyKey = key
+	}
+	return c.run(ctx, fs.Args())
+}
+
+// run processes every input and returns the exit code. A failed file does
+// not stop the others; an interruption does.
+func (c *fileCommand) run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	inputs, code := c.expand(args)
+	if c.output != "" && (len(args) > 1 || len(inputs) > 1) {
+		return usageError(c.s, c.name, "-o solo se puede usar con una entrada")
+	}
+	for _, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			fmt.Fprintf(c.s.stderr, "%s: interrumpido\n", progName)
+			return exitError
+		}
+		var err error
+		if input == "-" {
+			err = c.stream(ctx, c.s.stdin)
+		} else {
+			err = c.file(ctx, input)
+		}
+		if err != nil {
+			fmt.Fprintf(c.s.stderr, "%s: %s: %s\n", progName, displayName(input), describeError(err))
+			code = exitError
+		}
+	}
+	return code
+}
+
+// expand replaces the directories in args by the files they contain, when
+// -r is given. Directories without -r are reported and skipped.
+func (c *fileCommand) expand(args []string) ([]string, int) {
+	code := exitOK
+	var inputs []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if arg == "-" || err != nil || !info.IsDir() {
+			// Missing files are reported when they are opened
+			inputs = append(inputs, arg)
+			continue
+		}
+		if !c.recursive {
+			fmt.Fprintf(c.s.stderr, "%s: %s: es un directorio, use -r\n", progName, arg)
+			code = exitError
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// In a tree, decompress only looks at .huff files and compress skips them
+			if d.Type().IsRegular() && strings.HasSuffix(path, huffExt) == (c.name == "decompress") {
+				inputs = append(inputs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(c.s.stderr, "%s: %v\n", progName, err)
+			code = exitError
+		}
+	}
+	return inputs, code
+}
+
+// outputName returns where the result of input goes.
+func (c *fileCommand) outputName(input string) (string, error) {
+	if c.output != "" {
+		return c.output, nil
+	}
+	if c.name == "compress" {
+		if strings.HasSuffix(input, huffExt) && !c.force {
+			return "", errors.New("ya tiene la extensión " + huffExt + ", use -f para comprimirlo de nuevo")
+		}
+		return input + huffExt, nil
+	}
+	if !strings.HasSuffix(input, huffExt) || input == huffExt {
+		return "", errors.New("no tiene la extensión " + huffExt + ", use -o para indicar la salida")
+	}
+	return strings.TrimSuffix(input, huffExt), nil
+}
+
+// stream compresses or decompresses in to stdout, or to -o if given.
+func (c *fileCommand) stream(ctx context.Context, in io.Reader) error {
+	if c.output != "" && c.output != "-" {
+		return c.streamToFile(ctx, in, c.output)
+	}
+	// Compressed data on a terminal is only noise
+	if c.name == "compress" && !c.force && isTerminal(c.s.stdout) {
+		return errors.New("no se escriben datos comprimidos en una terminal, use -o o -f")
+	}
+	return c.transform(ctx, in, c.s.stdout)
+}
+
+// transform runs the command from in to out.
+func (c *fileCommand) transform(ctx context.Context, in io.Reader, out io.Writer) error {
+	if c.name == "compress" {
+		zw, err := huffman.NewWriterContext(ctx, out, c.opts)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(zw, in); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	// Like the file functions, read the original single-table format when
+	// the input does not start with the container magic
+	br := bufio.NewReader(in)
+	if magic, err := br.Peek(len(huffman.Magic)); err != nil || string(magic) != huffman.Magic {
+		data, err := huffman.ReadLegacy(br, c.opts)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+	zr, err := huffman.NewReaderContext(ctx, br, c.opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, zr)
+	return err
+}
+
+// file compresses or decompresses the file at input.
+func (c *fileCommand) file(ctx context.Context, input string) error {
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("no es un archivo regular")
+	}
+	output, err := c.outputName(input)
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		in, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return c.stream(ctx, in)
+	}
+	if sameFile(input, output) {
+		return errors.New("la entrada y la salida son el mismo archivo")
+	}
+
+	write := func(tmp string) error {
+		if c.name == "compress" {
+			return huffman.CompressContext(ctx, input, tmp, c.opts)
+		}
+		// The file functions also read the original single-table format
+		return huffman.DecompressContext(ctx, input, tmp, c.opts)
+	}
+	if err := c.writeOutput(output, info, write); err != nil {
+		return err
+	}
+
+	if c.verbose {
+		c.report(input, output, info.Size())
+	}
+	if !c.keep {
+		return os.Remove(input)
+	}
+	return nil
+}
+
+// streamToFile writes the result of reading in to output.
+func (c *fileCommand) streamToFile(ctx context.Context, in io.Reader, output string) error {
+	return c.writeOutput(output, nil, func(tmp string) error {
+		f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := c.transform(ctx, in, f); err != nil {
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// writeOutput has write fill a temporary file next to output and renames it
+// into place, so an existing output is never left half written. The result
+// takes the permissions and modification time of the input, if known.
+func (c *fileCommand) writeOutput(output string, input fs.FileInfo, write func(tmp string) error) error {
+	if _, err := os.Lstat(output); err == nil && !c.force {
+		return fmt.Errorf("%s ya existe, use -f para sobrescribirlo", output)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpName)
+
+	if err := write(tmpName); err != nil {
+		return err
+	}
+	if input != nil {
+		os.Chmod(tmpName, input.Mode().Perm())
+		os.Chtimes(tmpName, input.ModTime(), input.ModTime())
+	}
+	return os.Rename(tmpName, output)
+}
+
+// report prints the size change of input like gzip -v.
+func (c *fileCommand) report(input, output string, inputSize int64) {
+	info, err := os.Stat(output)
+	if err != nil {
+		return
+	}
+	original, compressed := inputSize, info.Size()
+	if c.name == "decompress" {
+		original, compressed = compressed, original
+	}
+	saved := 0.0
+	if original > 0 {
+		saved = 100 * (1 - float64(compressed)/float64(original))
+	}
+	action := "creado"
+	if !c.keep {
+		action = "reemplazado por"
+	}
+	fmt.Fprintf(c.s.stderr, "%s: %5.1f%% -- %s %s\n", input, saved, action, output)
+}
+
+func runVerify(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("verify", "[archivos.huff...]", s)
+	recursive := fs.Bool("r", false, "recorre los directorios recursivamente")
+	pubKey := fs.String("pubkey", "", "exige que los archivos estén firmados con la clave pública de este `archivo`")
+	quiet := fs.Bool("q", false, "solo informa los archivos con problemas")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	var opts huffman.Options
+	if *pubKey != "" {
+		key, err := loadPublicKey(*pubKey)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %v\n", progName, *pubKey, err)
+			return exitError
+		}
+		opts.VerifyKey = key
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	// Reuse the directory walk of decompress, which picks .huff files
+	walker := &fileCommand{name: "decompress", recursive: *recursive, s: s}
+	inputs, code := walker.expand(files)
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			fmt.Fprintf(s.stderr, "%s: interrumpido\n", progName)
+			return exitError
+		}
+		report, err := verifyInput(input, s.stdin, opts)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %s\n", progName, displayName(input), describeError(err))
+			code = exitError
+			continue
+		}
+		if !report.OK() {
+			code = exitError
+		} else if *quiet {
+			continue
+		}
+		printVerifyReport(s.stdout, displayName(input), report)
+	}
+	return code
+}
+
+func verifyInput(input string, stdin io.Reader, opts huffman.Options) (*huffman.VerifyReport, error) {
+	if input == "-" {
+		return huffman.VerifyWithOptions(stdin, opts)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return huffman.VerifyWithOptions(f, opts)
+}
+
+func printVerifyReport(w io.Writer, name string, report *huffman.VerifyReport) {
+	if report.OK() {
+		details := fmt.Sprintf("%d bloques, %d bytes", report.Blocks, report.OriginalSize)
+		if report.Signed {
+			details += ", firmado por " + report.Signer
+		}
+		if report.Encrypted {
+			details += ", cifrado"
+		}
+		fmt.Fprintf(w, "%s: OK (%s)\n", name, details)
+		for _, block := range report.CorruptBlocks {
+			fmt.Fprintf(w, "  bloque %d (offset %d): reparable con la paridad\n", block.Index, block.Offset)
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s: DAÑADO\n", name)
+	for _, block := range report.CorruptBlocks {
+		estado := "irrecuperable"
+		if block.Repaired {
+			estado = "reparable con la paridad"
+		}
+		fmt.Fprintf(w, "  bloque %d (offset %d): %s (%s)\n", block.Index, block.Offset, estado, block.Error)
+	}
+	if report.Truncated {
+		fmt.Fprintln(w, "  el archivo está truncado o su estructura está dañada")
+	}
+	if report.SignatureError != "" {
+		fmt.Fprintf(w, "  firma: %s\n", report.SignatureError)
+	}
+}
+
+func runInspect(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("inspect", "[archivos.huff...]", s)
+	recursive := fs.Bool("r", false, "recorre los directorios recursivamente")
+	pass := fs.String("password", "", "contraseña para leer las tablas de un archivo cifrado (o HUFFMAN_PASSWORD)")
+	asJSON := fs.Bool("json", false, "escribe el reporte en JSON, como POST /inspect")
+	blocks := fs.Bool("blocks", false, "lista también cada bloque")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	opts := huffman.Options{Password: password(*pass)}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	walker := &fileCommand{name: "decompress", recursive: *recursive, s: s}
+	inputs, code := walker.expand(files)
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			fmt.Fprintf(s.stderr, "%s: interrumpido\n", progName)
+			return exitError
+		}
+		report, err := inspectInput(input, s.stdin, opts)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %s\n", progName, displayName(input), describeError(err))
+			code = exitError
+			continue
+		}
+		if *asJSON {
+			enc := json.NewEncoder(s.stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			continue
+		}
+		printInspectReport(s.stdout, displayName(input), report, *blocks)
+	}
+	return code
+}
+
+func inspectInput(input string, stdin io.Reader, opts huffman.Options) (*huffman.InspectReport, error) {
+	if input == "-" {
+		return huffman.Inspect(stdin, opts)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return huffman.Inspect(f, opts)
+}
+
+func printInspectReport(w io.Writer, name string, report *huffman.InspectReport, blocks bool) {
+	fmt.Fprintf(w, "%s:\n", name)
+	if report.Format == "legacy" {
+		fmt.Fprintln(w, "  formato:     original (una sola tabla, sin contenedor)")
+	} else {
+		features := ""
+		if report.Parity {
+			features += ", paridad"
+		}
+		if report.Encrypted {
+			features += ", cifrado"
+		}
+		if report.Signed {
+			features += ", firmado por " + report.Signer
+		}
+		fmt.Fprintf(w, "  formato:     contenedor v%d, bloques de %d bytes%s\n", report.Version, report.BlockSize, features)
+	}
+	fmt.Fprintf(w, "  codec:       %s\n", report.Codec)
+	fmt.Fprintf(w, "  bloques:     %d\n", report.Blocks)
+	fmt.Fprintf(w, "  original:    %d bytes\n", report.OriginalSize)
+	fmt.Fprintf(w, "  comprimido:  %d bytes (%.1f%%)\n", report.CompressedSize, report.Ratio*100)
+	o := report.Overhead
+	fmt.Fprintf(w, "  sobrecarga:  %d bytes (encabezado %d, bloques %d, tablas %d, registros %d)\n",
+		o.Total, o.Header, o.BlockHeaders, o.CodeTables, o.Records)
+
+	if len(report.CodeTable) == 0 {
+		if report.Encrypted {
+			fmt.Fprintln(w, "  tabla de códigos: use -password para leerla")
+		}
+	} else {
+		fmt.Fprintf(w, "  tabla de códigos (%d símbolos, %.3f bits por símbolo, varianza %.3f):\n",
+			len(report.CodeTable), report.BitsPerSymbol, report.CodeLengthVariance)
+		fmt.Fprintln(w, "    símbolo  long  frecuencia  código")
+		for _, entry := range report.CodeTable {
+			fmt.Fprintf(w, "    %-7s  %4d  %10d  %s\n", symbolName(entry.Symbol), entry.Length, entry.Frequency, entry.Code)
+		}
+	}
+	if !blocks {
+		return
+	}
+	fmt.Fprintln(w, "  bloques:")
+	for _, block := range report.BlockList {
+		fmt.Fprintf(w, "    %4d  offset %-10d %-12s %8d -> %8d bytes, tabla %d", block.Index, block.Offset, block.Codec,
+			block.OriginalSize, block.CompressedSize, block.TableSize)
+		if block.Error != "" {
+			fmt.Fprintf(w, " (%s)", block.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// symbolName shows printable bytes as characters and the rest in hex.
+func symbolName(b byte) string {
+	if b > ' ' && b < 0x7f {
+		return fmt.Sprintf("'%c'", b)
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
+
+func runRepair(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("repair", "<archivo.huff>", s)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := fs.Arg(0)
+	if err := repairFile(path, s.stdout); err != nil {
+		fmt.Fprintf(s.stderr, "Error al reparar %s: %v\n", path, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// repairFile rebuilds the damaged blocks of a .huff file in place.
+func repairFile(path string, w io.Writer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Write to a temporary file so the original survives a failed repair
+	tmpPath := path + ".repair"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+
+	report, err := huffman.Repair(in, out)
+	if report != nil {
+		for _, block := range report.CorruptBlocks {
+			estado := "irrecuperable"
+			if block.Repaired {
+				estado = "reparado"
+			}
+			fmt.Fprintf(w, "Bloque %d (offset %d): %s (%s)\n", block.Index, block.Offset, estado, block.Error)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+
+	if len(report.CorruptBlocks) == 0 {
+		fmt.Fprintf(w, "%s no tiene bloques dañados\n", path)
+		return nil
+	}
+	fmt.Fprintf(w, "%d bloques reparados en %s\n", len(report.CorruptBlocks), path)
+	return os.Rename(tmpPath, path)
+}
+
+func runKeygen(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("keygen", "<prefijo>", s)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := generateKeyPair(fs.Arg(0), s.stdout); err != nil {
+		fmt.Fprintf(s.stderr, "Error al generar las claves: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// generateKeyPair writes a new Ed25519 keypair to prefix.key and prefix.pub.
+func generateKeyPair(prefix string, w io.Writer) error {
+	pub, priv, err := huffman.GenerateKey()
+	if err != nil {
+		return err
+	}
+	privPEM, err := huffman.MarshalPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubPEM, err := huffman.MarshalPublicKey(pub)
+	if err != nil {
+		return err
+	}
+
+	// The private key must not be overwritten by accident nor readable by others
+	keyFile, err := os.OpenFile(prefix+".key", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := keyFile.Write(privPEM); err != nil {
+		keyFile.Close()
+		return err
+	}
+	if err := keyFile.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(prefix+".pub", pubPEM, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Clave privada: %s.key\nClave pública: %s.pub\n", prefix, prefix)
+	return nil
+}
+
+// loadPublicKey reads a PEM Ed25519 public key written by keygen.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return huffman.ParsePublicKey(data)
+}
+
+// describeError explains the errors a user can fix in Spanish.
+func describeError(err error) string {
+	var blockErr *huffman.BlockError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "interrumpido"
+	case errors.Is(err, huffman.ErrPasswordRequired):
+		return "el archivo está cifrado, use -password"
+	case errors.Is(err, huffman.ErrWrongPassword):
+		return "contraseña incorrecta"
+	case errors.Is(err, huffman.ErrNotSigned):
+		return "el archivo no está firmado"
+	case errors.Is(err, huffman.ErrSignature):
+		return "la firma no es válida: el archivo fue modificado o firmado con otra clave"
+	case errors.Is(err, huffman.ErrFormat):
+		return "no es un archivo .huff válido"
+	case errors.As(err, &blockErr):
+		return fmt.Sprintf("el bloque %d está dañado, use verify o repair (%v)", blockErr.Index, blockErr.Err)
+	}
+	return err.Error()
+}
+
+func displayName(input string) string {
+	if input == "-" {
+		return "entrada estándar"
+	}
+	return input
+}
+
+func methodNames() string {
+	var names []string
+	for _, c := range huffman.Codecs() {
+		names = append(names, c.Name())
+	}
+	return strings.Join(append(names, huffman.CodecAuto), ", ")
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
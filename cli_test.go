@@ -0,0 +1,297 @@
+package main
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the program with args and stdin, returning the exit code and
+// what it wrote.
+func runCLI(t *testing.T, stdin []byte, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, streams{bytes.NewReader(stdin), &stdout, &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestCLIFiles(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("command line compression "), 2000)
+	input := filepath.Join(dir, "data.txt")
+	writeFile(t, input, content)
+
+	// Like gzip, the input is replaced by the compressed file
+	if code, _, stderr := runCLI(t, nil, "compress", input); code != exitOK {
+		t.Fatalf("compress failed with %d: %s", code, stderr)
+	}
+	if exists(input) || !exists(input+".huff") {
+		t.Fatal("Expected data.txt to be replaced by data.txt.huff")
+	}
+
+	// -k keeps the input, and an existing output needs -f
+	if code, _, _ := runCLI(t, nil, "decompress", "-k", input+".huff"); code != exitOK {
+		t.Fatalf("decompress failed with %d", code)
+	}
+	if data, _ := os.ReadFile(input); !bytes.Equal(data, content) || !exists(input+".huff") {
+		t.Fatal("decompress -k did not restore the file and keep the input")
+	}
+	if code, _, stderr := runCLI(t, nil, "decompress", input+".huff"); code != exitError || !strings.Contains(stderr, "-f") {
+		t.Errorf("Expected an error for an existing output, got %d: %s", code, stderr)
+	}
+	if code, _, _ := runCLI(t, nil, "decompress", "-f", input+".huff"); code != exitOK {
+		t.Errorf("decompress -f failed with %d", code)
+	}
+
+	// -o chooses the output name
+	output := filepath.Join(dir, "custom.bin")
+	if code, _, _ := runCLI(t, nil, "compress", "-k", "-m", "auto", "-o", output, input); code != exitOK || !exists(output) {
+		t.Fatalf("compress -o failed with %d", code)
+	}
+	if code, stdout, _ := runCLI(t, nil, "verify", output); code != exitOK || !strings.Contains(stdout, "OK") {
+		t.Errorf("verify failed with %d: %s", code, stdout)
+	}
+}
+
+func TestCLIRecursive(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"}
+	for _, name := range files {
+		writeFile(t, filepath.Join(dir, name), []byte(strings.Repeat(name, 500)))
+	}
+
+	if code, _, _ := runCLI(t, nil, "compress", dir); code != exitError {
+		t.Errorf("Expected a directory without -r to fail, got %d", code)
+	}
+	if code, _, stderr := runCLI(t, nil, "compress", "-r", dir); code != exitOK {
+		t.Fatalf("compress -r failed with %d: %s", code, stderr)
+	}
+	for _, name := range files {
+		if !exists(filepath.Join(dir, name+".huff")) || exists(filepath.Join(dir, name)) {
+			t.Errorf("%s was not compressed", name)
+		}
+	}
+	// A second pass does not compress the .huff files again
+	if code, _, _ := runCLI(t, nil, "compress", "-r", dir); code != exitOK {
+		t.Errorf("compress -r over compressed files failed with %d", code)
+	}
+	if code, _, _ := runCLI(t, nil, "verify", "-r", "-q", dir); code != exitOK {
+		t.Errorf("verify -r failed with %d", code)
+	}
+	if code, _, _ := runCLI(t, nil, "decompress", "-r", dir); code != exitOK {
+		t.Fatalf("decompress -r failed with %d", code)
+	}
+	for _, name := range files {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != strings.Repeat(name, 500) {
+			t.Errorf("%s was not restored", name)
+		}
+	}
+}
+
+func TestCLIPipeline(t *testing.T) {
+	content := bytes.Repeat([]byte("piped through stdin "), 5000)
+	code, compressed, stderr := runCLI(t, content, "compress", "-m", "rle-huffman")
+	if code != exitOK {
+		t.Fatalf("compress from stdin failed with %d: %s", code, stderr)
+	}
+	if len(compressed) >= len(content) {
+		t.Errorf("Output was not compressed: %d bytes", len(compressed))
+	}
+	code, decompressed, stderr := runCLI(t, []byte(compressed), "decompress", "-")
+	if code != exitOK || decompressed != string(content) {
+		t.Fatalf("decompress from stdin failed with %d: %s", code, stderr)
+	}
+
+	// A password is needed to read an encrypted stream
+	code, encrypted, _ := runCLI(t, content, "compress", "-password", "secret")
+	if code != exitOK {
+		t.Fatalf("compress -password failed with %d", code)
+	}
+	if code, _, stderr := runCLI(t, []byte(encrypted), "decompress"); code != exitError || !strings.Contains(stderr, "-password") {
+		t.Errorf("Expected a password error, got %d: %s", code, stderr)
+	}
+	if code, out, _ := runCLI(t, []byte(encrypted), "decompress", "-password", "secret"); code != exitOK || out != string(content) {
+		t.Errorf("decompress -password failed with %d", code)
+	}
+
+	// The original single-table format is also read from stdin: two one-bit
+	// codes followed by 01010101
+	legacy := []byte{0, 0, 0, 2, 'a', 1, '0', 'b', 1, '1', 0x55}
+	if code, out, stderr := runCLI(t, legacy, "decompress"); code != exitOK || out != "abababab" {
+		t.Errorf("decompress of a legacy stream failed with %d: %q %s", code, out, stderr)
+	}
+	if code, _, _ := runCLI(t, []byte("not compressed"), "decompress"); code != exitError {
+		t.Errorf("Expected an error for a stream in neither format, got %d", code)
+	}
+}
+
+func TestCLIExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.txt")
+	writeFile(t, plain, []byte("not compressed"))
+	corrupt := filepath.Join(dir, "corrupt.huff")
+	writeFile(t, corrupt, []byte("HUFC garbage"))
+
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"help", []string{"help"}, exitOK},
+		{"command help", []string{"compress", "-h"}, exitOK},
+		{"unknown command", []string{"zip"}, exitUsage},
+		{"unknown flag", []string{"compress", "-x"}, exitUsage},
+		{"unknown method", []string{"compress", "-m", "zip", plain}, exitUsage},
+		{"bad parity", []string{"compress", "-parity", "99", plain}, exitUsage},
+		{"-o with two inputs", []string{"compress", "-o", "out", plain, plain}, exitUsage},
+		{"missing file", []string{"compress", filepath.Join(dir, "missing")}, exitError},
+		{"wrong extension", []string{"decompress", plain}, exitError},
+		{"not a container", []string{"decompress", "-k", corrupt}, exitError},
+		{"verify not a container", []string{"verify", corrupt}, exitError},
+		{"repair without file", []string{"repair"}, exitUsage},
+	}
+	for _, c := range cases {
+		if code, _, stderr := runCLI(t, nil, c.args...); code != c.code {
+			t.Errorf("%s: expected exit code %d, got %d: %s", c.name, c.code, code, stderr)
+		}
+	}
+	if !exists(corrupt) || exists(filepath.Join(dir, "corrupt")) {
+		t.Error("A failed decompression must keep the input and leave no output")
+	}
+}
+
+func TestCLIKeygenAndSigning(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "signer")
+	if code, _, stderr := runCLI(t, nil, "keygen", prefix); code != exitOK {
+		t.Fatalf("keygen failed with %d: %s", code, stderr)
+	}
+	if code, _, _ := runCLI(t, nil, "keygen", prefix); code != exitError {
+		t.Error("keygen must not overwrite an existing key")
+	}
+
+	content := []byte(strings.Repeat("signed release ", 1000))
+	code, signed, _ := runCLI(t, content, "compress", "-signkey", prefix+".key")
+	if code != exitOK {
+		t.Fatalf("compress -signkey failed with %d", code)
+	}
+	if code, out, _ := runCLI(t, []byte(signed), "decompress", "-pubkey", prefix+".pub"); code != exitOK || out != string(content) {
+		t.Errorf("decompress -pubkey failed with %d", code)
+	}
+	if code, stdout, _ := runCLI(t, []byte(signed), "verify", "-pubkey", prefix+".pub"); code != exitOK || !strings.Contains(stdout, "firmado") {
+		t.Errorf("verify -pubkey failed with %d: %s", code, stdout)
+	}
+
+	_, unsigned, _ := runCLI(t, content, "compress")
+	if code, _, stderr := runCLI(t, []byte(unsigned), "decompress", "-pubkey", prefix+".pub"); code != exitError || !strings.Contains(stderr, "firmado") {
+		t.Errorf("Expected an unsigned error, got %d: %s", code, stderr)
+	}
+}
+
+func TestCLIInspect(t *testing.T) {
+	content := bytes.Repeat([]byte("inspect from the terminal "), 2000)
+	_, compressed, _ := runCLI(t, content, "compress", "-b", "4096", "-password", "secret")
+
+	code, stdout, stderr := runCLI(t, []byte(compressed), "inspect", "-password", "secret", "-blocks")
+	if code != exitOK {
+		t.Fatalf("inspect failed with %d: %s", code, stderr)
+	}
+	for _, want := range []string{"contenedor v", "cifrado", "tabla de códigos", "'i'", "offset"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the report:\n%s", want, stdout)
+		}
+	}
+	if _, stdout, _ := runCLI(t, []byte(compressed), "inspect"); !strings.Contains(stdout, "-password") {
+		t.Errorf("Expected a hint about the password:\n%s", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, []byte(compressed), "inspect", "-json")
+	var report huffman.InspectReport
+	if err := json.Unmarshal([]byte(stdout), &report); code != exitOK || err != nil {
+		t.Fatalf("inspect -json failed with %d: %v", code, err)
+	}
+	if report.OriginalSize != int64(len(content)) || report.CompressedSize != int64(len(compressed)) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	if code, _, _ := runCLI(t, []byte("not compressed"), "inspect"); code != exitError {
+		t.Errorf("Expected an error for a plain file, got %d", code)
+	}
+}
+
+func TestCLITable(t *testing.T) {
+	code, stdout, stderr := runCLI(t, []byte("abracadabra"), "table")
+	if code != exitOK {
+		t.Fatalf("table failed with %d: %s", code, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 6 || !strings.HasPrefix(lines[1], "97,5,") {
+		t.Errorf("Unexpected frequency table:\n%s", stdout)
+	}
+
+	// The exported table builds the same codes as the input
+	table := filepath.Join(t.TempDir(), "table.json")
+	if code, _, _ := runCLI(t, []byte("abracadabra"), "table", "-format", "json", "-o", table); code != exitOK {
+		t.Fatalf("table -o failed with %d", code)
+	}
+	_, fromInput, _ := runCLI(t, []byte("abracadabra"), "table", "-codes")
+	code, fromTable, stderr := runCLI(t, nil, "table", "-from", table)
+	if code != exitOK || fromTable != fromInput || !strings.HasPrefix(fromTable, "symbol,frequency,length,code\n97,5,1,") {
+		t.Errorf("Codes from the table differ from the codes of the input (%s):\n%s\n%s", stderr, fromTable, fromInput)
+	}
+
+	code, _, stderr = runCLI(t, []byte("symbol,probability\n97,0.5\n"), "table", "-from", "-")
+	if code != exitError || !strings.Contains(stderr, "add up") {
+		t.Errorf("Expected exit code %d for probabilities that do not add up, got %d: %s", exitError, code, stderr)
+	}
+	for _, bad := range [][]string{{"-format", "xml"}, {"a.txt", "b.txt"}, {"-from", table, "a.txt"}} {
+		if code, _, _ := runCLI(t, nil, append([]string{"table"}, bad...)...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", bad, exitUsage, code)
+		}
+	}
+}
+
+func TestCLIBench(t *testing.T) {
+	args := []string{"bench", "-size", "4096", "-samples", "text,random", "-codecs", "huffman,auto", "-time", "1ns"}
+	code, stdout, stderr := runCLI(t, nil, append(args, "-format", "csv")...)
+	if code != exitOK {
+		t.Fatalf("bench failed with %d: %s", code, stderr)
+	}
+	// Header, 2 samples by 2 codecs and a total per codec
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 1+4+2 || !strings.HasPrefix(lines[1], "text,huffman,4096,") {
+		t.Errorf("Unexpected csv:\n%s", stdout)
+	}
+
+	output := filepath.Join(t.TempDir(), "report.md")
+	if code, _, _ := runCLI(t, nil, append(args, "-o", output)...); code != exitOK {
+		t.Fatalf("bench -o failed with %d", code)
+	}
+	if data, _ := os.ReadFile(output); !strings.Contains(string(data), "| random | auto |") {
+		t.Errorf("Unexpected markdown report:\n%s", data)
+	}
+
+	for _, bad := range [][]string{{"-format", "xml"}, {"-samples", "nope"}, {"-codecs", "zip"}, {"file.txt"}} {
+		if code, _, _ := runCLI(t, nil, append([]string{"bench"}, bad...)...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", bad, exitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Un Codec es un método de compresión que se aplica a cada bloque del contenedor.
+// Cada bloque guarda el ID del codec con el que fue codificado, de modo que al
+// descomprimir se elige el decodificador correcto bloque por bloque.
+type Codec interface {
+	// ID identifica al codec dentro del contenedor. Debe ser único.
+	ID() byte
+	// Name es el nombre con el que se selecciona el codec (por ejemplo en /compress).
+	Name() string
+	// Encode comprime un bloque completo.
+	Encode(src []byte) ([]byte, error)
+	// Decode descomprime un bloque sabiendo que el original tenía rawLen bytes.
+	Decode(src []byte, rawLen int) ([]byte, error)
+}
+
+// CodecAuto selecciona, para cada bloque, el codec registrado que produce la salida más pequeña.
+const CodecAuto = "auto"
+
+// Identificadores de los codecs incluidos en el paquete.
+const (
+	methodStored     byte = 0
+	methodHuffman    byte = 1
+	methodRLE        byte = 2
+	methodRLEHuffman byte = 3
+)
+
+var (
+	errCorruptBlock = errors.New("huffman: corrupt block")
+	codecsByID      = make(map[byte]Codec)
+	codecsByName    = make(map[string]Codec)
+)
+
+func init() {
+	RegisterCodec(storedCodec{})
+	RegisterCodec(huffmanCodec{})
+	RegisterCodec(rleCodec{})
+	RegisterCodec(rleHuffmanCodec{})
+}
+
+// RegisterCodec agrega un codec al registro. Entra automáticamente en la
+// selección del modo auto. Entra en pánico si el ID o el nombre ya existen o
+// si el ID está reservado para los registros del contenedor.
+func RegisterCodec(c Codec) {
+	if c.ID() >= methodSignature {
+		panic(fmt.Sprintf("huffman: codec id %d is reserved", c.ID()))
+	}
+	if _, dup := codecsByID[c.ID()]; dup {
+		panic(fmt.Sprintf("huffman: codec id %d registered twice", c.ID()))
+	}
+	if _, dup := codecsByName[c.Name()]; dup || c.Name() == CodecAuto {
+		panic("huffman: codec name registered twice: " + c.Name())
+	}
+	codecsByID[c.ID()] = c
+	codecsByName[c.Name()] = c
+}
+
+// Codecs devuelve los codecs registrados ordenados por ID.
+func Codecs() []Codec {
+	list := make([]Codec, 0, len(codecsByID))
+	for _, c := range codecsByID {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID() < list[j].ID() })
+	return list
+}
+
+// CodecByName busca un codec registrado por su nombre.
+func CodecByName(name string) (Codec, bool) {
+	c, ok := codecsByName[name]
+	return c, ok
+}
+
+// treeEncoder lo implementan los codecs que construyen un árbol de Huffman,
+// para recibir Options.MinVariance, que no pasa por Codec.Encode.
+type treeEncoder interface {
+	encodeTree(src []byte, minVariance bool) ([]byte, error)
+}
+
+// encodeWith codifica src con c, con el desempate de varianza mínima si c lo admite.
+func encodeWith(c Codec, src []byte, minVariance bool) ([]byte, error) {
+	if t, ok := c.(treeEncoder); ok {
+		return t.encodeTree(src, minVariance)
+	}
+	return c.Encode(src)
+}
+
+// storedCodec guarda el bloque sin comprimir.
+type storedCodec struct{}
+
+func (storedCodec) ID() byte     { return methodStored }
+func (storedCodec) Name() string { return "stored" }
+
+func (storedCodec) Encode(src []byte) ([]byte, error) {
+	return append([]byte(nil), src...), nil
+}
+
+func (storedCodec) Decode(src []byte, rawLen int) ([]byte, error) {
+	if len(src) != rawLen {
+		return nil, errCorruptBlock
+	}
+	return append([]byte(nil), src...), nil
+}
+
+// huffmanCodec guarda la tabla de códigos seguida de los bits codificados.
+type huffmanCodec struct{}
+
+func (huffmanCodec) ID() byte     { return methodHuffman }
+func (huffmanCodec) Name() string { return "huffman" }
+
+func (c huffmanCodec) Encode(src []byte) ([]byte, error) {
+	return c.encodeTree(src, false)
+}
+
+func (huffmanCodec) encodeTree(src []byte, minVariance bool) ([]byte, error) {
+	return huffmanEncode(src, generateCodes(buildTree(countFrequencies(src), minVariance, nil)))
+}
+
+// huffmanEncode escribe la tabla de códigos seguida de src codificado.
+func huffmanEncode(src []byte, codes map[byte]string) ([]byte, error) {
+	var out bytes.Buffer
+	if err := writeCodeTable(&out, codes); err != nil {
+		return nil, err
+	}
+	out.Write(encode(src, codes))
+	return out.Bytes(), nil
+}
+
+func (huffmanCodec) Decode(src []byte, rawLen int) ([]byte, error) {
+	r := bytes.NewReader(src)
+	codes, err := readCodeTable(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeN(src[len(src)-r.Len():], codes, rawLen)
+}
+
+// rleCodec implementa run-length encoding al estilo PackBits: un byte de control
+// n en [0, 127] va seguido de n+1 literales; n en [129, 255] repite el byte
+// siguiente 257-n veces.
+type rleCodec struct{}
+
+func (rleCodec) ID() byte     { return methodRLE }
+func (rleCodec) Name() string { return "rle" }
+
+func (rleCodec) Encode(src []byte) ([]byte, error) {
+	return rleEncode(src), nil
+}
+
+func (rleCodec) Decode(src []byte, rawLen int) ([]byte, error) {
+	return rleDecode(src, rawLen)
+}
+
+// rleHuffmanCodec aplica RLE y luego Huffman sobre el resultado. Antes de los
+// datos Huffman se guarda la longitud intermedia del RLE.
+type rleHuffmanCodec struct{}
+
+func (rleHuffmanCodec) ID() byte     { return methodRLEHuffman }
+func (rleHuffmanCodec) Name() string { return "rle-huffman" }
+
+func (c rleHuffmanCodec) Encode(src []byte) ([]byte, error) {
+	return c.encodeTree(src, false)
+}
+
+func (rleHuffmanCodec) encodeTree(src []byte, minVariance bool) ([]byte, error) {
+	runs := rleEncode(src)
+	payload, err := huffmanCodec{}.encodeTree(runs, minVariance)
+	if err != nil {
+		return nil, err
+	}
+	out := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(payload)), uint32(len(runs)))
+	return append(out, payload...), nil
+}
+
+func (rleHuffmanCodec) Decode(src []byte, rawLen int) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, errCorruptBlock
+	}
+	runsLen := binary.BigEndian.Uint32(src)
+	// Cada byte de control produce al menos un byte, así que el RLE nunca ocupa
+	// más del doble del original.
+	if uint64(runsLen) > 2*uint64(rawLen)+1 {
+		return nil, errCorruptBlock
+	}
+	runs, err := huffmanCodec{}.Decode(src[4:], int(runsLen))
+	if err != nil {
+		return nil, err
+	}
+	return rleDecode(runs, rawLen)
+}
+
+func rleEncode(src []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		// Medir la corrida que empieza en i
+		run := 1
+		for i+run < len(src) && run < 128 && src[i+run] == src[i] {
+			run++
+		}
+		if run >= 2 {
+			out.WriteByte(byte(257 - run))
+			out.WriteByte(src[i])
+			i += run
+			continue
+		}
+
+		// Acumular literales hasta encontrar una corrida de al menos 2
+		start := i
+		for i < len(src) && i-start < 128 {
+			if i+1 < len(src) && src[i+1] == src[i] {
+				break
+			}
+			i++
+		}
+		out.WriteByte(byte(i - start - 1))
+		out.Write(src[start:i])
+	}
+	return out.Bytes()
+}
+
+func rleDecode(src []byte, rawLen int) ([]byte, error) {
+	out := make([]byte, 0, rawLen)
+	for i := 0; i < len(src); {
+		n := int(src[i])
+		i++
+		switch {
+		case n < 128:
+			if i+n+1 > len(src) || len(out)+n+1 > rawLen {
+				return nil, errCorruptBlock
+			}
+			out = append(out, src[i:i+n+1]...)
+			i += n + 1
+		case n > 128:
+			count := 257 - n
+			if i >= len(src) || len(out)+count > rawLen {
+				return nil, errCorruptBlock
+			}
+			for j := 0; j < count; j++ {
+				out = append(out, src[i])
+			}
+			i++
+		}
+	}
+	if len(out) != rawLen {
+		return nil, errCorruptBlock
+	}
+	return out, nil
+}
@@ -0,0 +1,777 @@
+package huffman
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Formato del contenedor .huff:
+//
+//	magic     [4]byte  "HUFC"
+//	version   uint8
+//	flags     uint8    (flagParity, flagEncrypted, flagSigned)
+//	blockSize uint32
+//	[parámetros de cifrado si flagEncrypted, ver crypto.go]
+//	bloques:  method uint8 | rawLen uint32 | payloadLen uint32 |
+//	          rawCRC uint32 | payloadCRC uint32 | headerCRC uint32 | payload
+//	[registro de firma si flagSigned, ver signature.go]
+//	fin:      method = methodEnd
+//	[etiqueta del fin si flagEncrypted, ver crypto.go]
+//
+// Con flagParity los bloques se agrupan y cada grupo va precedido por un
+// registro de paridad Reed-Solomon (ver parity.go). Con flagEncrypted cada
+// payload va cifrado con AES-256-GCM (ver crypto.go).
+//
+// Los CRC son CRC32C: rawCRC cubre los datos descomprimidos, payloadCRC los
+// comprimidos y headerCRC los 17 bytes anteriores del encabezado del bloque,
+// para saber si las longitudes son confiables y se puede saltar el bloque.
+// La versión 1 no tenía los tres CRC; se sigue pudiendo leer.
+//
+// Los archivos antiguos (sin magic) empiezan con el número de códigos en un
+// uint32, que nunca supera 256, así que los dos formatos no se confunden.
+
+const (
+	containerVersion = 2
+	methodEnd        = 0xFF
+
+	flagParity = 1 << 0
+
+	containerHeaderLen = 10
+	frameHeaderLenV1   = 9
+	frameHeaderLen     = 21
+
+	// DefaultBlockSize es el tamaño de bloque usado cuando Options.BlockSize es 0.
+	DefaultBlockSize = 64 << 10
+	// MaxBlockSize limita el tamaño de bloque para acotar la memoria al decodificar.
+	MaxBlockSize = 16 << 20
+)
+
+// Magic son los cuatro primeros bytes de un contenedor. Los archivos que no
+// empiezan por ellos usan el formato original, que se lee con ReadLegacy.
+const Magic = "HUFC"
+
+var containerMagic = [4]byte([]byte(Magic))
+
+var (
+	// ErrFormat indica que la entrada no es un contenedor .huff válido.
+	ErrFormat = errors.New("huffman: invalid container")
+	// ErrUnknownCodec indica que se pidió o se encontró un codec no registrado.
+	ErrUnknownCodec = errors.New("huffman: unknown codec")
+	// ErrChecksum indica que un CRC del bloque no coincide con su contenido.
+	ErrChecksum = errors.New("huffman: checksum mismatch")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Options configura la compresión y la descompresión. El valor cero usa
+// Huffman con bloques de DefaultBlockSize y falla ante el primer bloque dañado.
+type Options struct {
+	// Codec es el nombre de un codec registrado o CodecAuto. Vacío equivale a "huffman".
+	Codec string
+	// BlockSize es el tamaño de cada bloque en bytes.
+	BlockSize int
+	// Parity es el número de fragmentos de paridad Reed-Solomon por grupo de
+	// bloques (entre 0 y MaxParity). Permite reconstruir hasta Parity bloques
+	// dañados de cada grupo al descomprimir. 0 desactiva la paridad.
+	Parity int
+	// Password cifra la salida al comprimir y es obligatoria para leer un
+	// contenedor cifrado. Vacía significa sin cifrado.
+	Password string
+	// SigningKey firma el contenedor al comprimir.
+	SigningKey ed25519.PrivateKey
+	// VerifyKey exige al descomprimir que el contenedor esté firmado con esta clave.
+	// Sin VerifyKey la firma de un contenedor firmado se verifica igual, con la clave que trae.
+	VerifyKey ed25519.PublicKey
+	// Progress, si no es nil, recibe el avance en cada cambio de fase y al
+	// terminar cada bloque. Se llama desde la goroutine que escribe o lee.
+	Progress func(Progress)
+	// MaxOutput limita los bytes descomprimidos. El Reader devuelve un
+	// *LimitError antes de decodificar el bloque que lo superaría. 0 es sin límite.
+	MaxOutput int64
+	// MaxRatio limita la razón entre los bytes descomprimidos y los bytes del
+	// contenedor leídos, para cortar bombas de descompresión. Se comprueba a
+	// partir del primer MiB de salida. 0 es sin límite.
+	MaxRatio float64
+	// MinVariance construye los árboles de Huffman desempatando con el subárbol
+	// menos profundo primero: la longitud media no cambia y la varianza de las
+	// longitudes de código es mínima. Solo afecta al Writer; la tabla de
+	// códigos viaja en cada bloque, así que los lectores no necesitan saberlo.
+	MinVariance bool
+	// SkipCorrupt hace que el Reader descarte los bloques dañados y siga con el
+	// siguiente en lugar de devolver error. Los bloques descartados se consultan con Reader.Skipped.
+	SkipCorrupt bool
+}
+
+// BlockError describe un bloque dañado del contenedor.
+type BlockError struct {
+	Index  int   // posición del bloque, empezando en 0
+	Offset int64 // desplazamiento del encabezado del bloque en el archivo
+	Err    error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("huffman: block %d at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *BlockError) Unwrap() error { return e.Err }
+
+func (o Options) codec() (Codec, error) {
+	switch o.Codec {
+	case "":
+		return huffmanCodec{}, nil
+	case CodecAuto:
+		return nil, nil
+	}
+	c, ok := CodecByName(o.Codec)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, o.Codec)
+	}
+	return c, nil
+}
+
+func (o Options) blockSize() (int, error) {
+	if o.BlockSize == 0 {
+		return DefaultBlockSize, nil
+	}
+	if o.BlockSize < 0 || o.BlockSize > MaxBlockSize {
+		return 0, fmt.Errorf("huffman: block size %d out of range", o.BlockSize)
+	}
+	return o.BlockSize, nil
+}
+
+func (o Options) parity() (int, error) {
+	if o.Parity < 0 || o.Parity > MaxParity {
+		return 0, fmt.Errorf("huffman: parity %d out of range [0, %d]", o.Parity, MaxParity)
+	}
+	return o.Parity, nil
+}
+
+// Writer comprime lo que se le escribe y lo guarda en w como contenedor .huff.
+// Hay que llamar a Close para vaciar el último bloque.
+type Writer struct {
+	ctx       context.Context
+	w         io.Writer
+	codec     Codec // nil en modo auto
+	blockSize int
+	parity    int
+	minVar    bool     // Options.MinVariance
+	group     [][]byte // bloques codificados del grupo de paridad actual
+	header    []byte   // encabezado del contenedor; se genera al escribirlo si es nil
+	cipher    *blockCipher
+	blocks    int // bloques escritos, usado como nonce
+	signer    ed25519.PrivateKey
+	digest    hash.Hash // SHA-256 de lo escrito, si se firma
+	trailer   []byte    // registro que va antes del marcador de fin
+	endTag    []byte    // etiqueta que va después del marcador de fin, si se cifra
+	progress  func(Progress)
+	counter   *countingWriter
+	read      int64 // bytes originales ya codificados
+	buf       []byte
+	wroteHdr  bool
+	closed    bool
+	err       error
+}
+
+// NewWriter crea un Writer que escribe en w con las opciones dadas.
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	return NewWriterContext(context.Background(), w, opts)
+}
+
+// NewWriterContext es como NewWriter pero deja de comprimir cuando se cancela
+// ctx: Write y Close devuelven ctx.Err() en el siguiente límite de bloque.
+func NewWriterContext(ctx context.Context, w io.Writer, opts Options) (*Writer, error) {
+	codec, err := opts.codec()
+	if err != nil {
+		return nil, err
+	}
+	blockSize, err := opts.blockSize()
+	if err != nil {
+		return nil, err
+	}
+	parity, err := opts.parity()
+	if err != nil {
+		return nil, err
+	}
+	z := &Writer{ctx: ctx, codec: codec, blockSize: blockSize, parity: parity, minVar: opts.MinVariance, progress: opts.Progress}
+	z.counter = &countingWriter{w: w}
+	z.w = z.counter
+	if opts.SigningKey != nil {
+		if len(opts.SigningKey) != ed25519.PrivateKeySize {
+			return nil, errors.New("huffman: invalid signing key")
+		}
+		z.signer = opts.SigningKey
+		z.digest = sha256.New()
+		z.w = io.MultiWriter(z.counter, z.digest)
+	}
+
+	// La clave se deriva una sola vez por archivo, con una sal nueva.
+	if opts.Password != "" {
+		params, err := newEncryptParams()
+		if err != nil {
+			return nil, err
+		}
+		header := append(z.baseHeader(flagEncrypted), params...)
+		z.cipher, err = newBlockCipher(opts.Password, header)
+		if err != nil {
+			return nil, err
+		}
+		z.header = append(header, z.cipher.keyCheck()...)
+	}
+	return z, nil
+}
+
+// Write acumula p y comprime cada bloque completo.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errors.New("huffman: write to closed Writer")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		take := min(z.blockSize-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:take]...)
+		p = p[take:]
+		if len(z.buf) == z.blockSize {
+			if err := z.flushBlock(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close comprime el bloque pendiente y escribe el marcador de fin. No cierra w.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+	if len(z.buf) > 0 {
+		if err := z.flushBlock(); err != nil {
+			return err
+		}
+	}
+	if len(z.group) > 0 {
+		if err := z.writeGroup(); err != nil {
+			return err
+		}
+	}
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if z.signer != nil {
+		z.trailer = signatureRecord(z.signer, z.digest.Sum(nil))
+	}
+	if z.cipher != nil {
+		z.endTag = z.cipher.endTag(z.blocks)
+	}
+	if _, z.err = z.w.Write(append(append(z.trailer, methodEnd), z.endTag...)); z.err != nil {
+		return z.err
+	}
+	z.report(PhaseDone)
+	return nil
+}
+
+func (z *Writer) writeHeader() error {
+	if z.wroteHdr {
+		return nil
+	}
+	z.wroteHdr = true
+	if z.header == nil {
+		z.header = z.baseHeader(0)
+	}
+	_, z.err = z.w.Write(z.header)
+	return z.err
+}
+
+// baseHeader arma los primeros containerHeaderLen bytes del encabezado.
+func (z *Writer) baseHeader(flags byte) []byte {
+	hdr := make([]byte, 0, containerHeaderLen+encryptHeaderLen)
+	hdr = append(hdr, containerMagic[:]...)
+	if z.parity > 0 {
+		flags |= flagParity
+	}
+	if z.signer != nil {
+		flags |= flagSigned
+	}
+	hdr = append(hdr, containerVersion, flags)
+	return binary.BigEndian.AppendUint32(hdr, uint32(z.blockSize))
+}
+
+func (z *Writer) flushBlock() error {
+	if err := z.ctx.Err(); err != nil {
+		z.err = err
+		return err
+	}
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	method, payload, err := z.encodeBlock(z.buf)
+	if err != nil {
+		z.err = err
+		return err
+	}
+	var rawCRC uint32
+	if z.cipher != nil {
+		payload = z.cipher.seal(z.blocks, method, len(z.buf), payload)
+	} else {
+		rawCRC = crc32.Checksum(z.buf, castagnoli)
+	}
+	z.blocks++
+
+	frame := make([]byte, 0, frameHeaderLen+len(payload))
+	frame = append(frame, method)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(z.buf)))
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = binary.BigEndian.AppendUint32(frame, rawCRC)
+	frame = binary.BigEndian.AppendUint32(frame, crc32.Checksum(payload, castagnoli))
+	frame = binary.BigEndian.AppendUint32(frame, crc32.Checksum(frame, castagnoli))
+	frame = append(frame, payload...)
+	if err := z.writeFrame(frame); err != nil {
+		return err
+	}
+	z.read += int64(len(z.buf))
+	z.buf = z.buf[:0]
+	z.report(PhaseEncoding)
+	return nil
+}
+
+// writeFrame escribe un bloque ya codificado o, con paridad, lo agrega al grupo actual.
+func (z *Writer) writeFrame(frame []byte) error {
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if z.parity == 0 {
+		_, z.err = z.w.Write(frame)
+		return z.err
+	}
+	z.group = append(z.group, frame)
+	if len(z.group) == parityGroupBlocks {
+		return z.writeGroup()
+	}
+	return nil
+}
+
+// encodeBlock codifica data con el codec elegido. Si el resultado no es más
+// pequeño que data, el bloque se guarda sin comprimir, de modo que ningún bloque
+// crece más que su encabezado. Para Huffman la entropía permite descartar los
+// bloques incompresibles (JPEG, ZIP, datos aleatorios) sin llegar a codificarlos.
+// En modo auto se prueban todos los codecs registrados y gana el más pequeño.
+func (z *Writer) encodeBlock(data []byte) (byte, []byte, error) {
+	if z.codec != nil && z.codec.ID() == methodHuffman {
+		z.report(PhaseCounting)
+		frequencies := countFrequencies(data)
+		if !huffmanWorthwhile(frequencies, len(data)) {
+			return methodStored, data, nil
+		}
+		z.report(PhaseBuildingTree)
+		codes := generateCodes(buildTree(frequencies, z.minVar, nil))
+		z.report(PhaseEncoding)
+		payload, err := huffmanEncode(data, codes)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(payload) >= len(data) {
+			return methodStored, data, nil
+		}
+		return methodHuffman, payload, nil
+	}
+	z.report(PhaseEncoding)
+	if z.codec != nil {
+		payload, err := encodeWith(z.codec, data, z.minVar)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(payload) >= len(data) {
+			return methodStored, data, nil
+		}
+		return z.codec.ID(), payload, nil
+	}
+
+	bestID, best := methodStored, data
+	for _, c := range Codecs() {
+		if c.ID() == methodStored {
+			continue
+		}
+		payload, err := encodeWith(c, data, z.minVar)
+		if err != nil {
+			continue
+		}
+		if len(payload) < len(best) {
+			bestID, best = c.ID(), payload
+		}
+	}
+	return bestID, best, nil
+}
+
+// Reader descomprime un contenedor .huff leído de r.
+type Reader struct {
+	ctx         context.Context
+	r           *hashReader
+	mode        readerMode
+	version     byte
+	flags       byte
+	header      []byte // encabezado completo tal como se leyó
+	cipher      *blockCipher
+	blockSize   int
+	skipCorrupt bool
+	index       int           // índice del próximo bloque
+	offset      int64         // bytes consumidos de r
+	queue       []*blockFrame // bloques ya leídos del grupo de paridad actual
+	parity      int           // fragmentos de paridad del último grupo leído
+	verifyKey   ed25519.PublicKey
+	signature   []byte            // registro de firma leído
+	endTag      []byte            // etiqueta del fin leída, si está cifrado
+	signer      ed25519.PublicKey // firmante verificado
+	progress    func(Progress)
+	limits      Options // solo MaxOutput y MaxRatio
+	written     int64   // bytes descomprimidos entregados
+	pending     []byte
+	skipped     []*BlockError
+	err         error
+}
+
+// readerMode ajusta newReader para las herramientas que recorren el
+// contenedor sin decodificarlo.
+type readerMode struct {
+	allowLocked   bool // abrir un contenedor cifrado sin contraseña (Verify, Repair)
+	skipSignature bool // no comprobar la firma; Repair la copia tal cual
+}
+
+// blockFrame es un bloque leído del contenedor, todavía sin decodificar.
+type blockFrame struct {
+	index      int
+	offset     int64
+	method     byte
+	rawLen     int
+	hasCRC     bool // la versión 1 no guarda CRC
+	rawCRC     uint32
+	payloadCRC uint32
+	raw        []byte // bloque completo: encabezado y payload
+	payload    []byte
+	err        error // daño que la paridad no pudo reparar
+	repaired   error // daño reparado con la paridad
+}
+
+// NewReader lee el encabezado del contenedor y devuelve un Reader listo para
+// leer. Si el contenedor está cifrado hace falta Options.Password; devuelve
+// ErrPasswordRequired o ErrWrongPassword antes de leer cualquier bloque. Si el
+// contenedor está firmado, la firma se verifica al llegar al final y Read
+// devuelve un error que envuelve ErrSignature si no es válida.
+func NewReader(r io.Reader, opts Options) (*Reader, error) {
+	return NewReaderContext(context.Background(), r, opts)
+}
+
+// NewReaderContext es como NewReader pero Read devuelve ctx.Err() en el
+// siguiente límite de bloque una vez cancelado ctx.
+func NewReaderContext(ctx context.Context, r io.Reader, opts Options) (*Reader, error) {
+	z, err := newReader(r, opts, readerMode{})
+	if err != nil {
+		return nil, err
+	}
+	z.ctx = ctx
+	return z, nil
+}
+
+func newReader(r io.Reader, opts Options, mode readerMode) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var hdr [containerHeaderLen]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+	if [4]byte(hdr[:4]) != containerMagic {
+		return nil, ErrFormat
+	}
+	if hdr[4] == 0 || hdr[4] > containerVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, hdr[4])
+	}
+	if hdr[5]&^(flagParity|flagEncrypted|flagSigned) != 0 {
+		return nil, fmt.Errorf("%w: unsupported flags %#x", ErrFormat, hdr[5])
+	}
+	blockSize := binary.BigEndian.Uint32(hdr[6:])
+	if blockSize == 0 || blockSize > MaxBlockSize {
+		return nil, fmt.Errorf("%w: block size %d", ErrFormat, blockSize)
+	}
+	if opts.VerifyKey != nil && hdr[5]&flagSigned == 0 {
+		return nil, ErrNotSigned
+	}
+	z := &Reader{
+		ctx:         context.Background(),
+		r:           &hashReader{r: br},
+		mode:        mode,
+		verifyKey:   opts.VerifyKey,
+		version:     hdr[4],
+		flags:       hdr[5],
+		header:      hdr[:],
+		blockSize:   int(blockSize),
+		skipCorrupt: opts.SkipCorrupt,
+		progress:    opts.Progress,
+		limits:      Options{MaxOutput: opts.MaxOutput, MaxRatio: opts.MaxRatio},
+		offset:      containerHeaderLen,
+	}
+
+	if z.flags&flagEncrypted != 0 {
+		ext := make([]byte, encryptHeaderLen)
+		if _, err := io.ReadFull(br, ext); err != nil {
+			return nil, ErrFormat
+		}
+		z.header = append(z.header, ext...)
+		z.offset += encryptHeaderLen
+		if opts.Password == "" {
+			if !mode.allowLocked {
+				return nil, ErrPasswordRequired
+			}
+			return z, nil
+		}
+		var err error
+		keyed := z.header[:len(z.header)-16]
+		if z.cipher, err = newBlockCipher(opts.Password, keyed); err != nil {
+			return nil, err
+		}
+		if err := z.cipher.verifyKey(z.header[len(keyed):]); err != nil {
+			return nil, err
+		}
+	}
+
+	// El digest de la firma empieza con el encabezado ya leído.
+	if z.flags&flagSigned != 0 {
+		z.r.h = sha256.New()
+		z.r.h.Write(z.header)
+	}
+	return z, nil
+}
+
+// Read devuelve los datos descomprimidos.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.pending) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.pending, z.err = z.nextBlock()
+	}
+	n := copy(p, z.pending)
+	z.pending = z.pending[n:]
+	return n, nil
+}
+
+// Skipped devuelve los bloques descartados por estar dañados cuando se usa Options.SkipCorrupt.
+func (z *Reader) Skipped() []*BlockError {
+	return z.skipped
+}
+
+// nextBlock lee y decodifica el siguiente bloque. Devuelve io.EOF al llegar al marcador de fin.
+func (z *Reader) nextBlock() ([]byte, error) {
+	for {
+		if err := z.ctx.Err(); err != nil {
+			return nil, err
+		}
+		frame, err := z.readFrame()
+		if err == io.EOF {
+			z.report(PhaseDone)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Los límites se comprueban con la longitud declarada, sin decodificar
+		if err := checkLimits(z.written+int64(frame.rawLen), z.offset, z.limits); err != nil {
+			return nil, err
+		}
+		data, err := z.decodeFrame(frame)
+		if err == nil {
+			z.written += int64(len(data))
+			z.report(PhaseDecoding)
+			return data, nil
+		}
+		blockErr := &BlockError{Index: frame.index, Offset: frame.offset, Err: err}
+		if !z.skipCorrupt {
+			return nil, blockErr
+		}
+		z.skipped = append(z.skipped, blockErr)
+	}
+}
+
+// readFrame lee el siguiente bloque sin decodificarlo. Si el encabezado del
+// bloque está dañado las longitudes no son confiables y no se puede seguir
+// leyendo, así que el error se devuelve aunque se use SkipCorrupt. Con paridad
+// las longitudes vienen en el registro del grupo y esto no ocurre.
+func (z *Reader) readFrame() (*blockFrame, error) {
+	if len(z.queue) > 0 {
+		frame := z.queue[0]
+		z.queue = z.queue[1:]
+		return frame, nil
+	}
+
+	peek, err := z.r.Peek(1)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch {
+	case peek[0] == methodEnd:
+		z.r.ReadByte()
+		z.offset++
+		if z.flags&flagSigned != 0 && z.signature == nil {
+			return nil, fmt.Errorf("%w: signature record missing", ErrSignature)
+		}
+		if z.flags&flagEncrypted != 0 {
+			if err := z.readEndTag(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, io.EOF
+	case peek[0] == methodParity && z.flags&flagParity != 0:
+		z.r.ReadByte()
+		if err := z.readGroup(); err != nil {
+			return nil, err
+		}
+		return z.readFrame()
+	case peek[0] == methodSignature && z.flags&flagSigned != 0 && z.signature == nil:
+		if err := z.readSignature(); err != nil {
+			return nil, err
+		}
+		// La firma cubre todo lo anterior: después solo puede venir el fin
+		next, err := z.r.Peek(1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if next[0] != methodEnd {
+			return nil, fmt.Errorf("%w: data after the signature record", ErrSignature)
+		}
+		return z.readFrame()
+	}
+
+	frame := &blockFrame{index: z.index, offset: z.offset}
+	header := make([]byte, z.frameHeaderLen())
+	if _, err := io.ReadFull(z.r, header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	payloadLen, err := z.parseFrameHeader(header, frame)
+	if err != nil {
+		return nil, &BlockError{Index: frame.index, Offset: frame.offset, Err: err}
+	}
+
+	frame.raw = make([]byte, len(header)+payloadLen)
+	copy(frame.raw, header)
+	if _, err := io.ReadFull(z.r, frame.raw[len(header):]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	frame.payload = frame.raw[len(header):]
+	z.offset += int64(len(frame.raw))
+	z.index++
+	return frame, nil
+}
+
+// readEndTag lee la etiqueta que sigue al marcador de fin de un contenedor
+// cifrado y, con la contraseña, comprueba que no falten ni sobren bloques.
+func (z *Reader) readEndTag() error {
+	tag := make([]byte, endTagLen)
+	if _, err := io.ReadFull(z.r, tag); err != nil {
+		return unexpectedEOF(err)
+	}
+	z.offset += endTagLen
+	z.endTag = tag
+	if z.cipher == nil {
+		return nil
+	}
+	return z.cipher.verifyEnd(z.index, tag)
+}
+
+func (z *Reader) frameHeaderLen() int {
+	if z.version == 1 {
+		return frameHeaderLenV1
+	}
+	return frameHeaderLen
+}
+
+// parseFrameHeader valida el encabezado de un bloque, completa frame y devuelve
+// la longitud del payload.
+func (z *Reader) parseFrameHeader(header []byte, frame *blockFrame) (int, error) {
+	if z.version > 1 {
+		if crc32.Checksum(header[:17], castagnoli) != binary.BigEndian.Uint32(header[17:]) {
+			return 0, fmt.Errorf("%w: block header", ErrChecksum)
+		}
+		frame.hasCRC = true
+		frame.rawCRC = binary.BigEndian.Uint32(header[9:])
+		frame.payloadCRC = binary.BigEndian.Uint32(header[13:])
+	}
+	rawLen := binary.BigEndian.Uint32(header[1:])
+	payloadLen := binary.BigEndian.Uint32(header[5:])
+	if rawLen > uint32(z.blockSize) || payloadLen > uint32(z.maxPayloadLen()) {
+		return 0, errCorruptBlock
+	}
+	frame.method = header[0]
+	frame.rawLen = int(rawLen)
+	return int(payloadLen), nil
+}
+
+// maxPayloadLen acota el payload de un bloque: la tabla de códigos más los bits
+// nunca se acercan al doble del bloque.
+func (z *Reader) maxPayloadLen() int {
+	return 2*z.blockSize + (1 << 16)
+}
+
+// payloadOK indica si el payload coincide con su CRC.
+func (f *blockFrame) payloadOK() bool {
+	return !f.hasCRC || crc32.Checksum(f.payload, castagnoli) == f.payloadCRC
+}
+
+// openFrame comprueba el CRC del payload y lo descifra si hace falta.
+func (z *Reader) openFrame(f *blockFrame) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if !f.payloadOK() {
+		return nil, fmt.Errorf("%w: compressed data", ErrChecksum)
+	}
+	if z.flags&flagEncrypted == 0 {
+		return f.payload, nil
+	}
+	if z.cipher == nil {
+		return nil, ErrPasswordRequired
+	}
+	return z.cipher.open(f.index, f.method, f.rawLen, f.payload)
+}
+
+// decodeFrame comprueba los CRC del bloque, lo descifra si hace falta y lo decodifica con su codec.
+func (z *Reader) decodeFrame(f *blockFrame) ([]byte, error) {
+	payload, err := z.openFrame(f)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, ok := codecsByID[f.method]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCodec, f.method)
+	}
+	data, err := codec.Decode(payload, f.rawLen)
+	if err != nil {
+		return nil, err
+	}
+	// Los bloques cifrados se autentican con GCM en lugar del rawCRC.
+	if f.hasCRC && z.cipher == nil && crc32.Checksum(data, castagnoli) != f.rawCRC {
+		return nil, fmt.Errorf("%w: decompressed data", ErrChecksum)
+	}
+	return data, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,354 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testInputs devuelve entradas con distintas características para las pruebas del contenedor.
+func testInputs() map[string][]byte {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+
+	runs := bytes.Repeat([]byte{'a'}, 50000)
+	runs = append(runs, bytes.Repeat([]byte{'b'}, 30000)...)
+
+	return map[string][]byte{
+		"empty":  {},
+		"single": []byte("x"),
+		"text":   bytes.Repeat([]byte("hello world "), 10000),
+		"runs":   runs,
+		"random": random,
+	}
+}
+
+func roundTrip(t *testing.T, data []byte, opts Options) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw, err := NewWriter(&compressed, opts)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	zr, err := NewReader(bytes.NewReader(compressed.Bytes()), Options{})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Decompression failed: %v", err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		t.Fatalf("Decompressed data does not match original data (%d vs %d bytes)", len(decompressed), len(data))
+	}
+	return compressed.Bytes()
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	methods := []string{CodecAuto}
+	for _, c := range Codecs() {
+		methods = append(methods, c.Name())
+	}
+
+	for name, data := range testInputs() {
+		for _, method := range methods {
+			compressed := roundTrip(t, data, Options{Codec: method, BlockSize: 16 << 10})
+			t.Logf("%s/%s: %d -> %d bytes", name, method, len(data), len(compressed))
+		}
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	// Cada byte aparece las mismas veces, así que todas las frecuencias empatan
+	var data []byte
+	for i := range 64 {
+		for b := range 256 {
+			data = append(data, byte(b+i))
+		}
+	}
+	data = append(data, bytes.Repeat([]byte("equal equal frequencies "), 500)...)
+	_, priv, _ := GenerateKey()
+
+	methods := []string{CodecAuto}
+	for _, c := range Codecs() {
+		methods = append(methods, c.Name())
+	}
+	for _, codec := range methods {
+		opts := Options{Codec: codec, BlockSize: 4096, Parity: 2, SigningKey: priv}
+		want := sha256.Sum256(compressBytes(t, data, opts))
+		for range 10 {
+			if got := sha256.Sum256(compressBytes(t, data, opts)); got != want {
+				t.Fatalf("%s: compressing the same data twice gave %x and %x", codec, want, got)
+			}
+		}
+	}
+}
+
+func TestAutoPicksSmallest(t *testing.T) {
+	for name, data := range testInputs() {
+		roundTrip(t, data, Options{Codec: CodecAuto})
+
+		// Cada bloque se compara con lo que da cada codec sobre ese mismo bloque
+		zw, err := NewWriter(io.Discard, Options{Codec: CodecAuto})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for start := 0; start < len(data); start += DefaultBlockSize {
+			block := data[start:min(start+DefaultBlockSize, len(data))]
+			id, payload, err := zw.encodeBlock(block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range Codecs() {
+				other, err := encodeWith(c, block, false)
+				if err != nil {
+					continue
+				}
+				if c.ID() == id && len(other) != len(payload) {
+					t.Errorf("%s: auto picked %s with %d bytes, the codec alone gives %d", name, codecName(id), len(payload), len(other))
+				}
+				if len(other) < len(payload) {
+					t.Errorf("%s: auto picked %s with %d bytes, %s produced %d", name, codecName(id), len(payload), c.Name(), len(other))
+				}
+			}
+		}
+	}
+}
+
+func TestAutoBoundsExpansion(t *testing.T) {
+	data := testInputs()["random"]
+	blockSize := 8 << 10
+	compressed := roundTrip(t, data, Options{Codec: CodecAuto, BlockSize: blockSize})
+
+	// Encabezado + encabezado de cada bloque + marcador de fin.
+	blocks := (len(data) + blockSize - 1) / blockSize
+	limit := len(data) + containerHeaderLen + frameHeaderLen*blocks + 1
+	if len(compressed) > limit {
+		t.Errorf("Compressed size %d exceeds bound %d", len(compressed), limit)
+	}
+}
+
+func TestUnknownCodec(t *testing.T) {
+	if _, err := NewWriter(io.Discard, Options{Codec: "lzma"}); err == nil {
+		t.Fatal("Expected error for unknown codec")
+	}
+}
+
+func TestDecompressLegacyFormat(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("abracadabra")
+	codes := generateCodes(buildHuffmanTree(countFrequencies(data)))
+
+	legacyFile := filepath.Join(dir, "legacy.huff")
+	if err := saveCompressedFile(legacyFile, encode(data, codes), codes); err != nil {
+		t.Fatalf("Error writing legacy file: %v", err)
+	}
+
+	outputFile := filepath.Join(dir, "legacy.txt")
+	if err := Decompress(legacyFile, outputFile); err != nil {
+		t.Fatalf("Decompression failed: %v", err)
+	}
+	decompressed, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error reading decompressed file: %v", err)
+	}
+	// El formato original no guarda la longitud, así que puede haber símbolos extra por el relleno.
+	if !bytes.HasPrefix(decompressed, data) {
+		t.Errorf("Legacy decompression mismatch: got %q", decompressed)
+	}
+}
+
+// blockMethods recorre los bloques de un contenedor y devuelve el codec de cada uno.
+func blockMethods(t *testing.T, compressed []byte) []byte {
+	t.Helper()
+	var methods []byte
+	pos := containerHeaderLen
+	for compressed[pos] != methodEnd {
+		payloadLen := int(binary.BigEndian.Uint32(compressed[pos+5:]))
+		methods = append(methods, compressed[pos])
+		pos += frameHeaderLen + payloadLen
+	}
+	return methods
+}
+
+func TestStoredFallbackRandomData(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 1<<20)
+	rng.Read(data)
+
+	compressed := roundTrip(t, data, Options{})
+	methods := blockMethods(t, compressed)
+	for i, method := range methods {
+		if method != methodStored {
+			t.Errorf("Block %d of random data used codec %d, expected stored", i, method)
+		}
+	}
+
+	overhead := len(compressed) - len(data)
+	t.Logf("Random data: %d -> %d bytes (%d blocks, %d bytes overhead)", len(data), len(compressed), len(methods), overhead)
+	if overhead > containerHeaderLen+frameHeaderLen*len(methods)+1 {
+		t.Errorf("Expansion of %d bytes exceeds the per-block bound", overhead)
+	}
+
+	// Los datos compresibles siguen usando Huffman.
+	methods = blockMethods(t, roundTrip(t, testInputs()["text"], Options{}))
+	if methods[0] != methodHuffman {
+		t.Errorf("Text block used codec %d, expected huffman", methods[0])
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	cases := []struct {
+		data []byte
+		want float64
+	}{
+		{[]byte{}, 0},
+		{[]byte("aaaa"), 0},
+		{[]byte("abab"), 1},
+		{[]byte("abcd"), 2},
+	}
+	for _, c := range cases {
+		if got := Entropy(countFrequencies(c.data)); got != c.want {
+			t.Errorf("Entropy(%q) = %v, want %v", c.data, got, c.want)
+		}
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	data := bytes.Repeat([]byte("cancel me "), 10000)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Se cancela después del primer bloque: el resto ya no se comprime
+	var compressed bytes.Buffer
+	zw, err := NewWriterContext(ctx, &compressed, Options{BlockSize: 1024})
+	if err != nil {
+		t.Fatalf("NewWriterContext failed: %v", err)
+	}
+	if _, err := zw.Write(data[:1024]); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	cancel()
+	if _, err := zw.Write(data[1024:]); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Write, got %v", err)
+	}
+	if err := zw.Close(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Close, got %v", err)
+	}
+
+	full := roundTrip(t, data, Options{BlockSize: 1024})
+	zr, err := NewReaderContext(ctx, bytes.NewReader(full), Options{})
+	if err != nil {
+		t.Fatalf("NewReaderContext failed: %v", err)
+	}
+	if _, err := io.ReadAll(zr); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Read, got %v", err)
+	}
+
+	// Los archivos a medio escribir se borran
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	os.WriteFile(input, data, 0644)
+	os.WriteFile(filepath.Join(dir, "input.huff"), full, 0644)
+	if err := CompressContext(ctx, input, filepath.Join(dir, "out.huff"), Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from CompressContext, got %v", err)
+	}
+	if err := DecompressContext(ctx, filepath.Join(dir, "input.huff"), filepath.Join(dir, "out.txt"), Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from DecompressContext, got %v", err)
+	}
+	for _, name := range []string{"out.huff", "out.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Partial output %s was not removed", name)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("progress report "), 256) // 4 KiB, 4 bloques
+	var updates []Progress
+	opts := Options{BlockSize: 1024, Progress: func(p Progress) { updates = append(updates, p) }}
+	compressed := roundTrip(t, data, opts)
+
+	var phases []Phase
+	for _, p := range updates {
+		if p.Block == 0 {
+			phases = append(phases, p.Phase)
+		}
+	}
+	want := []Phase{PhaseCounting, PhaseBuildingTree, PhaseEncoding}
+	if len(phases) < len(want) || !slices.Equal(phases[:len(want)], want) {
+		t.Errorf("Unexpected phases for the first block: %v", phases)
+	}
+	last := updates[len(updates)-1]
+	if last.Phase != PhaseDone || last.BytesRead != int64(len(data)) || last.BytesWritten != int64(len(compressed)) {
+		t.Errorf("Unexpected final compression progress: %+v", last)
+	}
+
+	updates = nil
+	zr, err := NewReader(bytes.NewReader(compressed), opts)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	io.Copy(io.Discard, zr)
+	if len(updates) != 5 {
+		t.Fatalf("Expected one update per block and one at the end, got %+v", updates)
+	}
+	last = updates[len(updates)-1]
+	if last.Phase != PhaseDone || last.BytesRead != int64(len(compressed)) || last.BytesWritten != int64(len(data)) {
+		t.Errorf("Unexpected final decompression progress: %+v", last)
+	}
+}
+
+// FuzzReader lee contenedores arbitrarios: puede fallar, pero no entrar en
+// pánico ni superar los límites de salida.
+func FuzzReader(f *testing.F) {
+	for _, data := range [][]byte{{}, []byte("x"), bytes.Repeat([]byte("hello world "), 100)} {
+		for _, codec := range []string{"huffman", "rle", "rle-huffman", "stored"} {
+			var compressed bytes.Buffer
+			zw, _ := NewWriter(&compressed, Options{Codec: codec, BlockSize: 512})
+			zw.Write(data)
+			zw.Close()
+			f.Add(compressed.Bytes())
+		}
+	}
+
+	const maxOutput = 1 << 20
+	f.Fuzz(func(t *testing.T, compressed []byte) {
+		zr, err := NewReader(bytes.NewReader(compressed), Options{MaxOutput: maxOutput})
+		if err != nil {
+			return
+		}
+		n, _ := io.Copy(io.Discard, zr)
+		if n > maxOutput {
+			t.Fatalf("Read %d bytes past the limit", n)
+		}
+	})
+}
+
+// FuzzRoundTrip comprime y descomprime datos arbitrarios con cada codec.
+func FuzzRoundTrip(f *testing.F) {
+	for _, data := range testInputs() {
+		f.Add(data[:min(len(data), 256)], uint8(0))
+	}
+	f.Add([]byte("abracadabra"), uint8(3))
+
+	codecs := []string{"huffman", "rle", "rle-huffman", "stored", CodecAuto}
+	f.Fuzz(func(t *testing.T, data []byte, codec uint8) {
+		roundTrip(t, data, Options{Codec: codecs[int(codec)%len(codecs)], BlockSize: 1024})
+	})
+}
@@ -0,0 +1,190 @@
+package huffman
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Cifrado con contraseña.
+//
+// Con flagEncrypted el encabezado del contenedor continúa con:
+//
+//	kdf      uint8    kdfArgon2id
+//	time     uint32   iteraciones de Argon2id
+//	memory   uint32   memoria de Argon2id en KiB
+//	threads  uint8
+//	salt     [16]byte aleatoria
+//	check    [16]byte etiqueta AES-GCM de un mensaje vacío, para detectar una contraseña incorrecta
+//
+// La clave AES-256 se deriva de la contraseña con Argon2id. Cada payload se
+// cifra después de comprimirlo con AES-256-GCM; el nonce es el índice del
+// bloque y los datos autenticados son el encabezado completo del contenedor
+// más el codec y la longitud original del bloque, así que no se pueden
+// reordenar, mezclar ni alterar bloques sin que falle la autenticación. El
+// rawCRC de los bloques cifrados queda en cero para no filtrar información
+// del contenido.
+//
+// El marcador de fin va seguido de una etiqueta AES-GCM que autentica la
+// cantidad de bloques, para que cortar el archivo después de un bloque y
+// agregar el marcador no pase desapercibido:
+//
+//	end      [16]byte etiqueta de un mensaje vacío con la cantidad de bloques
+
+const (
+	flagEncrypted = 1 << 1
+
+	kdfArgon2id      = 1
+	saltLen          = 16
+	encryptParamsLen = 1 + 4 + 4 + 1 + saltLen
+	encryptHeaderLen = encryptParamsLen + 16
+	endTagLen        = 16
+
+	// Límites de los parámetros de Argon2id aceptados al leer: los mismos que
+	// usa el escritor. El encabezado llega sin autenticar antes de derivar la
+	// clave, así que un archivo manipulado no puede pedir más memoria, tiempo
+	// ni hilos que un archivo legítimo.
+	maxArgon2Time    = 3
+	maxArgon2Memory  = 64 * 1024 // 64 MiB en KiB
+	maxArgon2Threads = 4
+)
+
+// Parámetros de Argon2id usados al cifrar (segunda recomendación del RFC 9106).
+var (
+	argon2Time    uint32 = maxArgon2Time
+	argon2Memory  uint32 = maxArgon2Memory
+	argon2Threads uint8  = maxArgon2Threads
+)
+
+// deriveKey es argon2.IDKey; las pruebas la reemplazan para comprobar que no
+// se llama con parámetros fuera de rango.
+var deriveKey = argon2.IDKey
+
+var (
+	// ErrPasswordRequired indica que el contenedor está cifrado y no se dio contraseña.
+	ErrPasswordRequired = errors.New("huffman: password required")
+	// ErrWrongPassword indica que la contraseña no corresponde al contenedor.
+	ErrWrongPassword = errors.New("huffman: wrong password")
+	// ErrAuthentication indica que un bloque cifrado fue alterado, o que se
+	// quitaron o agregaron bloques al final.
+	ErrAuthentication = errors.New("huffman: message authentication failed")
+)
+
+// blockCipher cifra y descifra los payloads de un contenedor.
+type blockCipher struct {
+	aead   cipher.AEAD
+	header []byte // encabezado del contenedor sin la etiqueta de verificación
+}
+
+// newEncryptParams genera parámetros de Argon2id con una sal aleatoria.
+func newEncryptParams() ([]byte, error) {
+	params := make([]byte, 0, encryptParamsLen)
+	params = append(params, kdfArgon2id)
+	params = binary.BigEndian.AppendUint32(params, argon2Time)
+	params = binary.BigEndian.AppendUint32(params, argon2Memory)
+	params = append(params, argon2Threads)
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return append(params, salt...), nil
+}
+
+// newBlockCipher deriva la clave de la contraseña con los parámetros que
+// terminan header (el encabezado del contenedor hasta la sal inclusive).
+func newBlockCipher(password string, header []byte) (*blockCipher, error) {
+	params := header[len(header)-encryptParamsLen:]
+	if params[0] != kdfArgon2id {
+		return nil, fmt.Errorf("%w: unknown key derivation %d", ErrFormat, params[0])
+	}
+	time := binary.BigEndian.Uint32(params[1:])
+	memory := binary.BigEndian.Uint32(params[5:])
+	threads := params[9]
+	if time == 0 || time > maxArgon2Time || memory == 0 || memory > maxArgon2Memory || threads == 0 || threads > maxArgon2Threads {
+		return nil, fmt.Errorf("%w: key derivation parameters out of range", ErrFormat)
+	}
+
+	key := deriveKey([]byte(password), params[10:], time, memory, threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &blockCipher{aead: aead, header: header}, nil
+}
+
+// keyCheck es la etiqueta que se guarda en el encabezado para reconocer la contraseña.
+func (c *blockCipher) keyCheck() []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	for i := range 4 {
+		nonce[i] = 0xFF
+	}
+	return c.aead.Seal(nil, nonce, nil, c.header)
+}
+
+func (c *blockCipher) verifyKey(check []byte) error {
+	nonce := make([]byte, c.aead.NonceSize())
+	for i := range 4 {
+		nonce[i] = 0xFF
+	}
+	if _, err := c.aead.Open(nil, nonce, check, c.header); err != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// nonce usa el índice del bloque; la sal aleatoria garantiza una clave distinta por archivo.
+func (c *blockCipher) nonce(index int) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[4:], uint64(index))
+	return nonce
+}
+
+func (c *blockCipher) additionalData(method byte, rawLen int) []byte {
+	ad := append([]byte(nil), c.header...)
+	ad = append(ad, method)
+	return binary.BigEndian.AppendUint32(ad, uint32(rawLen))
+}
+
+func (c *blockCipher) seal(index int, method byte, rawLen int, payload []byte) []byte {
+	return c.aead.Seal(nil, c.nonce(index), payload, c.additionalData(method, rawLen))
+}
+
+func (c *blockCipher) open(index int, method byte, rawLen int, payload []byte) ([]byte, error) {
+	plain, err := c.aead.Open(nil, c.nonce(index), payload, c.additionalData(method, rawLen))
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return plain, nil
+}
+
+// endNonce empieza con 0xFE para no coincidir con los de los bloques ni con el
+// de keyCheck.
+func (c *blockCipher) endNonce(blocks int) []byte {
+	nonce := c.nonce(blocks)
+	for i := range 4 {
+		nonce[i] = 0xFE
+	}
+	return nonce
+}
+
+// endTag es la etiqueta que sigue al marcador de fin de un contenedor con
+// blocks bloques.
+func (c *blockCipher) endTag(blocks int) []byte {
+	return c.aead.Seal(nil, c.endNonce(blocks), nil, c.header)
+}
+
+func (c *blockCipher) verifyEnd(blocks int, tag []byte) error {
+	if _, err := c.aead.Open(nil, c.endNonce(blocks), tag, c.header); err != nil {
+		return fmt.Errorf("%w: the container does not end after %d blocks", ErrAuthentication, blocks)
+	}
+	return nil
+}
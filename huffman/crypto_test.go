@@ -0,0 +1,192 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// fastKDF baja los parámetros de Argon2id durante la prueba para que sea rápida.
+func fastKDF(t *testing.T) {
+	t.Helper()
+	time, memory := argon2Time, argon2Memory
+	argon2Time, argon2Memory = 1, 1024
+	t.Cleanup(func() { argon2Time, argon2Memory = time, memory })
+}
+
+func compressEncrypted(t *testing.T, data []byte, opts Options) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw, err := NewWriter(&compressed, opts)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return compressed.Bytes()
+}
+
+func decompressEncrypted(compressed []byte, password string) ([]byte, error) {
+	zr, err := NewReader(bytes.NewReader(compressed), Options{Password: password})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	fastKDF(t)
+	data := bytes.Repeat([]byte("confidential coursework "), 2000)
+	compressed := compressEncrypted(t, data, Options{Password: "s3cret", BlockSize: 4096})
+
+	decompressed, err := decompressEncrypted(compressed, "s3cret")
+	if err != nil {
+		t.Fatalf("Decompression failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("Decompressed data does not match original data")
+	}
+
+	// Sin la contraseña no se ve el texto ni la tabla de códigos
+	plain := compressEncrypted(t, data, Options{BlockSize: 4096})
+	if bytes.Contains(compressed, []byte("confidential")) || bytes.Contains(compressed, plain[containerHeaderLen+frameHeaderLen:][:64]) {
+		t.Error("Encrypted container leaks compressed content")
+	}
+
+	if _, err := decompressEncrypted(compressed, ""); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("Expected ErrPasswordRequired, got %v", err)
+	}
+	if _, err := decompressEncrypted(compressed, "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+}
+
+func TestEncryptionDetectsTampering(t *testing.T) {
+	fastKDF(t)
+	data := bytes.Repeat([]byte("tamper evident "), 1000)
+	compressed := compressEncrypted(t, data, Options{Password: "pw", BlockSize: 4096})
+
+	// Alterar el texto cifrado del primer bloque y recalcular sus CRC, como
+	// haría alguien que quiere que el cambio pase desapercibido
+	frame := compressed[containerHeaderLen+encryptHeaderLen:]
+	payloadLen := binary.BigEndian.Uint32(frame[5:])
+	payload := frame[frameHeaderLen : frameHeaderLen+int(payloadLen)]
+	payload[3] ^= 0x01
+	binary.BigEndian.PutUint32(frame[13:], crc32.Checksum(payload, castagnoli))
+	binary.BigEndian.PutUint32(frame[17:], crc32.Checksum(frame[:17], castagnoli))
+
+	_, err := decompressEncrypted(compressed, "pw")
+	var blockErr *BlockError
+	if !errors.As(err, &blockErr) || blockErr.Index != 0 || !errors.Is(err, ErrAuthentication) {
+		t.Fatalf("Expected authentication error in block 0, got %v", err)
+	}
+
+	// El encabezado también está autenticado: cambiar el tamaño de bloque invalida la contraseña
+	compressed = compressEncrypted(t, data, Options{Password: "pw", BlockSize: 4096})
+	compressed[9] ^= 0x01
+	if _, err := decompressEncrypted(compressed, "pw"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword after header change, got %v", err)
+	}
+}
+
+// Cortar el contenedor después de un bloque y agregar el marcador de fin no
+// debe dar un archivo más corto sin error.
+func TestEncryptionDetectsTruncation(t *testing.T) {
+	fastKDF(t)
+	data := parityTestData()[:3000]
+	compressed := compressEncrypted(t, data, Options{Password: "pw", BlockSize: 1024})
+
+	frame := compressed[containerHeaderLen+encryptHeaderLen:]
+	firstEnd := containerHeaderLen + encryptHeaderLen + frameHeaderLen + int(binary.BigEndian.Uint32(frame[5:]))
+	end := len(compressed) - endTagLen
+	truncated := [][]byte{
+		append(append([]byte(nil), compressed[:firstEnd]...), methodEnd),
+		// Con la etiqueta del contenedor completo tampoco sirve
+		append(append(append([]byte(nil), compressed[:firstEnd]...), methodEnd), compressed[end:]...),
+	}
+	for i, c := range truncated {
+		out, err := decompressEncrypted(c, "pw")
+		if err == nil {
+			t.Errorf("%d: truncated container decrypted to %d bytes without error", i, len(out))
+		}
+	}
+	_, err := decompressEncrypted(truncated[1], "pw")
+	if !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication, got %v", err)
+	}
+
+	if out, err := decompressEncrypted(compressed, "pw"); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("Complete container failed: %v", err)
+	}
+}
+
+func TestEncryptionRejectsCostlyKDF(t *testing.T) {
+	// Solo se mira qué parámetros llegan a Argon2id, así que no hace falta derivar de verdad
+	var calls int
+	derive := deriveKey
+	deriveKey = func(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+		calls++
+		return make([]byte, keyLen)
+	}
+	t.Cleanup(func() { deriveKey = derive })
+	compressed := compressEncrypted(t, []byte("costly"), Options{Password: "pw"})
+
+	// Los parámetros del escritor se aceptan
+	calls = 0
+	if _, err := decompressEncrypted(compressed, "pw"); err != nil || calls != 1 {
+		t.Fatalf("Default parameters: %d derivations, error %v", calls, err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(p []byte)
+	}{
+		{"time", func(p []byte) { binary.BigEndian.PutUint32(p[1:], maxArgon2Time+1) }},
+		{"memory", func(p []byte) { binary.BigEndian.PutUint32(p[5:], 1<<20) }},
+		{"threads", func(p []byte) { p[9] = maxArgon2Threads + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := append([]byte(nil), compressed...)
+			tt.tamper(tampered[containerHeaderLen:])
+			calls = 0
+			if _, err := decompressEncrypted(tampered, "pw"); !errors.Is(err, ErrFormat) || calls != 0 {
+				t.Errorf("Expected ErrFormat without deriving the key, got %v after %d derivations", err, calls)
+			}
+		})
+	}
+}
+
+func TestEncryptionWithParity(t *testing.T) {
+	fastKDF(t)
+	data := parityTestData()
+	original := compressEncrypted(t, data, Options{Password: "pw", BlockSize: 1024, Parity: 2})
+
+	report, err := Verify(bytes.NewReader(original))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() || !report.Encrypted || report.Blocks != 40 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	// Reparar sin la contraseña y descifrar el resultado
+	damaged := append([]byte(nil), original...)
+	damaged[len(damaged)-100] ^= 0xFF
+	var repaired bytes.Buffer
+	if _, err := Repair(bytes.NewReader(damaged), &repaired); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	decompressed, err := decompressEncrypted(repaired.Bytes(), "pw")
+	if err != nil {
+		t.Fatalf("Decompression of repaired container failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Decompressed data does not match original data")
+	}
+}
@@ -0,0 +1,37 @@
+package huffman
+
+import "math"
+
+// Entropy calcula la entropía de Shannon (bits por símbolo) de una tabla de frecuencias.
+// Es la cota inferior de la longitud media de cualquier código de prefijo para esos datos.
+func Entropy(frequencies map[byte]int) float64 {
+	total := 0
+	for _, freq := range frequencies {
+		total += freq
+	}
+	if total == 0 {
+		return 0
+	}
+
+	// Se suma en orden de símbolo: sumar en el orden del mapa cambia los
+	// últimos bits del resultado de una ejecución a otra
+	entropy := 0.0
+	for symbol := 0; symbol < 256; symbol++ {
+		freq := frequencies[byte(symbol)]
+		if freq == 0 {
+			continue
+		}
+		p := float64(freq) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// huffmanWorthwhile estima con la entropía si un bloque Huffman puede ser más
+// pequeño que el bloque original. La estimación es una cota inferior: los bits
+// nunca bajan de la entropía y la tabla ocupa al menos 4 bytes más 3 por
+// símbolo (carácter, longitud y un bit de código como mínimo).
+func huffmanWorthwhile(frequencies map[byte]int, size int) bool {
+	estimate := math.Ceil(Entropy(frequencies)*float64(size)/8) + 4 + 3*float64(len(frequencies))
+	return estimate < float64(size)
+}
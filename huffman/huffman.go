@@ -3,8 +3,10 @@ package huffman
 import (
 	"bytes"
 	"container/heap"
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -16,119 +18,266 @@ import (
 // Compress comprime un archivo de entrada y guarda el resultado en un archivo de salida con extensión .huff.
 // Decompress descomprime un archivo de entrada .huff y guarda el resultado en un archivo de salida.
 
-
 // Estructura para el nodo del árbol de Huffman
 type huffmanNode struct {
 	Frequency int          `json:"frequency"`
 	Char      byte         `json:"char"`
 	Left      *huffmanNode `json:"left,omitempty"`
 	Right     *huffmanNode `json:"right,omitempty"`
+	id        int          // orden de creación, para TraceTree
+	depth     int          // altura del subárbol; 0 en las hojas
+	minChar   byte         // menor símbolo del subárbol
 }
 
 // Definición del min-heap para los nodos de Huffman
-type priorityQueue []*huffmanNode
-
-func (pq priorityQueue) Len() int { return len(pq) }
+type priorityQueue struct {
+	nodes []*huffmanNode
+	// minVariance desempata con el subárbol menos profundo primero, ver Less.
+	minVariance bool
+}
 
-func (pq priorityQueue) Less(i, j int) bool {
-	return pq[i].Frequency < pq[j].Frequency
+func (pq *priorityQueue) Len() int { return len(pq.nodes) }
+
+// Less ordena la cola de forma total para que el mismo bloque produzca
+// siempre el mismo árbol y los mismos bytes: primero la menor frecuencia; a
+// igual frecuencia, el subárbol más profundo; y si también empatan, el que
+// contiene el menor símbolo. Los subárboles de la cola no comparten símbolos,
+// así que nunca hay dos nodos iguales. Cualquier desempate da la misma
+// longitud total; este solo la fija.
+//
+// Con minVariance a igual frecuencia sale primero el subárbol menos profundo.
+// Así los nodos recién unidos quedan para después, el árbol resulta lo más
+// balanceado posible y la varianza de las longitudes de código es mínima.
+func (pq *priorityQueue) Less(i, j int) bool {
+	a, b := pq.nodes[i], pq.nodes[j]
+	if a.Frequency != b.Frequency {
+		return a.Frequency < b.Frequency
+	}
+	if a.depth != b.depth {
+		return (a.depth > b.depth) != pq.minVariance
+	}
+	return a.minChar < b.minChar
 }
 
-func (pq priorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+func (pq *priorityQueue) Swap(i, j int) {
+	pq.nodes[i], pq.nodes[j] = pq.nodes[j], pq.nodes[i]
 }
 
 func (pq *priorityQueue) Push(x any) {
 	node := x.(*huffmanNode)
-	*pq = append(*pq, node)
+	pq.nodes = append(pq.nodes, node)
 }
 
 func (pq *priorityQueue) Pop() any {
-	old := *pq
+	old := pq.nodes
 	n := len(old)
 	item := old[n-1]
 	old[n-1] = nil
-	*pq = old[0 : n-1]
+	pq.nodes = old[0 : n-1]
 	return item
 }
 
 func buildHuffmanTree(frequencies map[byte]int) *huffmanNode {
-	pq := make(priorityQueue, 0, len(frequencies))
-	for char, freq := range frequencies {
-		pq = append(pq, &huffmanNode{Frequency: freq, Char: char})
+	return buildTree(frequencies, false, nil)
+}
+
+// buildTree construye el árbol de Huffman, con el desempate de varianza
+// mínima si minVariance, y si trace no es nil anota cada paso en trace. Las
+// hojas entran a la cola en orden de símbolo.
+func buildTree(frequencies map[byte]int, minVariance bool, trace *TreeTrace) *huffmanNode {
+	pq := &priorityQueue{nodes: make([]*huffmanNode, 0, len(frequencies)), minVariance: minVariance}
+	for symbol := 0; symbol < 256; symbol++ {
+		if freq, ok := frequencies[byte(symbol)]; ok {
+			pq.nodes = append(pq.nodes, &huffmanNode{Frequency: freq, Char: byte(symbol), id: len(pq.nodes), minChar: byte(symbol)})
+		}
+	}
+	heap.Init(pq)
+	if trace != nil {
+		trace.Leaves = queueSnapshot(pq)
 	}
-	heap.Init(&pq)
+	nextID := pq.Len()
 
 	for pq.Len() > 1 {
 		// Extraer los dos nodos con menor frecuencia
-		node1 := heap.Pop(&pq).(*huffmanNode)
-		node2 := heap.Pop(&pq).(*huffmanNode)
+		node1 := heap.Pop(pq).(*huffmanNode)
+		node2 := heap.Pop(pq).(*huffmanNode)
 
 		// Crear un nuevo nodo interno con la suma de las frecuencias
 		mergedNode := &huffmanNode{
 			Frequency: node1.Frequency + node2.Frequency,
 			Left:      node1,
 			Right:     node2,
+			id:        nextID,
+			depth:     max(node1.depth, node2.depth) + 1,
+			minChar:   min(node1.minChar, node2.minChar),
 		}
+		nextID++
 
 		// Insertar el nuevo nodo en la cola de prioridad
-		heap.Push(&pq, mergedNode)
+		heap.Push(pq, mergedNode)
+		if trace != nil {
+			trace.Steps = append(trace.Steps, TreeStep{
+				Step:   len(trace.Steps) + 1,
+				Left:   traceNode(node1),
+				Right:  traceNode(node2),
+				Merged: traceNode(mergedNode),
+				Queue:  queueSnapshot(pq),
+			})
+		}
 	}
 
 	// El nodo restante en la cola de prioridad es la raíz del árbol de Huffman
 	if pq.Len() == 1 {
-		return pq[0]
+		return pq.nodes[0]
 	}
 	return nil // En caso de que el archivo esté vacío
 }
 
 // Compress comprime el archivo de entrada y guarda el resultado en el archivo de salida con extensión .huff.
 func Compress(inputFile string, outputFile string) error {
-	// 1. Leer el contenido del archivo de entrada.
-	content, err := os.ReadFile(inputFile)
+	return CompressWithOptions(inputFile, outputFile, Options{})
+}
+
+// CompressWithOptions es como Compress pero acepta Options: codec (o CodecAuto),
+// tamaño de bloque, paridad, contraseña y clave de firma.
+func CompressWithOptions(inputFile string, outputFile string, opts Options) error {
+	return CompressContext(context.Background(), inputFile, outputFile, opts)
+}
+
+// CompressContext es como CompressWithOptions pero se detiene en el siguiente
+// límite de bloque cuando se cancela ctx. Si falla o se cancela no deja el
+// archivo de salida.
+func CompressContext(ctx context.Context, inputFile string, outputFile string, opts Options) (err error) {
+	// 1. Abrir el archivo de entrada.
+	in, err := os.Open(inputFile)
 	if err != nil {
 		return err
 	}
-	// 2. Contar la frecuencia de cada byte en un mapa o diccionario.
-	frequencies := countFrequencies(content)
-	// 3. Crear el árbol de Huffman.
-	root := buildHuffmanTree(frequencies)
-	// PrintTreeAsJSON(root)
-	// PrintLeafs(root)
-	// 4. Crear la tabla de códigos Huffman.
-	codes := generateCodes(root)
-	fmt.Println("Códigos Huffman generados:")
-	for char, code := range codes {
-		fmt.Printf("Carácter: '%c', Código: %s\n", char, code)
+	defer in.Close()
+
+	// 2. Crear el archivo de salida y el compresor por bloques.
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return err
 	}
-	// 5. Codificar el contenido del archivo usando la tabla de códigos.
-	encodedData := encode(content, codes)
-	// 6. Guardar los datos comprimidos y la tabla de códigos en el archivo de salida.
-	err = saveCompressedFile(outputFile, encodedData, codes)
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(outputFile)
+		}
+	}()
+
+	zw, err := NewWriterContext(ctx, out, opts)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// 3. Cada bloque se codifica con su propia tabla de códigos.
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
 }
 
 // Decompress descomprime el archivo de entrada .huff y guarda el resultado en el archivo de salida.
 func Decompress(inputFile string, outputFile string) error {
-	// 1. Leer el archivo comprimido, incluyendo los códigos Huffman.
-	encodedData, codes, err := readCompressedFile(inputFile)
+	return DecompressWithOptions(inputFile, outputFile, Options{})
+}
+
+// DecompressWithOptions es como Decompress pero acepta Options: contraseña,
+// clave pública esperada y si se saltan los bloques dañados. Si falla no deja
+// el archivo de salida.
+func DecompressWithOptions(inputFile string, outputFile string, opts Options) error {
+	return DecompressContext(context.Background(), inputFile, outputFile, opts)
+}
+
+// DecompressContext es como DecompressWithOptions pero se detiene en el
+// siguiente límite de bloque cuando se cancela ctx.
+func DecompressContext(ctx context.Context, inputFile string, outputFile string, opts Options) (err error) {
+	in, err := os.Open(inputFile)
 	if err != nil {
 		return err
 	}
+	defer in.Close()
 
-	// 2. Decodificar los datos comprimidos utilizando los códigos Huffman.
-	decodedData := decode(encodedData, codes)
+	// Los archivos sin magic usan el formato original de una sola tabla, que
+	// se decodifica de una vez; solo se puede cancelar antes de empezar.
+	var magic [4]byte
+	if _, err := io.ReadFull(in, magic[:]); err != nil || magic != containerMagic {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		in.Close()
+		return decompressLegacy(inputFile, outputFile, opts)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
 
-	// 3. Guardar los datos descomprimidos en el archivo de salida.
-	err = os.WriteFile(outputFile, decodedData, 0644)
+	zr, err := NewReaderContext(ctx, in, opts)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(outputFile)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Si la firma o un bloque fallan no se deja una salida a medias.
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(outputFile)
+		}
+	}()
+	if _, err := io.Copy(out, zr); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// decompressLegacy escribe en outputFile el archivo del formato original
+// inputFile.
+func decompressLegacy(inputFile string, outputFile string, opts Options) error {
+	in, err := os.Open(inputFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	decodedData, err := ReadLegacy(in, opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, decodedData, 0644)
+}
+
+// ReadLegacy decodifica el formato original, una tabla de códigos seguida de
+// los bits, leído de r. Como no hay bloques, los límites de opts se comprueban
+// mientras se decodifica y se detiene en el primer símbolo que los supera. El
+// formato no se puede firmar, así que con opts.VerifyKey devuelve ErrNotSigned.
+func ReadLegacy(r io.Reader, opts Options) ([]byte, error) {
+	if opts.VerifyKey != nil {
+		return nil, ErrNotSigned
+	}
+	// 1. Leer la tabla de códigos del encabezado
+	codes, err := readCodeTable(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 2. Leer los datos comprimidos restantes
+	encodedData, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// 3. Decodificar los datos comprimidos utilizando los códigos Huffman
+	return decode(encodedData, codes, opts)
 }
 
 // Funciones auxiliares que implementaremos a continuación:
@@ -142,6 +291,11 @@ func countFrequencies(data []byte) map[byte]int {
 
 func generateCodes(root *huffmanNode) map[byte]string {
 	codes := make(map[byte]string)
+	// Con un solo símbolo la raíz es una hoja; se le asigna un código de un bit.
+	if root != nil && root.Left == nil && root.Right == nil {
+		codes[root.Char] = "0"
+		return codes
+	}
 	generateCodesRecursive(root, "", codes)
 	return codes
 }
@@ -210,128 +364,254 @@ func saveCompressedFile(outputFile string, encodedData []byte, codes map[byte]st
 	}
 	defer file.Close()
 
+	// 1. Escribir la tabla de códigos en el encabezado
+	err = writeCodeTable(file, codes)
+	if err != nil {
+		return err
+	}
+
+	// 2. Escribir los datos comprimidos
+	_, err = file.Write(encodedData)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeCodeTable escribe el número de códigos seguido de cada carácter, la
+// longitud de su código y el código como texto de '0' y '1'.
+func writeCodeTable(w io.Writer, codes map[byte]string) error {
 	// 1. Escribir el número de códigos
 	numCodes := uint32(len(codes))
-	err = binary.Write(file, binary.BigEndian, &numCodes)
+	err := binary.Write(w, binary.BigEndian, &numCodes)
 	if err != nil {
 		return err
 	}
 
-	// 2. Escribir cada código en el encabezado
-	for char, code := range codes {
-		// Escribir el byte
-		err = binary.Write(file, binary.BigEndian, &char)
-		if err != nil {
-			return err
+	// 2. Escribir cada código, en orden de símbolo para que la tabla no
+	// dependa del orden en que se recorre el mapa
+	for symbol := 0; symbol < 256; symbol++ {
+		code, ok := codes[byte(symbol)]
+		if !ok {
+			continue
 		}
-
-		// Escribir la longitud del código
+		// Escribir el byte y la longitud del código
 		codeLen := uint8(len(code))
-		err = binary.Write(file, binary.BigEndian, &codeLen)
+		_, err = w.Write([]byte{byte(symbol), codeLen})
 		if err != nil {
 			return err
 		}
 
 		// Escribir el código
-		_, err = file.WriteString(code)
+		_, err = io.WriteString(w, code)
 		if err != nil {
 			return err
 		}
 	}
-
-	// 3. Escribir los datos comprimidos
-	_, err = file.Write(encodedData)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func readCompressedFile(inputFile string) ([]byte, map[byte]string, error) {
-	file, err := os.Open(inputFile)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer file.Close()
+// maxCodes es el número máximo de entradas de una tabla: una por valor de byte.
+const maxCodes = 256
 
+// ErrCodeTable es el error con el que coinciden (errors.Is) los errores de una
+// tabla de códigos mal formada.
+var ErrCodeTable = errors.New("huffman: invalid code table")
+
+// readCodeTable lee una tabla escrita por writeCodeTable y comprueba que sirva
+// para decodificar: a lo sumo maxCodes entradas sin símbolos repetidos, códigos
+// no vacíos formados solo por '0' y '1', y un conjunto de códigos libre de
+// prefijos y completo.
+func readCodeTable(r io.Reader) (map[byte]string, error) {
 	// 1. Leer el número de códigos
 	var numCodes uint32
-	err = binary.Read(file, binary.BigEndian, &numCodes)
+	err := binary.Read(r, binary.BigEndian, &numCodes)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if numCodes > maxCodes {
+		return nil, fmt.Errorf("%w: %d codes, at most %d are possible", ErrCodeTable, numCodes, maxCodes)
 	}
 
-	// 2. Leer la tabla de códigos del encabezado
-	codes := make(map[byte]string)
+	// 2. Leer cada código
+	codes := make(map[byte]string, numCodes)
 	for i := uint32(0); i < numCodes; i++ {
-		// Leer el byte
-		var char byte
-		err = binary.Read(file, binary.BigEndian, &char)
+		// Leer el byte y la longitud del código
+		var entry [2]byte
+		_, err = io.ReadFull(r, entry[:])
 		if err != nil {
-			return nil, nil, err
+			return nil, unexpectedEOF(err)
 		}
-
-		// Leer la longitud del código
-		var codeLen uint8
-		err = binary.Read(file, binary.BigEndian, &codeLen)
-		if err != nil {
-			return nil, nil, err
+		if _, dup := codes[entry[0]]; dup {
+			return nil, fmt.Errorf("%w: symbol %d appears twice", ErrCodeTable, entry[0])
 		}
 
 		// Leer el código
-		codeBytes := make([]byte, codeLen)
-		_, err = io.ReadFull(file, codeBytes)
+		codeBytes := make([]byte, entry[1])
+		_, err = io.ReadFull(r, codeBytes)
 		if err != nil {
-			return nil, nil, err
+			return nil, unexpectedEOF(err)
 		}
-		codes[char] = string(codeBytes)
+		codes[entry[0]] = string(codeBytes)
 	}
-	// 3. Leer los datos comprimidos restantes del archivo
-	encodedData, err := io.ReadAll(file)
+
+	// 3. Comprobar que los códigos formen un árbol válido
+	if _, err := codeTree(codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// codeTree reconstruye el árbol de Huffman a partir de los códigos. Falla si
+// un código está vacío o tiene caracteres distintos de '0' y '1', si un código
+// es prefijo de otro o si el árbol tiene ramas vacías. La única excepción es
+// una tabla de un solo símbolo, cuyo código de un bit deja una rama libre.
+func codeTree(codes map[byte]string) (*huffmanNode, error) {
+	root, leaves, err := prefixTree(codes)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	if len(codes) == 1 && (leaves[root.Left] || leaves[root.Right]) {
+		return root, nil
+	}
+	if len(codes) > 0 {
+		if err := checkComplete(root, "", leaves); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
 
-	return encodedData, codes, nil
+// prefixTree arma el árbol de los códigos sin exigir que esté completo y
+// devuelve también el conjunto de hojas.
+func prefixTree(codes map[byte]string) (*huffmanNode, map[*huffmanNode]bool, error) {
+	root := &huffmanNode{}
+	leaves := make(map[*huffmanNode]bool, len(codes))
+	// Recorrer los símbolos en orden para que los errores sean reproducibles
+	for sym := 0; sym < maxCodes; sym++ {
+		code, ok := codes[byte(sym)]
+		if !ok {
+			continue
+		}
+		if code == "" {
+			return nil, nil, fmt.Errorf("%w: symbol %d has an empty code", ErrCodeTable, sym)
+		}
+		node := root
+		for i := 0; i < len(code); i++ {
+			if code[i] != '0' && code[i] != '1' {
+				return nil, nil, fmt.Errorf("%w: code of symbol %d contains %q", ErrCodeTable, sym, code[i])
+			}
+			if leaves[node] {
+				return nil, nil, fmt.Errorf("%w: the code of symbol %d is a prefix of the code of symbol %d", ErrCodeTable, node.Char, sym)
+			}
+			next := &node.Left
+			if code[i] == '1' {
+				next = &node.Right
+			}
+			if *next == nil {
+				*next = &huffmanNode{}
+			} else if i == len(code)-1 {
+				return nil, nil, fmt.Errorf("%w: the code of symbol %d repeats or prefixes another code", ErrCodeTable, sym)
+			}
+			node = *next
+		}
+		node.Char = byte(sym)
+		leaves[node] = true
+	}
+	return root, leaves, nil
+}
+
+// checkComplete comprueba que todo nodo interno tenga dos hijos, es decir, que
+// cualquier secuencia de bits se pueda decodificar.
+func checkComplete(node *huffmanNode, prefix string, leaves map[*huffmanNode]bool) error {
+	if leaves[node] {
+		return nil
+	}
+	if node.Left == nil {
+		return fmt.Errorf("%w: no code starts with %q", ErrCodeTable, prefix+"0")
+	}
+	if node.Right == nil {
+		return fmt.Errorf("%w: no code starts with %q", ErrCodeTable, prefix+"1")
+	}
+	if err := checkComplete(node.Left, prefix+"0", leaves); err != nil {
+		return err
+	}
+	return checkComplete(node.Right, prefix+"1", leaves)
+}
+
+// decode decodifica todos los bits de encodedData, incluidos los de relleno.
+// Devuelve un *LimitError en cuanto la salida supera MaxOutput o MaxRatio de
+// limits, contando como entrada todo encodedData.
+func decode(encodedData []byte, codes map[byte]string, limits Options) ([]byte, error) {
+	var decodedData bytes.Buffer
+	err := decodeEach(encodedData, codes, func(b byte) error {
+		if err := checkLimits(int64(decodedData.Len()+1), int64(len(encodedData)), limits); err != nil {
+			return err
+		}
+		return decodedData.WriteByte(b)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodedData.Bytes(), nil
 }
 
-func decode(encodedData []byte, codes map[byte]string) []byte {
+// decodeEach recorre los bits de encodedData, incluidos los de relleno, y
+// llama a emit con cada símbolo decodificado. Se detiene en el primer error
+// de emit.
+func decodeEach(encodedData []byte, codes map[byte]string, emit func(byte) error) error {
 	// Crear la tabla de decodificación inversa (código -> byte)
 	decodingTable := make(map[string]byte)
 	for char, code := range codes {
 		decodingTable[code] = char
 	}
 
-	var decodedData bytes.Buffer
-	var currentCode string
-
-	// Convertir los datos comprimidos (bytes) a una secuencia de bits (string)
-	bitString := bytesToBits(encodedData)
-
-	for _, bit := range bitString {
-		currentCode += string(bit)
-		if originalByte, ok := decodingTable[currentCode]; ok {
-			decodedData.WriteByte(originalByte)
-			currentCode = "" // Reiniciar el código actual
+	var currentCode []byte
+	for _, b := range encodedData {
+		for i := 7; i >= 0; i-- {
+			currentCode = append(currentCode, '0'+(b>>i)&1)
+			if originalByte, ok := decodingTable[string(currentCode)]; ok {
+				if err := emit(originalByte); err != nil {
+					return err
+				}
+				currentCode = currentCode[:0] // Reiniciar el código actual
+			}
 		}
 	}
-
-	return decodedData.Bytes()
+	return nil
 }
-func bytesToBits(data []byte) string {
-	var bitsBuffer bytes.Buffer
-	for _, b := range data {
-		for i := 7; i >= 0; i-- {
-			bit := (b >> i) & 1
-			if bit == 1 {
-				bitsBuffer.WriteString("1")
+
+// decodeN decodifica exactamente n símbolos recorriendo el árbol reconstruido a
+// partir de los códigos, de modo que los bits de relleno del último byte se ignoran.
+func decodeN(encodedData []byte, codes map[byte]string, n int) ([]byte, error) {
+	root, err := codeTree(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	decodedData := make([]byte, 0, n)
+	node := root
+	for _, b := range encodedData {
+		for i := 7; i >= 0 && len(decodedData) < n; i-- {
+			if (b>>i)&1 == 1 {
+				node = node.Right
 			} else {
-				bitsBuffer.WriteString("0")
+				node = node.Left
+			}
+			if node == nil {
+				return nil, errCorruptBlock
+			}
+			if node.Left == nil && node.Right == nil {
+				decodedData = append(decodedData, node.Char)
+				node = root
 			}
 		}
 	}
-	return bitsBuffer.String()
+	if len(decodedData) != n {
+		return nil, errCorruptBlock
+	}
+	return decodedData, nil
 }
 
 // Add this new function to print the tree as JSON
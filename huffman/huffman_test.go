@@ -2,6 +2,10 @@ package huffman
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"maps"
 	"os"
 	"testing"
 )
@@ -9,9 +13,16 @@ import (
 func TestHuffman(t *testing.T) {
 
 	testFile := "test.txt"
+	err := os.WriteFile(testFile, bytes.Repeat([]byte("hello world "), 1000), 0644)
+	if err != nil {
+		t.Fatalf("Error creating test file: %v", err)
+	}
+	defer os.Remove(testFile)
+
 	// 1. Comprimir el archivo usando la función Compress
 	compressedFile := "test.huff"
-	err := Compress(testFile, compressedFile)
+	defer os.Remove(compressedFile)
+	err = Compress(testFile, compressedFile)
 	if err != nil {
 		t.Fatalf("Compression failed: %v", err)
 	}
@@ -41,6 +52,7 @@ func TestHuffman(t *testing.T) {
 
 	// 5. Descomprimir el archivo comprimido
 	decompressedFile := "test_decompressed.txt"
+	defer os.Remove(decompressedFile)
 	err = Decompress(compressedFile, decompressedFile)
 	if err != nil {
 		t.Fatalf("Decompression failed: %v", err)
@@ -64,3 +76,127 @@ func TestHuffman(t *testing.T) {
 		t.Logf("Integrity check passed: Decompressed data matches original data.")
 	}
 }
+
+// codeTableBytes escribe una tabla con numCodes declarados seguida de las
+// entradas dadas, sin validarlas.
+func codeTableBytes(numCodes uint32, entries ...any) []byte {
+	out := binary.BigEndian.AppendUint32(nil, numCodes)
+	for i := 0; i < len(entries); i += 2 {
+		code := entries[i+1].(string)
+		out = append(out, byte(entries[i].(rune)), byte(len(code)))
+		out = append(out, code...)
+	}
+	return out
+}
+
+func TestTieBreaking(t *testing.T) {
+	cases := []struct {
+		frequencies map[byte]int
+		want        map[byte]string
+	}{
+		// ab y c empatan en frecuencia: sale primero ab, el más profundo
+		{map[byte]int{'a': 1, 'b': 1, 'c': 2}, map[byte]string{'a': "00", 'b': "01", 'c': "1"}},
+		// ab y cd empatan en frecuencia y profundidad: sale primero el que tiene la 'a'
+		{map[byte]int{'d': 1, 'c': 1, 'b': 1, 'a': 1}, map[byte]string{'a': "00", 'b': "01", 'c': "10", 'd': "11"}},
+	}
+	for _, c := range cases {
+		// El orden de recorrido del mapa cambia en cada vuelta; el árbol no
+		for range 20 {
+			codes := generateCodes(buildHuffmanTree(c.frequencies))
+			if !maps.Equal(codes, c.want) {
+				t.Fatalf("Frequencies %v: expected codes %v, got %v", c.frequencies, c.want, codes)
+			}
+		}
+	}
+
+	// La tabla se escribe en orden de símbolo
+	var first bytes.Buffer
+	codes := generateCodes(buildHuffmanTree(countFrequencies([]byte("the same table every time"))))
+	writeCodeTable(&first, codes)
+	for range 20 {
+		var again bytes.Buffer
+		writeCodeTable(&again, maps.Clone(codes))
+		if !bytes.Equal(first.Bytes(), again.Bytes()) {
+			t.Fatal("writeCodeTable output depends on map order")
+		}
+	}
+}
+
+func TestReadCodeTable(t *testing.T) {
+	valid := []struct {
+		name  string
+		table []byte
+	}{
+		{"empty", codeTableBytes(0)},
+		{"single symbol", codeTableBytes(1, 'a', "0")},
+		{"two symbols", codeTableBytes(2, 'a', "0", 'b', "1")},
+		{"skewed", codeTableBytes(4, 'a', "0", 'b', "10", 'c', "110", 'd', "111")},
+	}
+	for _, c := range valid {
+		if _, err := readCodeTable(bytes.NewReader(c.table)); err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+
+	invalid := []struct {
+		name  string
+		table []byte
+	}{
+		{"too many codes", codeTableBytes(1 << 31)},
+		{"257 codes", codeTableBytes(257)},
+		{"duplicate symbol", codeTableBytes(2, 'a', "0", 'a', "1")},
+		{"empty code", codeTableBytes(2, 'a', "", 'b', "1")},
+		{"not binary", codeTableBytes(2, 'a', "0", 'b', "2")},
+		{"prefix", codeTableBytes(3, 'a', "0", 'b', "01", 'c', "1")},
+		{"prefix after", codeTableBytes(3, 'a', "01", 'b', "0", 'c', "1")},
+		{"same code", codeTableBytes(2, 'a', "0", 'b', "0")},
+		{"incomplete", codeTableBytes(3, 'a', "0", 'b', "10", 'c', "111")},
+		{"single long code", codeTableBytes(1, 'a', "00")},
+	}
+	for _, c := range invalid {
+		_, err := readCodeTable(bytes.NewReader(c.table))
+		if !errors.Is(err, ErrCodeTable) {
+			t.Errorf("%s: expected ErrCodeTable, got %v", c.name, err)
+		} else {
+			t.Logf("%s: %v", c.name, err)
+		}
+	}
+
+	// Una tabla truncada no es un final limpio del archivo
+	table := codeTableBytes(2, 'a', "0", 'b', "1")
+	if _, err := readCodeTable(bytes.NewReader(table[:len(table)-2])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a truncated table, got %v", err)
+	}
+}
+
+// FuzzHuffmanDecode decodifica bloques arbitrarios con el codec Huffman y el
+// formato original: pueden fallar, pero no entrar en pánico ni quedarse sin
+// terminar. Lo que se acepta debe tener la longitud pedida.
+func FuzzHuffmanDecode(f *testing.F) {
+	for _, data := range [][]byte{{}, []byte("a"), []byte("hello world"), bytes.Repeat([]byte("ab"), 100)} {
+		block, err := huffmanCodec{}.Encode(data)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(block, uint16(len(data)))
+	}
+	f.Add(codeTableBytes(1<<31), uint16(1))
+	f.Add(codeTableBytes(2, 'a', "0", 'b', "01"), uint16(4))
+
+	f.Fuzz(func(t *testing.T, block []byte, rawLen uint16) {
+		out, err := huffmanCodec{}.Decode(block, int(rawLen))
+		if err == nil && len(out) != int(rawLen) {
+			t.Fatalf("Decoded %d bytes, expected %d", len(out), rawLen)
+		}
+
+		r := bytes.NewReader(block)
+		codes, err := readCodeTable(r)
+		if err != nil {
+			return
+		}
+		// Cada bit produce a lo sumo un símbolo
+		if out, _ := decode(block[len(block)-r.Len():], codes, Options{}); len(out) > 8*r.Len() {
+			t.Fatalf("Decoded %d bytes from %d", len(out), r.Len())
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// InspectReport describe la estructura de un archivo .huff: encabezado,
+// bloques, tabla de códigos y cuántos bytes no son datos comprimidos.
+type InspectReport struct {
+	// Format es "container" o "legacy" para el formato original de una sola tabla.
+	Format    string `json:"format"`
+	Version   int    `json:"version"`
+	BlockSize int    `json:"blockSize"`
+	Parity    bool   `json:"parity"`
+	Encrypted bool   `json:"encrypted"`
+	Signed    bool   `json:"signed"`
+	// Signer es la clave pública (hex) declarada en el registro de firma. No
+	// se verifica; para eso está Verify.
+	Signer string `json:"signer,omitempty"`
+	// Codec es el codec de todos los bloques, o "mixed" si hay varios.
+	Codec          string  `json:"codec"`
+	Blocks         int     `json:"blocks"`
+	OriginalSize   int64   `json:"originalSize"`
+	CompressedSize int64   `json:"compressedSize"`
+	Ratio          float64 `json:"ratio"` // tamaño comprimido sobre el original
+	// CodeTable es la tabla del primer bloque con Huffman, o la única del
+	// formato original, con la frecuencia de cada símbolo en ese bloque.
+	// Con rle-huffman los símbolos son los bytes del RLE. Está vacía si
+	// ningún bloque usa Huffman o si falta la contraseña.
+	CodeTable []CodeInfo `json:"codeTable"`
+	// BitsPerSymbol es la longitud media de código en ese bloque y
+	// CodeLengthVariance la varianza de las longitudes, ponderadas por frecuencia.
+	BitsPerSymbol      float64     `json:"bitsPerSymbol"`
+	CodeLengthVariance float64     `json:"codeLengthVariance"`
+	Overhead           Overhead    `json:"overhead"`
+	BlockList          []BlockInfo `json:"blockList"`
+}
+
+// CodeInfo es una entrada de la tabla de códigos.
+type CodeInfo struct {
+	Symbol    byte   `json:"symbol"`
+	Code      string `json:"code"`
+	Length    int    `json:"length"`
+	Frequency int    `json:"frequency"`
+}
+
+// Overhead reparte los bytes del archivo que no son datos codificados.
+type Overhead struct {
+	Header       int64 `json:"header"`       // encabezado del contenedor con los parámetros de cifrado
+	BlockHeaders int64 `json:"blockHeaders"` // encabezados de los bloques
+	CodeTables   int64 `json:"codeTables"`   // tablas de códigos dentro de los payloads
+	Records      int64 `json:"records"`      // registros de paridad y firma y marcador de fin
+	Total        int64 `json:"total"`
+}
+
+// BlockInfo resume un bloque del contenedor.
+type BlockInfo struct {
+	Index          int    `json:"index"`
+	Offset         int64  `json:"offset"`
+	Codec          string `json:"codec"`
+	OriginalSize   int    `json:"originalSize"`
+	CompressedSize int    `json:"compressedSize"` // payload, sin el encabezado del bloque
+	TableSize      int    `json:"tableSize"`      // bytes de la tabla de códigos dentro del payload
+	Error          string `json:"error,omitempty"`
+}
+
+// Inspect lee un archivo .huff, del contenedor o del formato original, y
+// describe su estructura sin escribir la salida. Con opts.Password se leen las
+// tablas de códigos de un contenedor cifrado; sin ella solo la estructura. Los
+// bloques que no se pueden leer se informan en BlockList. Devuelve error si r
+// no es un archivo .huff o si el encabezado de un bloque está dañado.
+func Inspect(r io.Reader, opts Options) (*InspectReport, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(containerMagic))
+	if err != nil || [4]byte(magic) != containerMagic {
+		return inspectLegacy(br)
+	}
+
+	zr, err := newReader(br, Options{Password: opts.Password}, readerMode{allowLocked: true, skipSignature: true})
+	if err != nil {
+		return nil, err
+	}
+	report := &InspectReport{
+		Format:    "container",
+		Version:   int(zr.version),
+		BlockSize: zr.blockSize,
+		Parity:    zr.flags&flagParity != 0,
+		Encrypted: zr.flags&flagEncrypted != 0,
+		Signed:    zr.flags&flagSigned != 0,
+		CodeTable: []CodeInfo{},
+		BlockList: []BlockInfo{},
+	}
+	report.Overhead.Header = int64(len(zr.header))
+	var payloads int64
+	for {
+		frame, err := zr.readFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		block := BlockInfo{
+			Index:          frame.index,
+			Offset:         frame.offset,
+			Codec:          codecName(frame.method),
+			OriginalSize:   frame.rawLen,
+			CompressedSize: len(frame.payload),
+		}
+		report.Blocks++
+		report.OriginalSize += int64(frame.rawLen)
+		report.Overhead.BlockHeaders += int64(len(frame.raw) - len(frame.payload))
+		payloads += int64(len(frame.payload))
+		if report.Codec == "" {
+			report.Codec = block.Codec
+		} else if report.Codec != block.Codec {
+			report.Codec = "mixed"
+		}
+
+		if payload, err := zr.openFrame(frame); err != nil {
+			if !errors.Is(err, ErrPasswordRequired) {
+				block.Error = err.Error()
+			}
+		} else if codes, tableLen, symbols, err := blockTable(frame.method, payload, frame.rawLen); err != nil {
+			block.Error = err.Error()
+		} else if codes != nil {
+			block.TableSize = tableLen
+			report.Overhead.CodeTables += int64(tableLen)
+			if len(report.CodeTable) == 0 {
+				report.CodeTable, report.BitsPerSymbol, report.CodeLengthVariance = codeInfo(codes, countFrequencies(symbols))
+			}
+		}
+		report.BlockList = append(report.BlockList, block)
+	}
+	if zr.signature != nil {
+		report.Signer = hex.EncodeToString(zr.signature[1 : 1+ed25519.PublicKeySize])
+	}
+
+	// Lo que no es encabezado ni bloque son los registros y el marcador de fin
+	report.CompressedSize = zr.offset
+	report.Overhead.Records = report.CompressedSize - report.Overhead.Header - report.Overhead.BlockHeaders - payloads
+	report.finish()
+	return report, nil
+}
+
+// inspectLegacy describe un archivo del formato original: una tabla de
+// códigos seguida de los bits de todo el archivo.
+func inspectLegacy(r io.Reader) (*InspectReport, error) {
+	counted := &countingReader{r: r}
+	codes, err := readCodeTable(counted)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrFormat
+		}
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+	tableLen := counted.n
+	encoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Solo hacen falta las frecuencias: se cuentan sin guardar la salida, que
+	// puede ser ocho veces la entrada
+	frequencies := make(map[byte]int)
+	var originalSize int
+	decodeEach(encoded, codes, func(b byte) error {
+		frequencies[b]++
+		originalSize++
+		return nil
+	})
+	size := tableLen + int64(len(encoded))
+
+	report := &InspectReport{
+		Format:         "legacy",
+		Codec:          "huffman",
+		Blocks:         1,
+		OriginalSize:   int64(originalSize),
+		CompressedSize: size,
+		BlockList: []BlockInfo{{
+			Codec:          "huffman",
+			OriginalSize:   originalSize,
+			CompressedSize: int(size),
+			TableSize:      int(tableLen),
+		}},
+	}
+	report.Overhead.CodeTables = tableLen
+	report.CodeTable, report.BitsPerSymbol, report.CodeLengthVariance = codeInfo(codes, frequencies)
+	report.finish()
+	return report, nil
+}
+
+func (r *InspectReport) finish() {
+	o := &r.Overhead
+	o.Total = o.Header + o.BlockHeaders + o.CodeTables + o.Records
+	if r.OriginalSize > 0 {
+		r.Ratio = float64(r.CompressedSize) / float64(r.OriginalSize)
+	}
+}
+
+// blockTable devuelve la tabla de códigos de un payload ya descifrado, los
+// bytes que ocupa y los símbolos que codifica. Los codecs sin tabla devuelven
+// codes nil.
+func blockTable(method byte, payload []byte, rawLen int) (map[byte]string, int, []byte, error) {
+	prefix := 0
+	switch method {
+	case methodHuffman:
+	case methodRLEHuffman:
+		// El RLE guarda antes su longitud; la tabla codifica los bytes del RLE
+		if len(payload) < 4 {
+			return nil, 0, nil, errCorruptBlock
+		}
+		prefix = 4
+		runsLen := binary.BigEndian.Uint32(payload)
+		if uint64(runsLen) > 2*uint64(rawLen)+1 {
+			return nil, 0, nil, errCorruptBlock
+		}
+		rawLen = int(runsLen)
+	default:
+		return nil, 0, nil, nil
+	}
+	r := bytes.NewReader(payload[prefix:])
+	codes, err := readCodeTable(r)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	tableLen := len(payload) - r.Len()
+	symbols, err := decodeN(payload[tableLen:], codes, rawLen)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return codes, tableLen, symbols, nil
+}
+
+// codeInfo arma la tabla ordenada por longitud de código y símbolo, con las
+// frecuencias dadas, y devuelve la longitud media de código y su varianza,
+// ambas ponderadas por esas frecuencias.
+func codeInfo(codes map[byte]string, frequencies map[byte]int) ([]CodeInfo, float64, float64) {
+	table := make([]CodeInfo, 0, len(codes))
+	total := 0
+	for symbol, code := range codes {
+		table = append(table, CodeInfo{Symbol: symbol, Code: code, Length: len(code), Frequency: frequencies[symbol]})
+		total += frequencies[symbol]
+	}
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Length != table[j].Length {
+			return table[i].Length < table[j].Length
+		}
+		return table[i].Symbol < table[j].Symbol
+	})
+	if total == 0 {
+		return table, 0, 0
+	}
+	mean := 0.0
+	for _, entry := range table {
+		mean += float64(entry.Length) * float64(entry.Frequency) / float64(total)
+	}
+	variance := 0.0
+	for _, entry := range table {
+		d := float64(entry.Length) - mean
+		variance += d * d * float64(entry.Frequency) / float64(total)
+	}
+	return table, mean, variance
+}
+
+func codecName(method byte) string {
+	if c, ok := codecsByID[method]; ok {
+		return c.Name()
+	}
+	return "unknown"
+}
+
+// countingReader cuenta los bytes leídos de r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
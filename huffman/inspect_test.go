@@ -0,0 +1,186 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func compressBytes(t *testing.T, data []byte, opts Options) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw, err := NewWriter(&compressed, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return compressed.Bytes()
+}
+
+// checkInspectSizes comprueba que las partes del reporte sumen el tamaño del archivo.
+func checkInspectSizes(t *testing.T, report *InspectReport, size int) {
+	t.Helper()
+	if report.CompressedSize != int64(size) {
+		t.Errorf("CompressedSize is %d, the file has %d bytes", report.CompressedSize, size)
+	}
+	var payloads int64
+	for _, block := range report.BlockList {
+		payloads += int64(block.CompressedSize)
+	}
+	o := report.Overhead
+	if o.Header+o.BlockHeaders+o.Records+payloads != report.CompressedSize {
+		t.Errorf("Overhead %+v plus %d payload bytes does not add up to %d", o, payloads, report.CompressedSize)
+	}
+	if o.Total != o.Header+o.BlockHeaders+o.CodeTables+o.Records {
+		t.Errorf("Wrong overhead total: %+v", o)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	data := bytes.Repeat([]byte("abracadabra "), 20000)
+	compressed := compressBytes(t, data, Options{BlockSize: 64 << 10})
+	report, err := Inspect(bytes.NewReader(compressed), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInspectSizes(t, report, len(compressed))
+
+	if report.Format != "container" || report.Version != containerVersion || report.Codec != "huffman" {
+		t.Errorf("Unexpected header: %+v", report)
+	}
+	if wantBlocks := (len(data) + 64<<10 - 1) / (64 << 10); report.Blocks != wantBlocks || len(report.BlockList) != wantBlocks {
+		t.Errorf("Expected %d blocks, got %d", wantBlocks, report.Blocks)
+	}
+	if report.OriginalSize != int64(len(data)) {
+		t.Errorf("Expected original size %d, got %d", len(data), report.OriginalSize)
+	}
+
+	// La primera tabla cubre los símbolos de "abracadabra " con sus frecuencias en el bloque
+	if len(report.CodeTable) != 6 {
+		t.Fatalf("Expected 6 symbols, got %+v", report.CodeTable)
+	}
+	total := 0
+	for i, entry := range report.CodeTable {
+		total += entry.Frequency
+		if entry.Length != len(entry.Code) {
+			t.Errorf("Wrong length for %+v", entry)
+		}
+		if i > 0 && entry.Length < report.CodeTable[i-1].Length {
+			t.Error("Code table is not sorted by length")
+		}
+	}
+	if total != 64<<10 {
+		t.Errorf("Frequencies add up to %d instead of the block size", total)
+	}
+	if report.CodeTable[0].Symbol != 'a' {
+		t.Errorf("Expected 'a' to have the shortest code, got %q", report.CodeTable[0].Symbol)
+	}
+	if report.BitsPerSymbol <= 1 || report.BitsPerSymbol >= 8 {
+		t.Errorf("Unexpected bits per symbol %f", report.BitsPerSymbol)
+	}
+	if report.Overhead.CodeTables == 0 || report.BlockList[0].TableSize == 0 {
+		t.Error("Code tables not counted as overhead")
+	}
+}
+
+func TestInspectFeatures(t *testing.T) {
+	data := bytes.Repeat([]byte("inspect every feature "), 5000)
+	pub, priv, _ := GenerateKey()
+	compressed := compressBytes(t, data, Options{Codec: "rle-huffman", BlockSize: 8 << 10, Parity: 2, Password: "secret", SigningKey: priv})
+
+	// Sin contraseña se ve la estructura pero no las tablas
+	report, err := Inspect(bytes.NewReader(compressed), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInspectSizes(t, report, len(compressed))
+	if !report.Parity || !report.Encrypted || !report.Signed || report.Signer != hex.EncodeToString(pub) {
+		t.Errorf("Unexpected flags: %+v", report)
+	}
+	if len(report.CodeTable) != 0 || report.Overhead.CodeTables != 0 {
+		t.Error("Code tables read without the password")
+	}
+	if report.Overhead.Records <= 1 {
+		t.Error("Parity and signature records not counted")
+	}
+
+	report, err = Inspect(bytes.NewReader(compressed), Options{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Codec != "rle-huffman" || len(report.CodeTable) == 0 {
+		t.Errorf("Expected the rle-huffman table with the password, got %+v", report)
+	}
+	for _, block := range report.BlockList {
+		if block.Error != "" {
+			t.Errorf("Block %d: %s", block.Index, block.Error)
+		}
+	}
+
+	// Con auto los bloques pueden usar codecs distintos
+	mixed := append(bytes.Repeat([]byte{0}, 16<<10), compressBytes(t, data, Options{})...)
+	report, err = Inspect(bytes.NewReader(compressBytes(t, mixed, Options{Codec: CodecAuto, BlockSize: 16 << 10})), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Codec != "mixed" {
+		t.Errorf("Expected mixed codecs, got %s", report.Codec)
+	}
+}
+
+func TestInspectLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.huff")
+	data := []byte("legacy format")
+	codes := generateCodes(buildHuffmanTree(countFrequencies(data)))
+	if err := saveCompressedFile(path, encode(data, codes), codes); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.ReadFile(path)
+
+	report, err := Inspect(bytes.NewReader(file), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInspectSizes(t, report, len(file))
+	if report.Format != "legacy" || len(report.CodeTable) != len(codes) {
+		t.Errorf("Unexpected legacy report: %+v", report)
+	}
+
+	for _, garbage := range [][]byte{nil, []byte("HUF"), []byte("not a compressed file at all")} {
+		if _, err := Inspect(bytes.NewReader(garbage), Options{}); !errors.Is(err, ErrFormat) {
+			t.Errorf("%q: expected ErrFormat, got %v", garbage, err)
+		}
+	}
+}
+
+// Con un código de un bit cada byte del formato original da ocho símbolos;
+// Inspect solo los cuenta y no guarda la salida.
+func TestInspectLegacyBomb(t *testing.T) {
+	const input = 1 << 20
+	var file bytes.Buffer
+	if err := writeCodeTable(&file, map[byte]string{'a': "0"}); err != nil {
+		t.Fatal(err)
+	}
+	file.Write(make([]byte, input))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	report, err := Inspect(&file, Options{})
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OriginalSize != 8*input || report.CodeTable[0].Frequency != 8*input {
+		t.Errorf("Unexpected legacy report: %+v", report)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4*input {
+		t.Errorf("Inspect allocated %d bytes for a %d byte file", allocated, input)
+	}
+}
@@ -0,0 +1,45 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded es el error con el que coinciden (errors.Is) todos los
+// *LimitError.
+var ErrLimitExceeded = errors.New("huffman: decompression limit exceeded")
+
+// ratioGrace es la salida a partir de la cual se comprueba MaxRatio: los
+// archivos pequeños muy repetitivos expanden mucho sin ser peligrosos.
+const ratioGrace = 1 << 20
+
+// LimitError indica que la descompresión se detuvo porque la salida iba a
+// superar Options.MaxOutput u Options.MaxRatio. Se devuelve antes de
+// decodificar el bloque que cruza el límite.
+type LimitError struct {
+	Output    int64   // bytes descomprimidos que se habrían alcanzado
+	Input     int64   // bytes comprimidos leídos hasta entonces
+	MaxOutput int64   // límite de tamaño superado, o 0
+	MaxRatio  float64 // límite de razón superado, o 0
+}
+
+func (e *LimitError) Error() string {
+	if e.MaxOutput > 0 {
+		return fmt.Sprintf("huffman: decompressed size %d exceeds the limit of %d bytes", e.Output, e.MaxOutput)
+	}
+	return fmt.Sprintf("huffman: expansion ratio %.0f exceeds the limit of %.0f", float64(e.Output)/float64(e.Input), e.MaxRatio)
+}
+
+func (e *LimitError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// checkLimits devuelve un *LimitError si producir output bytes a partir de
+// input bytes comprimidos supera los límites de opts.
+func checkLimits(output, input int64, opts Options) error {
+	if opts.MaxOutput > 0 && output > opts.MaxOutput {
+		return &LimitError{Output: output, Input: input, MaxOutput: opts.MaxOutput}
+	}
+	if opts.MaxRatio > 0 && output > ratioGrace && float64(output) > opts.MaxRatio*float64(max(input, 1)) {
+		return &LimitError{Output: output, Input: input, MaxRatio: opts.MaxRatio}
+	}
+	return nil
+}
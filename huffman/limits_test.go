@@ -0,0 +1,150 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zeroBomb devuelve un contenedor pequeño que se expande a size bytes de ceros.
+func zeroBomb(t *testing.T, size int) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw, err := NewWriter(&compressed, Options{Codec: "rle-huffman"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return compressed.Bytes()
+}
+
+func TestReaderLimits(t *testing.T) {
+	const size = 8 << 20
+	bomb := zeroBomb(t, size)
+	t.Logf("Bomb: %d bytes expand to %d (ratio %.0f)", len(bomb), size, float64(size)/float64(len(bomb)))
+
+	cases := []struct {
+		name      string
+		opts      Options
+		maxOutput int64 // salida máxima que se puede haber entregado antes del error
+	}{
+		{"output", Options{MaxOutput: 3 << 20}, 3 << 20},
+		{"ratio", Options{MaxRatio: 50}, 2 << 20},
+	}
+	for _, c := range cases {
+		zr, err := NewReader(bytes.NewReader(bomb), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := io.Copy(io.Discard, zr)
+		var limitErr *LimitError
+		if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitErr) {
+			t.Fatalf("%s: expected a LimitError, got %v", c.name, err)
+		}
+		if n > c.maxOutput {
+			t.Errorf("%s: %d bytes were decoded before stopping", c.name, n)
+		}
+		if limitErr.MaxOutput != c.opts.MaxOutput || limitErr.MaxRatio != c.opts.MaxRatio {
+			t.Errorf("%s: error reports the wrong limit: %+v", c.name, limitErr)
+		}
+	}
+
+	// Dentro de los límites la bomba se decodifica con normalidad
+	zr, _ := NewReader(bytes.NewReader(bomb), Options{MaxOutput: size, MaxRatio: 1e6})
+	if n, err := io.Copy(io.Discard, zr); n != size || err != nil {
+		t.Errorf("Expected %d bytes within the limits, got %d, %v", size, n, err)
+	}
+
+	// La razón no rechaza archivos pequeños muy repetitivos
+	small := roundTrip(t, make([]byte, 64<<10), Options{Codec: "rle-huffman"})
+	zr, _ = NewReader(bytes.NewReader(small), Options{MaxRatio: 2})
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		t.Errorf("Small file rejected: %v", err)
+	}
+}
+
+// Un bloque que declara una longitud excesiva se rechaza antes de decodificarlo.
+func TestReaderLimitsDeclaredLength(t *testing.T) {
+	var compressed bytes.Buffer
+	zw, _ := NewWriter(&compressed, Options{Codec: "rle", BlockSize: 4 << 20})
+	zw.Write(make([]byte, 4<<20))
+	zw.Close()
+
+	zr, err := NewReader(bytes.NewReader(compressed.Bytes()), Options{MaxOutput: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, zr)
+	if n != 0 || !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected to stop before the first block, got %d bytes, %v", n, err)
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	dir := t.TempDir()
+	bombFile := filepath.Join(dir, "bomb.huff")
+	if err := os.WriteFile(bombFile, zeroBomb(t, 4<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "bomb.out")
+	err := DecompressWithOptions(bombFile, output, Options{MaxOutput: 1 << 20})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("Partial output left behind")
+	}
+
+	// En el formato original un código de un bit convierte cada bit de entrada en un byte
+	legacy := filepath.Join(dir, "legacy.huff")
+	if err := saveCompressedFile(legacy, make([]byte, 256<<10), map[byte]string{'a': "0"}); err != nil {
+		t.Fatal(err)
+	}
+	err = DecompressWithOptions(legacy, output, Options{MaxOutput: 1 << 20})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected ErrLimitExceeded for the legacy bomb, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("Legacy bomb output left behind")
+	}
+}
+
+// El formato original se detiene en el primer símbolo que supera el límite en
+// lugar de decodificar todo el archivo antes de comprobarlo.
+func TestDecodeLegacyLimits(t *testing.T) {
+	const input = 1 << 20 // 8 MiB de salida con un código de un bit
+	encoded := make([]byte, input)
+	codes := map[byte]string{'a': "0"}
+
+	cases := []struct {
+		name   string
+		opts   Options
+		output int64 // salida en la que debe detenerse
+	}{
+		{"output", Options{MaxOutput: 3 << 20}, 3<<20 + 1},
+		{"ratio", Options{MaxRatio: 2}, 2<<20 + 1},
+		{"grace", Options{MaxRatio: 0.5}, ratioGrace + 1},
+	}
+	for _, c := range cases {
+		out, err := decode(encoded, codes, c.opts)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || out != nil {
+			t.Fatalf("%s: expected a LimitError, got %d bytes, %v", c.name, len(out), err)
+		}
+		if limitErr.Output != c.output || limitErr.Input != input {
+			t.Errorf("%s: stopped at %d bytes from %d, expected %d", c.name, limitErr.Output, limitErr.Input, c.output)
+		}
+	}
+
+	if out, err := decode(encoded, codes, Options{MaxOutput: 8 * input, MaxRatio: 8}); len(out) != 8*input || err != nil {
+		t.Errorf("Expected %d bytes within the limits, got %d, %v", 8*input, len(out), err)
+	}
+}
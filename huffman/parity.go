@@ -0,0 +1,402 @@
+package huffman
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Paridad Reed-Solomon por grupos de bloques.
+//
+// Cuando Options.Parity > 0 los bloques se juntan en grupos de hasta
+// parityGroupBlocks y cada grupo se escribe así:
+//
+//	method     uint8 = methodParity
+//	k          uint8           bloques de datos del grupo
+//	m          uint8           fragmentos de paridad
+//	shardLen   uint32          longitud de cada fragmento
+//	lens       k × uint32      longitud de cada bloque (encabezado incluido)
+//	parityCRCs m × uint32      CRC32C de cada fragmento de paridad
+//	headerCRC  uint32          CRC32C de todo lo anterior
+//	paridad    m × shardLen
+//	bloques    los k bloques tal cual, uno detrás de otro
+//
+// Cada bloque completo es un fragmento de datos rellenado con ceros hasta
+// shardLen. La paridad es P_j = Σ c(j,i)·D_i sobre GF(2^8) con c(j,i) = 1/(j ⊕ (m+i)),
+// una matriz de Cauchy: cualquier submatriz cuadrada es invertible, así que con m
+// fragmentos de paridad se recuperan hasta m bloques dañados en cualquier posición.
+// Como las longitudes están en el registro del grupo, un bloque con el
+// encabezado dañado también se puede ubicar y reconstruir.
+
+const (
+	methodParity = 0xFE
+
+	// MaxParity es el máximo de fragmentos de paridad por grupo.
+	MaxParity         = parityGroupBlocks
+	parityGroupBlocks = 16
+)
+
+// ErrUnrepairable indica que un grupo tiene más bloques dañados que fragmentos de paridad sanos.
+var ErrUnrepairable = errors.New("huffman: too many corrupt blocks to repair")
+
+// Tablas de logaritmos y exponenciales de GF(2^8) con el polinomio x^8+x^4+x^3+x^2+1.
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// cauchy devuelve el coeficiente del bloque i en el fragmento de paridad j.
+func cauchy(j, i, m int) byte {
+	return gfInv(byte(j) ^ byte(m+i))
+}
+
+// mulAdd hace dst ^= c·src byte a byte.
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	logC := int(gfLog[c])
+	for x, b := range src {
+		if b != 0 {
+			dst[x] ^= gfExp[logC+int(gfLog[b])]
+		}
+	}
+}
+
+// rsEncode calcula m fragmentos de paridad de longitud shardLen para los datos dados.
+func rsEncode(data [][]byte, m, shardLen int) [][]byte {
+	parity := make([][]byte, m)
+	for j := range parity {
+		parity[j] = make([]byte, shardLen)
+		for i, shard := range data {
+			mulAdd(parity[j], shard, cauchy(j, i, m))
+		}
+	}
+	return parity
+}
+
+// rsReconstruct rellena los fragmentos de datos nil usando los fragmentos de
+// paridad no nil. Necesita al menos tantos fragmentos de paridad como datos faltantes.
+func rsReconstruct(data [][]byte, parity [][]byte, shardLen int) error {
+	m := len(parity)
+	var missing, rows []int
+	for i, shard := range data {
+		if shard == nil {
+			missing = append(missing, i)
+		}
+	}
+	for j, shard := range parity {
+		if shard != nil && len(rows) < len(missing) {
+			rows = append(rows, j)
+		}
+	}
+	if len(rows) < len(missing) {
+		return ErrUnrepairable
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Restar de cada paridad el aporte de los bloques sanos: queda un sistema
+	// A·X = B con A la submatriz de Cauchy de los bloques faltantes.
+	e := len(missing)
+	a := make([][]byte, e)
+	b := make([][]byte, e)
+	for r, j := range rows {
+		b[r] = append([]byte(nil), parity[j]...)
+		for i, shard := range data {
+			if shard != nil {
+				mulAdd(b[r], shard, cauchy(j, i, m))
+			}
+		}
+		a[r] = make([]byte, e)
+		for c, i := range missing {
+			a[r][c] = cauchy(j, i, m)
+		}
+	}
+
+	// Eliminación de Gauss-Jordan sobre GF(2^8), aplicando las mismas operaciones a B.
+	for col := 0; col < e; col++ {
+		pivot := col
+		for pivot < e && a[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == e {
+			return ErrUnrepairable
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		inv := gfInv(a[col][col])
+		for c := range a[col] {
+			a[col][c] = gfMul(a[col][c], inv)
+		}
+		scaled := make([]byte, shardLen)
+		mulAdd(scaled, b[col], inv)
+		b[col] = scaled
+
+		for r := 0; r < e; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			factor := a[r][col]
+			for c := range a[r] {
+				a[r][c] ^= gfMul(factor, a[col][c])
+			}
+			mulAdd(b[r], b[col], factor)
+		}
+	}
+
+	for c, i := range missing {
+		data[i] = b[c]
+	}
+	return nil
+}
+
+// writeGroup escribe el registro de paridad del grupo actual seguido de sus bloques.
+func (z *Writer) writeGroup() error {
+	k, m := len(z.group), z.parity
+	shardLen := 0
+	for _, frame := range z.group {
+		shardLen = max(shardLen, len(frame))
+	}
+	parity := rsEncode(z.group, m, shardLen)
+
+	record := make([]byte, 0, 7+4*k+4*m+4)
+	record = append(record, methodParity, byte(k), byte(m))
+	record = binary.BigEndian.AppendUint32(record, uint32(shardLen))
+	for _, frame := range z.group {
+		record = binary.BigEndian.AppendUint32(record, uint32(len(frame)))
+	}
+	for _, shard := range parity {
+		record = binary.BigEndian.AppendUint32(record, crc32.Checksum(shard, castagnoli))
+	}
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(record, castagnoli))
+
+	if _, err := z.w.Write(record); err != nil {
+		z.err = err
+		return err
+	}
+	for _, chunk := range append(parity, z.group...) {
+		if _, err := z.w.Write(chunk); err != nil {
+			z.err = err
+			return err
+		}
+	}
+	z.group = z.group[:0]
+	return nil
+}
+
+// readGroup lee un grupo de paridad completo (el byte methodParity ya fue
+// consumido), reconstruye los bloques dañados si puede y los deja en la cola.
+func (z *Reader) readGroup() error {
+	groupErr := func(err error) error {
+		return &BlockError{Index: z.index, Offset: z.offset, Err: err}
+	}
+
+	var fixed [6]byte
+	if _, err := io.ReadFull(z.r, fixed[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	k, m := int(fixed[0]), int(fixed[1])
+	shardLen := int(binary.BigEndian.Uint32(fixed[2:]))
+	rest := make([]byte, 4*k+4*m+4)
+	if _, err := io.ReadFull(z.r, rest); err != nil {
+		return unexpectedEOF(err)
+	}
+	record := append(append([]byte{methodParity}, fixed[:]...), rest...)
+	if crc32.Checksum(record[:len(record)-4], castagnoli) != binary.BigEndian.Uint32(record[len(record)-4:]) {
+		return groupErr(fmt.Errorf("%w: parity header", ErrChecksum))
+	}
+	// Con más bloques o fragmentos que los que usa el escritor los coeficientes
+	// de cauchy se repetirían y la matriz dejaría de ser invertible
+	if k == 0 || m == 0 || k > parityGroupBlocks || m > MaxParity || shardLen > frameHeaderLen+z.maxPayloadLen() {
+		return groupErr(fmt.Errorf("%w: parity header", ErrFormat))
+	}
+	lens := make([]int, k)
+	for i := range lens {
+		lens[i] = int(binary.BigEndian.Uint32(rest[4*i:]))
+		if lens[i] > shardLen {
+			return groupErr(fmt.Errorf("%w: parity header", ErrFormat))
+		}
+	}
+
+	// Leer la paridad y descartar los fragmentos que no coinciden con su CRC
+	parity := make([][]byte, m)
+	for j := range parity {
+		parity[j] = make([]byte, shardLen)
+		if _, err := io.ReadFull(z.r, parity[j]); err != nil {
+			return unexpectedEOF(err)
+		}
+		if crc32.Checksum(parity[j], castagnoli) != binary.BigEndian.Uint32(rest[4*k+4*j:]) {
+			parity[j] = nil
+		}
+	}
+	z.offset += int64(len(record) + m*shardLen)
+	z.parity = m
+
+	// Leer los bloques y marcar como faltantes los dañados
+	frames := make([]*blockFrame, k)
+	shards := make([][]byte, k)
+	var damage []error
+	for i := range frames {
+		raw := make([]byte, lens[i])
+		if _, err := io.ReadFull(z.r, raw); err != nil {
+			return unexpectedEOF(err)
+		}
+		frames[i] = &blockFrame{index: z.index + i, offset: z.offset}
+		z.offset += int64(lens[i])
+		if err := z.parseGroupFrame(raw, frames[i]); err != nil {
+			damage = append(damage, err)
+			frames[i].repaired = err
+			continue
+		}
+		shards[i] = raw
+	}
+	z.index += k
+
+	if len(damage) > 0 {
+		// Los fragmentos se rellenan con ceros hasta shardLen para operar.
+		padded := make([][]byte, k)
+		for i, shard := range shards {
+			if shard != nil {
+				padded[i] = make([]byte, shardLen)
+				copy(padded[i], shard)
+			}
+		}
+		err := rsReconstruct(padded, parity, shardLen)
+		for i, frame := range frames {
+			if frame.repaired == nil {
+				continue
+			}
+			if err != nil {
+				frame.err, frame.repaired = frame.repaired, nil
+				continue
+			}
+			if perr := z.parseGroupFrame(padded[i][:lens[i]], frame); perr != nil {
+				frame.err, frame.repaired = frame.repaired, nil
+			}
+		}
+	}
+	z.queue = append(z.queue, frames...)
+	return nil
+}
+
+// parseGroupFrame interpreta un bloque de un grupo de paridad, cuya longitud
+// total ya se conoce, y comprueba que esté intacto.
+func (z *Reader) parseGroupFrame(raw []byte, frame *blockFrame) error {
+	headerLen := z.frameHeaderLen()
+	if len(raw) < headerLen {
+		return errCorruptBlock
+	}
+	payloadLen, err := z.parseFrameHeader(raw[:headerLen], frame)
+	if err != nil {
+		return err
+	}
+	if headerLen+payloadLen != len(raw) {
+		return errCorruptBlock
+	}
+	frame.raw = raw
+	frame.payload = raw[headerLen:]
+	if !frame.payloadOK() {
+		return fmt.Errorf("%w: compressed data", ErrChecksum)
+	}
+	return nil
+}
+
+// Repair lee un contenedor de r, reconstruye los bloques dañados con la
+// paridad y escribe en w un contenedor sano con paridad recalculada. Los
+// bloques no se recomprimen ni se descifran y la firma se copia sin
+// verificarla: si la reparación es completa vuelve a ser válida. Devuelve ErrUnrepairable si algún bloque no se
+// puede recuperar; en ese caso lo escrito en w está incompleto.
+func Repair(r io.Reader, w io.Writer) (*VerifyReport, error) {
+	zr, err := newReader(r, Options{}, readerMode{allowLocked: true, skipSignature: true})
+	if err != nil {
+		return nil, err
+	}
+	if zr.version == 1 {
+		return nil, fmt.Errorf("%w: version 1 containers cannot be repaired", ErrFormat)
+	}
+	report := &VerifyReport{
+		Version:       int(zr.version),
+		Parity:        zr.flags&flagParity != 0,
+		Encrypted:     zr.flags&flagEncrypted != 0,
+		CorruptBlocks: []CorruptInfo{},
+	}
+	// El encabezado se copia tal cual: un contenedor cifrado se repara sin la contraseña.
+	zw := &Writer{ctx: context.Background(), w: w, blockSize: zr.blockSize, header: zr.header}
+
+	for {
+		frame, err := zr.readFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var blockErr *BlockError
+			if errors.As(err, &blockErr) {
+				report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(blockErr, false))
+				report.Truncated = true
+				return report, fmt.Errorf("%w: %v", ErrUnrepairable, err)
+			}
+			return nil, err
+		}
+
+		report.Blocks++
+		report.OriginalSize += int64(frame.rawLen)
+		if frame.repaired != nil {
+			report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(&BlockError{Index: frame.index, Offset: frame.offset, Err: frame.repaired}, true))
+		}
+		damage := frame.err
+		if damage == nil && !frame.payloadOK() {
+			damage = fmt.Errorf("%w: compressed data", ErrChecksum)
+		}
+		if damage != nil {
+			blockErr := &BlockError{Index: frame.index, Offset: frame.offset, Err: damage}
+			report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(blockErr, false))
+			return report, fmt.Errorf("%w: %v", ErrUnrepairable, blockErr)
+		}
+
+		// La paridad de la salida usa la misma cantidad de fragmentos que la entrada.
+		zw.parity = zr.parity
+		if err := zw.writeFrame(frame.raw); err != nil {
+			return nil, err
+		}
+	}
+	if report.Parity && zw.parity == 0 {
+		zw.parity = 1
+	}
+	// La firma cubre los bytes originales, que la reparación restituye.
+	zw.trailer = zr.signature
+	zw.endTag = zr.endTag
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
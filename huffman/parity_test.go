@@ -0,0 +1,209 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// parityFrameOffsets devuelve el desplazamiento de cada bloque de un contenedor con paridad.
+func parityFrameOffsets(t *testing.T, compressed []byte) []int {
+	t.Helper()
+	var offsets []int
+	pos := containerHeaderLen
+	for compressed[pos] == methodParity {
+		k, m := int(compressed[pos+1]), int(compressed[pos+2])
+		shardLen := int(binary.BigEndian.Uint32(compressed[pos+3:]))
+		lens := compressed[pos+7:]
+		pos += 7 + 4*k + 4*m + 4 + m*shardLen
+		for i := 0; i < k; i++ {
+			offsets = append(offsets, pos)
+			pos += int(binary.BigEndian.Uint32(lens[4*i:]))
+		}
+	}
+	if compressed[pos] != methodEnd {
+		t.Fatalf("Expected end marker at offset %d", pos)
+	}
+	return offsets
+}
+
+func parityTestData() []byte {
+	rng := rand.New(rand.NewSource(3))
+	words := []string{"huffman ", "parity ", "shard ", "block ", "galois ", "field "}
+	var data bytes.Buffer
+	for data.Len() < 40*1024 {
+		data.WriteString(words[rng.Intn(len(words))])
+	}
+	return data.Bytes()[:40*1024]
+}
+
+func compressWithParity(t *testing.T, data []byte, parity int) []byte {
+	t.Helper()
+	return roundTrip(t, data, Options{BlockSize: 1024, Parity: parity})
+}
+
+func TestParityRecoversCorruptBlocks(t *testing.T) {
+	data := parityTestData()
+	compressed := compressWithParity(t, data, 2)
+	offsets := parityFrameOffsets(t, compressed)
+	if len(offsets) != 40 {
+		t.Fatalf("Expected 40 blocks, got %d", len(offsets))
+	}
+
+	// Dos bloques dañados en el primer grupo (uno en el encabezado) y uno en el segundo
+	compressed[offsets[3]+frameHeaderLen+5] ^= 0x20
+	compressed[offsets[9]+2] ^= 0x80
+	compressed[offsets[20]+frameHeaderLen] ^= 0xFF
+
+	zr, err := NewReader(bytes.NewReader(compressed), Options{})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Decompression failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("Repaired data does not match original data")
+	}
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() || !report.Parity || len(report.CorruptBlocks) != 3 {
+		t.Fatalf("Expected three repaired blocks, got %+v", report)
+	}
+	for _, block := range report.CorruptBlocks {
+		if !block.Repaired {
+			t.Errorf("Block %d was not repaired", block.Index)
+		}
+	}
+}
+
+func TestParityTooManyCorruptBlocks(t *testing.T) {
+	data := parityTestData()
+	compressed := compressWithParity(t, data, 1)
+	offsets := parityFrameOffsets(t, compressed)
+	compressed[offsets[1]+frameHeaderLen+1] ^= 0x01
+	compressed[offsets[2]+frameHeaderLen+1] ^= 0x01
+
+	zr, err := NewReader(bytes.NewReader(compressed), Options{})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	_, err = io.ReadAll(zr)
+	var blockErr *BlockError
+	if !errors.As(err, &blockErr) || blockErr.Index != 1 {
+		t.Fatalf("Expected error in block 1, got %v", err)
+	}
+
+	// Con las longitudes del grupo los bloques irrecuperables se pueden saltar
+	zr, err = NewReader(bytes.NewReader(compressed), Options{SkipCorrupt: true})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Decompression with SkipCorrupt failed: %v", err)
+	}
+	if len(decompressed) != len(data)-2*1024 || len(zr.Skipped()) != 2 {
+		t.Errorf("Expected two skipped blocks, got %d bytes and %v", len(decompressed), zr.Skipped())
+	}
+
+	if _, err := Repair(bytes.NewReader(compressed), io.Discard); !errors.Is(err, ErrUnrepairable) {
+		t.Errorf("Expected ErrUnrepairable, got %v", err)
+	}
+}
+
+// Un registro de paridad con más bloques o fragmentos que los del escritor se
+// rechaza aunque su CRC sea correcto.
+func TestParityRejectsOversizedGroup(t *testing.T) {
+	compressed := compressWithParity(t, parityTestData(), 1)
+	for _, km := range [][2]int{{parityGroupBlocks + 1, 1}, {1, MaxParity + 1}, {200, 56}} {
+		k, m := km[0], km[1]
+		record := []byte{methodParity, byte(k), byte(m), 0, 0, 0, 1}
+		record = append(record, make([]byte, 4*k+4*m)...)
+		record = binary.BigEndian.AppendUint32(record, crc32.Checksum(record, castagnoli))
+		crafted := append(append([]byte(nil), compressed[:containerHeaderLen]...), record...)
+
+		zr, err := NewReader(bytes.NewReader(crafted), Options{})
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if _, err := io.ReadAll(zr); !errors.Is(err, ErrFormat) {
+			t.Errorf("k=%d m=%d: expected ErrFormat, got %v", k, m, err)
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	data := parityTestData()
+	original := compressWithParity(t, data, 3)
+	offsets := parityFrameOffsets(t, original)
+
+	damaged := append([]byte(nil), original...)
+	for _, i := range []int{16, 17, 31} {
+		damaged[offsets[i]+frameHeaderLen+7] ^= 0x55
+	}
+	// También un fragmento de paridad del primer grupo
+	damaged[containerHeaderLen+7+4*16+4*3+4+10] ^= 0x01
+
+	var repaired bytes.Buffer
+	report, err := Repair(bytes.NewReader(damaged), &repaired)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(report.CorruptBlocks) != 3 {
+		t.Errorf("Expected three repaired blocks, got %+v", report)
+	}
+	if !bytes.Equal(repaired.Bytes(), original) {
+		t.Error("Repaired container differs from the original")
+	}
+}
+
+func TestReedSolomonReconstruct(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	k, m, shardLen := 6, 3, 64
+	data := make([][]byte, k)
+	for i := range data {
+		data[i] = make([]byte, shardLen)
+		rng.Read(data[i])
+	}
+	parity := rsEncode(data, m, shardLen)
+
+	// Todas las combinaciones de hasta m fragmentos perdidos entre datos y paridad
+	for mask := 0; mask < 1<<(k+m); mask++ {
+		lost := 0
+		for b := mask; b != 0; b &= b - 1 {
+			lost++
+		}
+		if lost > m {
+			continue
+		}
+		shards := make([][]byte, k)
+		for i := range shards {
+			if mask&(1<<i) == 0 {
+				shards[i] = data[i]
+			}
+		}
+		available := make([][]byte, m)
+		for j := range available {
+			if mask&(1<<(k+j)) == 0 {
+				available[j] = parity[j]
+			}
+		}
+		if err := rsReconstruct(shards, available, shardLen); err != nil {
+			t.Fatalf("Mask %b: reconstruct failed: %v", mask, err)
+		}
+		for i := range shards {
+			if !bytes.Equal(shards[i], data[i]) {
+				t.Fatalf("Mask %b: shard %d reconstructed incorrectly", mask, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package huffman
+
+import "io"
+
+// Phase indica en qué etapa está la compresión o la descompresión.
+type Phase string
+
+const (
+	PhaseCounting     Phase = "counting"      // contando las frecuencias del bloque
+	PhaseBuildingTree Phase = "building tree" // construyendo el árbol y la tabla de códigos
+	PhaseEncoding     Phase = "encoding"      // codificando y escribiendo el bloque
+	PhaseDecoding     Phase = "decoding"      // leyendo y decodificando bloques
+	PhaseDone         Phase = "done"
+)
+
+// Progress es lo que recibe Options.Progress. Al comprimir BytesRead cuenta
+// los datos originales ya procesados y BytesWritten los bytes del contenedor
+// escritos; al descomprimir BytesRead cuenta bytes del contenedor y
+// BytesWritten datos descomprimidos.
+type Progress struct {
+	Phase        Phase
+	Block        int // índice del bloque en curso
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// countingWriter cuenta los bytes escritos en w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (z *Writer) report(phase Phase) {
+	if z.progress != nil {
+		z.progress(Progress{Phase: phase, Block: z.blocks, BytesRead: z.read, BytesWritten: z.counter.n})
+	}
+}
+
+func (z *Reader) report(phase Phase) {
+	if z.progress != nil {
+		z.progress(Progress{Phase: phase, Block: z.index, BytesRead: z.offset, BytesWritten: z.written})
+	}
+}
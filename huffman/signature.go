@@ -0,0 +1,180 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Firma digital del contenedor.
+//
+// Con flagSigned, antes del marcador de fin va un registro de firma:
+//
+//	method    uint8 = methodSignature
+//	publicKey [32]byte  clave Ed25519 del firmante
+//	digest    [32]byte  SHA-256 de todos los bytes anteriores al registro
+//	signature [64]byte  firma Ed25519 de signatureContext + digest
+//
+// Después del registro solo puede venir el marcador de fin; cualquier otro
+// byte no está firmado y se rechaza con ErrSignature.
+//
+// El digest cubre el encabezado (incluido flagSigned, así que quitar la firma
+// se detecta) y todos los bloques y registros de paridad tal como están en el
+// archivo. En un contenedor cifrado la firma cubre el texto cifrado y se puede
+// verificar sin la contraseña.
+
+const (
+	flagSigned = 1 << 2
+
+	methodSignature    = 0xFD
+	signatureRecordLen = 1 + ed25519.PublicKeySize + sha256.Size + ed25519.SignatureSize
+)
+
+var signatureContext = []byte("HUFC signature v1\x00")
+
+var (
+	// ErrSignature indica que la firma falta, no es válida o no corresponde a la clave esperada.
+	ErrSignature = errors.New("huffman: invalid signature")
+	// ErrNotSigned indica que se pidió verificar la firma de un contenedor sin firmar.
+	ErrNotSigned = errors.New("huffman: container is not signed")
+)
+
+// hashReader lleva el SHA-256 de todo lo que se lee, cuando el contenedor está firmado.
+type hashReader struct {
+	r *bufio.Reader
+	h hash.Hash // nil si no hay firma que verificar
+}
+
+func (hr *hashReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	if hr.h != nil {
+		hr.h.Write(p[:n])
+	}
+	return n, err
+}
+
+func (hr *hashReader) ReadByte() (byte, error) {
+	b, err := hr.r.ReadByte()
+	if err == nil && hr.h != nil {
+		hr.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (hr *hashReader) Peek(n int) ([]byte, error) {
+	return hr.r.Peek(n)
+}
+
+// signatureRecord arma el registro de firma para el digest dado.
+func signatureRecord(key ed25519.PrivateKey, digest []byte) []byte {
+	record := make([]byte, 0, signatureRecordLen)
+	record = append(record, methodSignature)
+	record = append(record, key.Public().(ed25519.PublicKey)...)
+	record = append(record, digest...)
+	return append(record, ed25519.Sign(key, append(signatureContext, digest...))...)
+}
+
+// readSignature lee el registro de firma y lo compara con el digest de lo
+// leído hasta ahora.
+func (z *Reader) readSignature() error {
+	var digest []byte
+	if z.r.h != nil {
+		digest = z.r.h.Sum(nil)
+	}
+	record := make([]byte, signatureRecordLen)
+	if _, err := io.ReadFull(z.r, record); err != nil {
+		return unexpectedEOF(err)
+	}
+	z.offset += signatureRecordLen
+	z.signature = record
+	if z.mode.skipSignature {
+		return nil
+	}
+
+	publicKey := ed25519.PublicKey(record[1 : 1+ed25519.PublicKeySize])
+	signed := record[1+ed25519.PublicKeySize : 1+ed25519.PublicKeySize+sha256.Size]
+	sig := record[1+ed25519.PublicKeySize+sha256.Size:]
+	if !bytes.Equal(signed, digest) {
+		return fmt.Errorf("%w: content does not match the signed digest", ErrSignature)
+	}
+	if !ed25519.Verify(publicKey, append(signatureContext, signed...), sig) {
+		return fmt.Errorf("%w: bad signature", ErrSignature)
+	}
+	if z.verifyKey != nil && !publicKey.Equal(z.verifyKey) {
+		return fmt.Errorf("%w: signed by %s", ErrSignature, hex.EncodeToString(publicKey))
+	}
+	z.signer = publicKey
+	return nil
+}
+
+// Signer devuelve la clave pública con la que se verificó la firma, o nil si
+// el contenedor no está firmado o todavía no se leyó hasta el final.
+func (z *Reader) Signer() ed25519.PublicKey {
+	return z.signer
+}
+
+// GenerateKey crea un par de claves Ed25519 para firmar contenedores.
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// MarshalPrivateKey codifica una clave privada en PEM (PKCS #8).
+func MarshalPrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKey codifica una clave pública en PEM (PKIX).
+func MarshalPublicKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey lee una clave privada Ed25519 escrita por MarshalPrivateKey.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("huffman: no PEM private key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("huffman: private key is not Ed25519")
+	}
+	return priv, nil
+}
+
+// ParsePublicKey lee una clave pública Ed25519 escrita por MarshalPublicKey.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("huffman: no PEM public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("huffman: public key is not Ed25519")
+	}
+	return pub, nil
+}
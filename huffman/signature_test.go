@@ -0,0 +1,161 @@
+package huffman
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+func signedContainer(t *testing.T, data []byte, opts Options) ([]byte, ed25519.PublicKey) {
+	t.Helper()
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	opts.SigningKey = priv
+	var compressed bytes.Buffer
+	zw, err := NewWriter(&compressed, opts)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return compressed.Bytes(), pub
+}
+
+func readSigned(compressed []byte, opts Options) ([]byte, *Reader, error) {
+	zr, err := NewReader(bytes.NewReader(compressed), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(zr)
+	return data, zr, err
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("dataset row;"), 3000)
+	compressed, pub := signedContainer(t, data, Options{BlockSize: 4096})
+
+	decompressed, zr, err := readSigned(compressed, Options{VerifyKey: pub})
+	if err != nil {
+		t.Fatalf("Decompression failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("Decompressed data does not match original data")
+	}
+	if !zr.Signer().Equal(pub) {
+		t.Errorf("Signer %x does not match %x", zr.Signer(), pub)
+	}
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() || !report.Signed || report.Signer != hex.EncodeToString(pub) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestSignatureDetectsTampering(t *testing.T) {
+	data := bytes.Repeat([]byte("dataset row;"), 3000)
+	compressed, pub := signedContainer(t, data, Options{BlockSize: 4096})
+
+	// Cambiar un byte del primer bloque y recalcular sus CRC: solo la firma lo detecta
+	frame := compressed[containerHeaderLen:]
+	payload := frame[frameHeaderLen : frameHeaderLen+int(binary.BigEndian.Uint32(frame[5:]))]
+	payload[len(payload)-1] ^= 0x01
+	binary.BigEndian.PutUint32(frame[13:], crc32.Checksum(payload, castagnoli))
+	binary.BigEndian.PutUint32(frame[17:], crc32.Checksum(frame[:17], castagnoli))
+
+	if _, _, err := readSigned(compressed, Options{}); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected ErrSignature for tampered content, got %v", err)
+	}
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.SignatureError == "" {
+		t.Errorf("Expected a signature error in the report, got %+v", report)
+	}
+
+	// Quitar el registro de firma
+	original, _ := signedContainer(t, data, Options{BlockSize: 4096})
+	stripped := append(append([]byte(nil), original[:len(original)-1-signatureRecordLen]...), methodEnd)
+	if _, _, err := readSigned(stripped, Options{}); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected ErrSignature for a stripped signature, got %v", err)
+	}
+
+	// Un bloque agregado después del registro de firma no está firmado
+	first := original[containerHeaderLen:]
+	first = first[:frameHeaderLen+int(binary.BigEndian.Uint32(first[5:]))]
+	appended := append(append(append([]byte(nil), original[:len(original)-1]...), first...), methodEnd)
+	if _, _, err := readSigned(appended, Options{}); !errors.Is(err, ErrSignature) || !strings.Contains(err.Error(), "after the signature") {
+		t.Errorf("Expected ErrSignature for a block after the signature, got %v", err)
+	}
+	report, err = Verify(bytes.NewReader(appended))
+	if err != nil || report.OK() || report.SignatureError == "" {
+		t.Errorf("Expected a signature error in the report, got %+v: %v", report, err)
+	}
+
+	// Firmado con otra clave
+	if _, _, err := readSigned(original, Options{VerifyKey: pub}); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected ErrSignature for a different signer, got %v", err)
+	}
+
+	// Sin firma cuando se exige una
+	unsigned := roundTrip(t, data, Options{})
+	if _, _, err := readSigned(unsigned, Options{VerifyKey: pub}); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("Expected ErrNotSigned, got %v", err)
+	}
+}
+
+func TestSignatureSurvivesRepair(t *testing.T) {
+	fastKDF(t)
+	data := parityTestData()
+	original, pub := signedContainer(t, data, Options{BlockSize: 1024, Parity: 1, Password: "pw"})
+
+	damaged := append([]byte(nil), original...)
+	damaged[len(damaged)/2] ^= 0x04
+	var repaired bytes.Buffer
+	if _, err := Repair(bytes.NewReader(damaged), &repaired); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if _, _, err := readSigned(repaired.Bytes(), Options{Password: "pw", VerifyKey: pub}); err != nil {
+		t.Errorf("Signature of repaired container is not valid: %v", err)
+	}
+}
+
+func TestKeyMarshalling(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	privPEM, err := MarshalPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey failed: %v", err)
+	}
+	pubPEM, err := MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPublicKey failed: %v", err)
+	}
+
+	parsedPriv, err := ParsePrivateKey(privPEM)
+	if err != nil || !parsedPriv.Equal(priv) {
+		t.Errorf("Private key round trip failed: %v", err)
+	}
+	parsedPub, err := ParsePublicKey(pubPEM)
+	if err != nil || !parsedPub.Equal(pub) {
+		t.Errorf("Public key round trip failed: %v", err)
+	}
+	if _, err := ParsePublicKey(privPEM); err == nil {
+		t.Error("Expected error parsing a private key as public key")
+	}
+}
@@ -0,0 +1,358 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TableFormats son los formatos de exportación e importación de tablas.
+var TableFormats = []string{"csv", "json"}
+
+// ErrFrequencyTable indica que una tabla de frecuencias importada no es válida.
+var ErrFrequencyTable = errors.New("huffman: invalid frequency table")
+
+// probabilityScale convierte probabilidades en frecuencias enteras para
+// construir el árbol: una probabilidad p pesa round(p*probabilityScale).
+const probabilityScale = 1_000_000
+
+// maxTableFrequency limita cada frecuencia importada para que la suma de 256
+// símbolos no desborde un int.
+const maxTableFrequency = 1 << 52
+
+// FrequencyTable es la frecuencia de cada símbolo, contada en unos datos o
+// importada. Los símbolos que no aparecen no reciben código.
+type FrequencyTable struct {
+	// Symbols es la suma de las frecuencias.
+	Symbols int `json:"symbols"`
+	// Frequencies está en orden de símbolo y solo tiene frecuencias positivas.
+	Frequencies []SymbolFrequency `json:"frequencies"`
+}
+
+// SymbolFrequency es una fila de la tabla de frecuencias.
+type SymbolFrequency struct {
+	Symbol      byte    `json:"symbol"`
+	Frequency   int     `json:"frequency"`
+	Probability float64 `json:"probability"`
+}
+
+// CodeTable es la tabla de códigos que resulta de una tabla de frecuencias.
+type CodeTable struct {
+	Symbols     int  `json:"symbols"`
+	MinVariance bool `json:"minVariance"`
+	// Codes está ordenada por longitud de código y símbolo.
+	Codes []CodeInfo `json:"codes"`
+	// BitsPerSymbol es la longitud media de código y CodeLengthVariance su
+	// varianza, ponderadas por frecuencia.
+	BitsPerSymbol      float64 `json:"bitsPerSymbol"`
+	CodeLengthVariance float64 `json:"codeLengthVariance"`
+	Entropy            float64 `json:"entropy"`
+}
+
+// Frequencies cuenta la frecuencia de cada byte de data.
+func Frequencies(data []byte) *FrequencyTable {
+	return newFrequencyTable(countFrequencies(data))
+}
+
+func newFrequencyTable(frequencies map[byte]int) *FrequencyTable {
+	t := &FrequencyTable{Frequencies: []SymbolFrequency{}}
+	for _, freq := range frequencies {
+		t.Symbols += freq
+	}
+	for symbol := 0; symbol < 256; symbol++ {
+		if freq := frequencies[byte(symbol)]; freq > 0 {
+			p := float64(freq) / float64(t.Symbols)
+			t.Frequencies = append(t.Frequencies, SymbolFrequency{Symbol: byte(symbol), Frequency: freq, Probability: p})
+		}
+	}
+	return t
+}
+
+func (t *FrequencyTable) frequencies() map[byte]int {
+	frequencies := make(map[byte]int, len(t.Frequencies))
+	for _, f := range t.Frequencies {
+		frequencies[f.Symbol] = f.Frequency
+	}
+	return frequencies
+}
+
+// Codes construye el árbol de Huffman de la tabla como al comprimir un bloque
+// con opts.MinVariance y devuelve sus códigos.
+func (t *FrequencyTable) Codes(opts Options) *CodeTable {
+	frequencies := t.frequencies()
+	root := buildTree(frequencies, opts.MinVariance, nil)
+	c := &CodeTable{Symbols: t.Symbols, MinVariance: opts.MinVariance, Entropy: Entropy(frequencies)}
+	c.Codes, c.BitsPerSymbol, c.CodeLengthVariance = codeInfo(generateCodes(root), frequencies)
+	return c
+}
+
+// Write escribe la tabla en csv o json.
+func (t *FrequencyTable) Write(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		return t.WriteCSV(w)
+	case "json":
+		return writeTableJSON(w, t)
+	}
+	return fmt.Errorf("huffman: unknown table format %q", format)
+}
+
+// WriteCSV escribe una fila symbol,frequency,probability por símbolo. El
+// símbolo es el valor del byte, de 0 a 255.
+func (t *FrequencyTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"symbol", "frequency", "probability"})
+	for _, f := range t.Frequencies {
+		cw.Write([]string{strconv.Itoa(int(f.Symbol)), strconv.Itoa(f.Frequency), strconv.FormatFloat(f.Probability, 'g', -1, 64)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write escribe la tabla de códigos en csv o json.
+func (c *CodeTable) Write(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		return c.WriteCSV(w)
+	case "json":
+		return writeTableJSON(w, c)
+	}
+	return fmt.Errorf("huffman: unknown table format %q", format)
+}
+
+// WriteCSV escribe una fila symbol,frequency,length,code por símbolo.
+func (c *CodeTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"symbol", "frequency", "length", "code"})
+	for _, code := range c.Codes {
+		cw.Write([]string{strconv.Itoa(int(code.Symbol)), strconv.Itoa(code.Frequency), strconv.Itoa(code.Length), code.Code})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTableJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// tableRow es una fila importada de una tabla de frecuencias o de códigos;
+// los campos que no están en la tabla quedan en nil.
+type tableRow struct {
+	Symbol      *int     `json:"symbol"`
+	Frequency   *float64 `json:"frequency"`
+	Probability *float64 `json:"probability"`
+	Code        *string  `json:"code"`
+}
+
+// ReadFrequencyTable lee una tabla en csv o json, con el formato que escriben
+// FrequencyTable.WriteCSV y Write. Con format vacío se detecta por el primer
+// carácter: '{' o '[' es json. Cada fila da el símbolo (0 a 255) y su
+// frecuencia entera o su probabilidad; si todas las filas tienen frecuencia se
+// usan las frecuencias y si no las probabilidades, que deben sumar 1. Las filas
+// con peso 0 se ignoran. Los errores de validación envuelven ErrFrequencyTable.
+func ReadFrequencyTable(r io.Reader, format string) (*FrequencyTable, error) {
+	// El json de la tabla de códigos también sirve: sus filas tienen frecuencia
+	rows, err := readTableRows(r, format, ErrFrequencyTable, "frequencies", "codes")
+	if err != nil {
+		return nil, err
+	}
+	return tableFromRows(rows)
+}
+
+// readTableRows lee las filas de una tabla en csv o json, detectando el
+// formato si format está vacío. Se ignoran la marca BOM de UTF-8 y los
+// espacios alrededor de la tabla y de cada campo. En csv la primera línea
+// nombra las columnas; en json se acepta solo la lista de filas o un objeto
+// con la primera de lists que no esté vacía. Los errores envuelven kind.
+func readTableRows(r io.Reader, format string, kind error, lists ...string) ([]tableRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(data), []byte("\ufeff")))
+	if format == "" {
+		format = "csv"
+		if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+			format = "json"
+		}
+	}
+	var rows []tableRow
+	switch format {
+	case "csv":
+		rows, err = readCSVRows(data, kind)
+	case "json":
+		rows, err = readJSONRows(data, kind, lists)
+	default:
+		return nil, fmt.Errorf("huffman: unknown table format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if row.Symbol != nil && (*row.Symbol < 0 || *row.Symbol > 255) {
+			return nil, fmt.Errorf("%w: row %d: symbol %d out of range [0, 255]", kind, i+1, *row.Symbol)
+		}
+	}
+	return rows, nil
+}
+
+func readCSVRows(data []byte, kind error) ([]tableRow, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", kind, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: empty table", kind)
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return nil, fmt.Errorf("%w: missing symbol column", kind)
+	}
+
+	rows := make([]tableRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		line := i + 2
+		// text devuelve la columna name de la fila, o nil si falta o está vacía
+		text := func(name string) *string {
+			col, ok := columns[name]
+			if !ok || col >= len(record) || strings.TrimSpace(record[col]) == "" {
+				return nil
+			}
+			value := strings.TrimSpace(record[col])
+			return &value
+		}
+		number := func(name string) (*float64, error) {
+			value := text(name)
+			if value == nil {
+				return nil, nil
+			}
+			f, err := strconv.ParseFloat(*value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %q is not a number", kind, line, *value)
+			}
+			return &f, nil
+		}
+		row := tableRow{Code: text("code")}
+		symbol, err := number("symbol")
+		if err != nil {
+			return nil, err
+		}
+		if symbol != nil {
+			if *symbol != math.Trunc(*symbol) || *symbol < 0 || *symbol > 255 {
+				return nil, fmt.Errorf("%w: line %d: symbol %g is not an integer in [0, 255]", kind, line, *symbol)
+			}
+			n := int(*symbol)
+			row.Symbol = &n
+		}
+		if row.Frequency, err = number("frequency"); err != nil {
+			return nil, err
+		}
+		if row.Probability, err = number("probability"); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONRows(data []byte, kind error, lists []string) ([]tableRow, error) {
+	var rows []tableRow
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("%w: %v", kind, err)
+		}
+		return rows, nil
+	}
+	var table map[string]json.RawMessage
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("%w: %v", kind, err)
+	}
+	for _, name := range lists {
+		list, ok := table[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(list, &rows); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", kind, name, err)
+		}
+		if len(rows) > 0 {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// tableFromRows valida las filas y las convierte en frecuencias enteras.
+func tableFromRows(rows []tableRow) (*FrequencyTable, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: empty table", ErrFrequencyTable)
+	}
+	useFrequency := true
+	for _, row := range rows {
+		if row.Frequency == nil {
+			useFrequency = false
+		}
+	}
+
+	frequencies := make(map[byte]int)
+	seen := make(map[int]bool)
+	sum := 0.0
+	for i, row := range rows {
+		if row.Symbol == nil {
+			return nil, fmt.Errorf("%w: row %d has no symbol", ErrFrequencyTable, i+1)
+		}
+		symbol := *row.Symbol
+		if seen[symbol] {
+			return nil, fmt.Errorf("%w: symbol %d appears twice", ErrFrequencyTable, symbol)
+		}
+		seen[symbol] = true
+
+		weight := row.Probability
+		if useFrequency {
+			weight = row.Frequency
+		}
+		if weight == nil {
+			return nil, fmt.Errorf("%w: symbol %d has no frequency or probability", ErrFrequencyTable, symbol)
+		}
+		w := *weight
+		switch {
+		case math.IsNaN(w) || w < 0:
+			return nil, fmt.Errorf("%w: symbol %d has a negative weight", ErrFrequencyTable, symbol)
+		case useFrequency && (w != math.Trunc(w) || w > maxTableFrequency):
+			return nil, fmt.Errorf("%w: frequency of symbol %d must be an integer up to %d", ErrFrequencyTable, symbol, maxTableFrequency)
+		case !useFrequency && w > 1:
+			return nil, fmt.Errorf("%w: probability of symbol %d is greater than 1", ErrFrequencyTable, symbol)
+		}
+		sum += w
+		if w == 0 {
+			continue
+		}
+		if useFrequency {
+			frequencies[byte(symbol)] = int(w)
+		} else {
+			// Las probabilidades muy pequeñas pesan al menos 1 para no perder el símbolo
+			frequencies[byte(symbol)] = max(1, int(math.Round(w*probabilityScale)))
+		}
+	}
+	if len(frequencies) == 0 {
+		return nil, fmt.Errorf("%w: every weight is 0", ErrFrequencyTable)
+	}
+	if !useFrequency && math.Abs(sum-1) > 1e-3 {
+		return nil, fmt.Errorf("%w: probabilities add up to %g instead of 1", ErrFrequencyTable, sum)
+	}
+	return newFrequencyTable(frequencies), nil
+}
@@ -0,0 +1,148 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFrequencyTableRoundTrip(t *testing.T) {
+	data := []byte("abracadabra")
+	table := Frequencies(data)
+	if table.Symbols != len(data) || len(table.Frequencies) != 5 || table.Frequencies[0].Symbol != 'a' || table.Frequencies[0].Frequency != 5 {
+		t.Fatalf("Unexpected table %+v", table)
+	}
+
+	for _, format := range TableFormats {
+		var out bytes.Buffer
+		if err := table.Write(&out, format); err != nil {
+			t.Fatal(err)
+		}
+		// Sin formato se detecta por el contenido
+		read, err := ReadFrequencyTable(bytes.NewReader(out.Bytes()), "")
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, out.String())
+		}
+		if read.Symbols != table.Symbols || len(read.Frequencies) != len(table.Frequencies) {
+			t.Fatalf("%s: read %+v, wrote %+v", format, read, table)
+		}
+		for i := range read.Frequencies {
+			if read.Frequencies[i] != table.Frequencies[i] {
+				t.Errorf("%s: row %d is %+v, expected %+v", format, i, read.Frequencies[i], table.Frequencies[i])
+			}
+		}
+	}
+
+	// Los códigos son los mismos que al comprimir los datos
+	codes := generateCodes(buildHuffmanTree(countFrequencies(data)))
+	codeTable := table.Codes(Options{})
+	for _, c := range codeTable.Codes {
+		if codes[c.Symbol] != c.Code {
+			t.Errorf("Symbol %q: table code %s, compression code %s", c.Symbol, c.Code, codes[c.Symbol])
+		}
+	}
+	trace := TraceTree(data, Options{})
+	if codeTable.BitsPerSymbol != trace.BitsPerSymbol || codeTable.Entropy != trace.Entropy {
+		t.Errorf("Table gives %.3f bits and entropy %.3f, the trace %.3f and %.3f",
+			codeTable.BitsPerSymbol, codeTable.Entropy, trace.BitsPerSymbol, trace.Entropy)
+	}
+
+	var out bytes.Buffer
+	codeTable.Write(&out, "csv")
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 6 || lines[0] != "symbol,frequency,length,code" || lines[1] != "97,5,1,0" {
+		t.Errorf("Unexpected code table csv:\n%s", out.String())
+	}
+	if err := codeTable.Write(&out, "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestReadProbabilities(t *testing.T) {
+	// Una distribución diádica da códigos de longitud exacta -log2(p)
+	csv := "symbol,probability\n65,0.5\n66,0.25\n67,0.125\n68,0.125\n69,0\n"
+	table, err := ReadFrequencyTable(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Frequencies) != 4 || table.Frequencies[0].Frequency != probabilityScale/2 {
+		t.Fatalf("Unexpected table %+v", table)
+	}
+	codes := table.Codes(Options{})
+	lengths := map[byte]int{'A': 1, 'B': 2, 'C': 3, 'D': 3}
+	for _, c := range codes.Codes {
+		if lengths[c.Symbol] != c.Length {
+			t.Errorf("Symbol %q has length %d, expected %d", c.Symbol, c.Length, lengths[c.Symbol])
+		}
+	}
+	if codes.BitsPerSymbol != 1.75 || codes.Entropy != 1.75 {
+		t.Errorf("Expected 1.75 bits and entropy, got %.3f and %.3f", codes.BitsPerSymbol, codes.Entropy)
+	}
+
+	// En json basta la lista de filas
+	table, err = ReadFrequencyTable(strings.NewReader(`[{"symbol": 48, "probability": 0.9}, {"symbol": 49, "probability": 0.1}]`), "")
+	if err != nil || len(table.Frequencies) != 2 || table.Frequencies[1].Frequency != probabilityScale/10 {
+		t.Errorf("Unexpected table %+v: %v", table, err)
+	}
+}
+
+func TestReadFrequencyTableErrors(t *testing.T) {
+	tests := []struct {
+		name, format, input string
+	}{
+		{"empty", "csv", ""},
+		{"no symbol column", "csv", "char,frequency\n97,1\n"},
+		{"no weight column", "csv", "symbol,code\n97,0\n"},
+		{"symbol out of range", "csv", "symbol,frequency\n256,1\n"},
+		{"symbol not a number", "csv", "symbol,frequency\na,1\n"},
+		{"duplicate symbol", "csv", "symbol,frequency\n97,1\n97,2\n"},
+		{"negative frequency", "csv", "symbol,frequency\n97,-1\n"},
+		{"fractional frequency", "csv", "symbol,frequency\n97,1.5\n"},
+		{"all zero", "csv", "symbol,frequency\n97,0\n98,0\n"},
+		{"probabilities do not add up", "csv", "symbol,probability\n97,0.5\n98,0.2\n"},
+		{"probability above 1", "csv", "symbol,probability\n97,1.5\n98,-0.5\n"},
+		{"missing symbol", "json", `{"frequencies": [{"frequency": 3}]}`},
+		{"missing weight", "json", `[{"symbol": 97}]`},
+		{"invalid json", "json", `{"frequencies": `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFrequencyTable(strings.NewReader(tt.input), tt.format)
+			if !errors.Is(err, ErrFrequencyTable) {
+				t.Errorf("Expected ErrFrequencyTable, got %v", err)
+			}
+		})
+	}
+	if _, err := ReadFrequencyTable(strings.NewReader("symbol,frequency\n"), "xml"); err == nil || errors.Is(err, ErrFrequencyTable) {
+		t.Errorf("Expected an unknown format error, got %v", err)
+	}
+}
+
+// Las tablas de frecuencias y de códigos se leen igual: con BOM, espacios
+// alrededor y en cualquiera de los dos formatos.
+func TestReadTableRowsShared(t *testing.T) {
+	codes := Frequencies([]byte("aab")).Codes(Options{})
+	for _, format := range TableFormats {
+		var out bytes.Buffer
+		codes.Write(&out, format)
+		input := "\ufeff \n" + out.String() + "\n\n"
+
+		frequencies, err := ReadFrequencyTable(strings.NewReader(input), "")
+		if err != nil || frequencies.Symbols != 3 {
+			t.Errorf("%s: unexpected frequencies %+v: %v", format, frequencies, err)
+		}
+		read, err := ReadCodes(strings.NewReader(input), "")
+		if err != nil || len(read) != 2 || read[0].Code != codes.Codes[0].Code {
+			t.Errorf("%s: unexpected codes %+v: %v", format, read, err)
+		}
+	}
+
+	for _, bad := range []string{"symbol,frequency,code\n256,1,0\n", `[{"symbol": 256, "frequency": 1, "code": "0"}]`} {
+		if _, err := ReadFrequencyTable(strings.NewReader(bad), ""); !errors.Is(err, ErrFrequencyTable) {
+			t.Errorf("%q: expected ErrFrequencyTable, got %v", bad, err)
+		}
+		if _, err := ReadCodes(strings.NewReader(bad), ""); !errors.Is(err, ErrCodeTable) {
+			t.Errorf("%q: expected ErrCodeTable, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrBits es el error con el que coinciden (errors.Is) los errores de una
+// cadena de bits que no se puede decodificar con la tabla dada.
+var ErrBits = errors.New("huffman: invalid bit string")
+
+// TextEncoding es la codificación de un mensaje corto, para mostrar sus bits.
+type TextEncoding struct {
+	Table *CodeTable `json:"table"`
+	// Bits es el mensaje codificado como una cadena de '0' y '1', y Grouped
+	// los mismos bits con un espacio entre los códigos de cada símbolo.
+	Bits    string `json:"bits"`
+	Grouped string `json:"grouped"`
+	// TotalBits es la longitud de Bits y ASCIIBits la del mensaje con 8 bits
+	// por símbolo. No incluyen la tabla, que también habría que enviar.
+	TotalBits int     `json:"totalBits"`
+	ASCIIBits int     `json:"asciiBits"`
+	Ratio     float64 `json:"ratio"` // TotalBits sobre ASCIIBits
+}
+
+// EncodeText codifica text con su propio árbol de Huffman, construido con
+// opts.MinVariance.
+func EncodeText(text []byte, opts Options) *TextEncoding {
+	table := Frequencies(text).Codes(opts)
+	codes := make(map[byte]string, len(table.Codes))
+	for _, c := range table.Codes {
+		codes[c.Symbol] = c.Code
+	}
+
+	var bits, grouped strings.Builder
+	for i, b := range text {
+		if i > 0 {
+			grouped.WriteByte(' ')
+		}
+		bits.WriteString(codes[b])
+		grouped.WriteString(codes[b])
+	}
+	e := &TextEncoding{Table: table, Bits: bits.String(), Grouped: grouped.String(), TotalBits: bits.Len(), ASCIIBits: 8 * len(text)}
+	if e.ASCIIBits > 0 {
+		e.Ratio = float64(e.TotalBits) / float64(e.ASCIIBits)
+	}
+	return e
+}
+
+// DecodeBits decodifica una cadena de '0' y '1' con codes. Los espacios se
+// ignoran, así que sirve la salida de TextEncoding.Grouped. La tabla debe ser
+// libre de prefijos pero puede estar incompleta, como una escrita a mano; en
+// ese caso los bits que no empiezan ningún código son un error. Los errores de
+// la tabla envuelven ErrCodeTable y los de los bits ErrBits, con la posición
+// del primer bit del código que falla, contando desde 1 y solo '0' y '1'.
+func DecodeBits(bits string, codes []CodeInfo) ([]byte, error) {
+	table := make(map[byte]string, len(codes))
+	for _, c := range codes {
+		if _, dup := table[c.Symbol]; dup {
+			return nil, fmt.Errorf("%w: symbol %d appears twice", ErrCodeTable, c.Symbol)
+		}
+		table[c.Symbol] = c.Code
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%w: no codes", ErrCodeTable)
+	}
+	root, _, err := prefixTree(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded []byte
+	node := root
+	var prefix []byte // bits del código en curso
+	position, start := 0, 0
+	for i := 0; i < len(bits); i++ {
+		c := bits[i]
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '0':
+			node = node.Left
+		case '1':
+			node = node.Right
+		default:
+			return nil, fmt.Errorf("%w: %q at character %d, only 0 and 1 are allowed", ErrBits, c, i+1)
+		}
+		prefix = append(prefix, c)
+		position++
+		if node == nil {
+			return nil, fmt.Errorf("%w: no code starts with %s (bit %d)", ErrBits, prefix, start+1)
+		}
+		if node.Left == nil && node.Right == nil {
+			decoded = append(decoded, node.Char)
+			node, prefix, start = root, prefix[:0], position
+		}
+	}
+	if node != root {
+		return nil, fmt.Errorf("%w: the last bits %s are not a complete code (bit %d)", ErrBits, prefix, start+1)
+	}
+	return decoded, nil
+}
+
+// ReadCodes lee una tabla de códigos en csv o json, con el formato que
+// escribe CodeTable. Con format vacío se detecta por el primer carácter: '{'
+// o '[' es json. Cada fila necesita el símbolo (0 a 255) y el código; las demás
+// columnas se ignoran. Los errores envuelven ErrCodeTable.
+func ReadCodes(r io.Reader, format string) ([]CodeInfo, error) {
+	rows, err := readTableRows(r, format, ErrCodeTable, "codes")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no codes", ErrCodeTable)
+	}
+	codes := make([]CodeInfo, 0, len(rows))
+	for i, row := range rows {
+		if row.Symbol == nil || row.Code == nil {
+			return nil, fmt.Errorf("%w: row %d has no symbol or code", ErrCodeTable, i+1)
+		}
+		codes = append(codes, CodeInfo{Symbol: byte(*row.Symbol), Code: *row.Code, Length: len(*row.Code)})
+	}
+	return codes, nil
+}
@@ -0,0 +1,94 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeText(t *testing.T) {
+	text := []byte("abracadabra")
+	e := EncodeText(text, Options{})
+	// a:5 b:2 r:2 c:1 d:1 dan 23 bits con el árbol de Huffman
+	if e.TotalBits != 23 || e.ASCIIBits != 88 || len(e.Bits) != e.TotalBits {
+		t.Fatalf("Unexpected encoding %+v", e)
+	}
+	if groups := strings.Fields(e.Grouped); len(groups) != len(text) || strings.Join(groups, "") != e.Bits {
+		t.Errorf("Grouped bits %q do not match %q", e.Grouped, e.Bits)
+	}
+	if e.Ratio != 23.0/88 || e.Table.Symbols != len(text) {
+		t.Errorf("Unexpected ratio %.3f or table %+v", e.Ratio, e.Table)
+	}
+
+	// Los bits agrupados y los seguidos dan el mismo texto
+	for _, bits := range []string{e.Bits, e.Grouped} {
+		decoded, err := DecodeBits(bits, e.Table.Codes)
+		if err != nil || !bytes.Equal(decoded, text) {
+			t.Errorf("Decoded %q: %v", decoded, err)
+		}
+	}
+
+	if empty := EncodeText(nil, Options{}); empty.TotalBits != 0 || empty.Ratio != 0 || len(empty.Table.Codes) != 0 {
+		t.Errorf("Unexpected encoding of an empty text %+v", empty)
+	}
+}
+
+func TestDecodeBitsErrors(t *testing.T) {
+	// Tabla escrita a mano, libre de prefijos pero sin código que empiece por 11
+	codes := []CodeInfo{{Symbol: 'a', Code: "0"}, {Symbol: 'b', Code: "10"}}
+	if decoded, err := DecodeBits("0 10 0", codes); err != nil || string(decoded) != "aba" {
+		t.Errorf("Decoded %q: %v", decoded, err)
+	}
+
+	bitErrors := []struct {
+		bits, message string
+	}{
+		{"0110", "no code starts with 11 (bit 2)"},
+		{"001", "the last bits 1 are not a complete code (bit 3)"},
+		{"0 2", `'2' at character 3`},
+	}
+	for _, tt := range bitErrors {
+		_, err := DecodeBits(tt.bits, codes)
+		if !errors.Is(err, ErrBits) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%q: expected %q, got %v", tt.bits, tt.message, err)
+		}
+	}
+
+	tableErrors := [][]CodeInfo{
+		nil,
+		{{Symbol: 'a', Code: "0"}, {Symbol: 'b', Code: "01"}},
+		{{Symbol: 'a', Code: "0"}, {Symbol: 'a', Code: "1"}},
+		{{Symbol: 'a', Code: ""}},
+		{{Symbol: 'a', Code: "0x"}},
+	}
+	for _, table := range tableErrors {
+		if _, err := DecodeBits("0", table); !errors.Is(err, ErrCodeTable) {
+			t.Errorf("%+v: expected ErrCodeTable, got %v", table, err)
+		}
+	}
+}
+
+func TestReadCodes(t *testing.T) {
+	e := EncodeText([]byte("mississippi"), Options{})
+	for _, format := range TableFormats {
+		var out bytes.Buffer
+		e.Table.Write(&out, format)
+		codes, err := ReadCodes(&out, "")
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if decoded, err := DecodeBits(e.Bits, codes); err != nil || string(decoded) != "mississippi" {
+			t.Errorf("%s: decoded %q: %v", format, decoded, err)
+		}
+	}
+
+	if codes, err := ReadCodes(strings.NewReader(`[{"symbol": 120, "code": "1"}]`), ""); err != nil || len(codes) != 1 || codes[0].Code != "1" {
+		t.Errorf("Unexpected codes %+v: %v", codes, err)
+	}
+	for _, bad := range []string{"", "symbol,length\n97,1\n", "symbol,code\n300,0\n", `{"codes": [{"symbol": 97}]}`, `{"codes": `} {
+		if _, err := ReadCodes(strings.NewReader(bad), ""); !errors.Is(err, ErrCodeTable) {
+			t.Errorf("%q: expected ErrCodeTable, got %v", bad, err)
+		}
+	}
+}
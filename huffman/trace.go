@@ -0,0 +1,82 @@
+package huffman
+
+import (
+	"slices"
+	"sort"
+)
+
+// TreeTrace registra la construcción del árbol de Huffman paso a paso, para
+// mostrar cómo se forma: cada paso saca de la cola los dos nodos de menor
+// frecuencia y devuelve a la cola el nodo que los une.
+type TreeTrace struct {
+	// Symbols es el número de símbolos de la entrada.
+	Symbols int `json:"symbols"`
+	// Leaves es la cola inicial, una hoja por símbolo distinto.
+	Leaves []TraceNode `json:"leaves"`
+	Steps  []TreeStep  `json:"steps"`
+	// Root es el id de la raíz, o -1 si la entrada está vacía.
+	Root int `json:"root"`
+	// MinVariance indica si se desempató con el subárbol menos profundo primero.
+	MinVariance bool `json:"minVariance"`
+	// Codes es la tabla que resulta del árbol, con las frecuencias de la entrada.
+	Codes []CodeInfo `json:"codes"`
+	// BitsPerSymbol es la longitud media de código y CodeLengthVariance su
+	// varianza, ponderadas por frecuencia.
+	BitsPerSymbol      float64 `json:"bitsPerSymbol"`
+	CodeLengthVariance float64 `json:"codeLengthVariance"`
+	Entropy            float64 `json:"entropy"`
+}
+
+// TreeStep es una unión: Left y Right salen de la cola y Merged entra.
+type TreeStep struct {
+	Step   int       `json:"step"`
+	Left   TraceNode `json:"left"`
+	Right  TraceNode `json:"right"`
+	Merged TraceNode `json:"merged"`
+	// Queue es la cola después del paso, ordenada como se van a sacar los nodos.
+	Queue []TraceNode `json:"queue"`
+}
+
+// TraceNode es un nodo del árbol. Las hojas tienen los ids 0 a n-1 en orden
+// de símbolo y los nodos internos los siguientes en orden de creación.
+type TraceNode struct {
+	ID        int  `json:"id"`
+	Frequency int  `json:"frequency"`
+	Leaf      bool `json:"leaf"`
+	Symbol    byte `json:"symbol"` // solo en las hojas
+	// Left y Right son los ids de los hijos de un nodo interno, -1 en las hojas.
+	Left  int `json:"left"`
+	Right int `json:"right"`
+}
+
+// TraceTree construye el árbol de Huffman de data como al comprimir un bloque
+// con opts.MinVariance y devuelve cada paso de la construcción.
+func TraceTree(data []byte, opts Options) *TreeTrace {
+	frequencies := countFrequencies(data)
+	trace := &TreeTrace{Symbols: len(data), Leaves: []TraceNode{}, Steps: []TreeStep{}, Root: -1, MinVariance: opts.MinVariance}
+	root := buildTree(frequencies, opts.MinVariance, trace)
+	if root != nil {
+		trace.Root = root.id
+	}
+	trace.Codes, trace.BitsPerSymbol, trace.CodeLengthVariance = codeInfo(generateCodes(root), frequencies)
+	trace.Entropy = Entropy(frequencies)
+	return trace
+}
+
+func traceNode(n *huffmanNode) TraceNode {
+	if n.Left == nil && n.Right == nil {
+		return TraceNode{ID: n.id, Frequency: n.Frequency, Leaf: true, Symbol: n.Char, Left: -1, Right: -1}
+	}
+	return TraceNode{ID: n.id, Frequency: n.Frequency, Left: n.Left.id, Right: n.Right.id}
+}
+
+// queueSnapshot copia la cola en el orden en que se van a sacar los nodos.
+func queueSnapshot(pq *priorityQueue) []TraceNode {
+	sorted := &priorityQueue{nodes: slices.Clone(pq.nodes), minVariance: pq.minVariance}
+	sort.Sort(sorted)
+	nodes := make([]TraceNode, len(sorted.nodes))
+	for i, n := range sorted.nodes {
+		nodes[i] = traceNode(n)
+	}
+	return nodes
+}
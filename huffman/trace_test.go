@@ -0,0 +1,114 @@
+package huffman
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestTraceTree(t *testing.T) {
+	data := []byte("abracadabra")
+	trace := TraceTree(data, Options{})
+
+	// a:5 b:2 r:2 c:1 d:1 dan 5 hojas y 4 uniones
+	if trace.Symbols != len(data) || len(trace.Leaves) != 5 || len(trace.Steps) != 4 {
+		t.Fatalf("Unexpected trace: %d leaves, %d steps", len(trace.Leaves), len(trace.Steps))
+	}
+	if trace.Leaves[0].Frequency != 1 || trace.Leaves[4].Symbol != 'a' || !trace.Leaves[4].Leaf {
+		t.Errorf("Initial queue is not sorted by frequency: %+v", trace.Leaves)
+	}
+
+	nodes := make(map[int]TraceNode)
+	for _, leaf := range trace.Leaves {
+		nodes[leaf.ID] = leaf
+	}
+	for i, step := range trace.Steps {
+		if step.Step != i+1 || len(step.Queue) != len(trace.Leaves)-i-1 {
+			t.Errorf("Step %d has a queue of %d nodes", step.Step, len(step.Queue))
+		}
+		// Los nodos que salen son los de menor frecuencia de la cola anterior
+		if step.Left.Frequency > step.Right.Frequency || step.Merged.Frequency != step.Left.Frequency+step.Right.Frequency {
+			t.Errorf("Step %d merges %+v and %+v into %+v", step.Step, step.Left, step.Right, step.Merged)
+		}
+		if step.Merged.Left != step.Left.ID || step.Merged.Right != step.Right.ID || step.Merged.ID != len(trace.Leaves)+i {
+			t.Errorf("Step %d: wrong ids in %+v", step.Step, step.Merged)
+		}
+		for _, n := range step.Queue {
+			if n.Frequency < step.Right.Frequency {
+				t.Errorf("Step %d left %+v in the queue, smaller than %+v", step.Step, n, step.Right)
+			}
+		}
+		nodes[step.Merged.ID] = step.Merged
+	}
+	if root := nodes[trace.Root]; root.Frequency != len(data) || trace.Steps[3].Queue[0].ID != trace.Root {
+		t.Errorf("Root %+v does not hold the whole input", root)
+	}
+
+	// La tabla es la misma que se usa al comprimir
+	codes := generateCodes(buildHuffmanTree(countFrequencies(data)))
+	if len(trace.Codes) != len(codes) {
+		t.Fatalf("Expected %d codes, got %d", len(codes), len(trace.Codes))
+	}
+	for _, c := range trace.Codes {
+		if codes[c.Symbol] != c.Code {
+			t.Errorf("Symbol %q: trace code %s, compression code %s", c.Symbol, c.Code, codes[c.Symbol])
+		}
+	}
+	if trace.BitsPerSymbol < trace.Entropy || trace.BitsPerSymbol >= trace.Entropy+1 {
+		t.Errorf("Average length %.3f is not within a bit of the entropy %.3f", trace.BitsPerSymbol, trace.Entropy)
+	}
+
+	// Casos límite: entrada vacía y un solo símbolo
+	if empty := TraceTree(nil, Options{}); empty.Root != -1 || len(empty.Steps) != 0 || len(empty.Codes) != 0 {
+		t.Errorf("Unexpected trace for empty input: %+v", empty)
+	}
+	if single := TraceTree([]byte("aaaa"), Options{}); single.Root != 0 || len(single.Steps) != 0 || single.Codes[0].Code != "0" {
+		t.Errorf("Unexpected trace for a single symbol: %+v", single)
+	}
+}
+
+func TestMinVariance(t *testing.T) {
+	// El ejemplo clásico: probabilidades 0.4, 0.2, 0.2, 0.1 y 0.1
+	data := []byte("aaaabbccde")
+	normal := TraceTree(data, Options{})
+	minVar := TraceTree(data, Options{MinVariance: true})
+
+	lengths := func(trace *TreeTrace) map[byte]int {
+		m := make(map[byte]int)
+		for _, c := range trace.Codes {
+			m[c.Symbol] = c.Length
+		}
+		return m
+	}
+	if got := lengths(normal); got['a'] != 1 || got['e'] != 4 {
+		t.Errorf("Expected lengths 1 to 4 by default, got %v", got)
+	}
+	if got := lengths(minVar); got['a'] != 2 || got['c'] != 2 || got['e'] != 3 {
+		t.Errorf("Expected lengths 2 and 3 with minimum variance, got %v", got)
+	}
+	// La misma longitud media, 2.2 bits, con varianza 1.36 contra 0.16
+	if math.Abs(normal.BitsPerSymbol-2.2) > 1e-9 || math.Abs(minVar.BitsPerSymbol-2.2) > 1e-9 {
+		t.Errorf("Average lengths %.3f and %.3f, expected 2.2", normal.BitsPerSymbol, minVar.BitsPerSymbol)
+	}
+	if math.Abs(normal.CodeLengthVariance-1.36) > 1e-9 || math.Abs(minVar.CodeLengthVariance-0.16) > 1e-9 {
+		t.Errorf("Variances %.3f and %.3f, expected 1.36 and 0.16", normal.CodeLengthVariance, minVar.CodeLengthVariance)
+	}
+	if !minVar.MinVariance || normal.MinVariance {
+		t.Error("Mode not reported in the trace")
+	}
+
+	// Los archivos comprimidos así se leen sin opciones y la inspección muestra la varianza
+	text := bytes.Repeat([]byte("minimum variance huffman codes "), 2000)
+	for _, codec := range []string{"huffman", "rle-huffman", CodecAuto} {
+		compressed := roundTrip(t, text, Options{Codec: codec, MinVariance: true})
+		report, err := Inspect(bytes.NewReader(compressed), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := Inspect(bytes.NewReader(compressBytes(t, text, Options{Codec: codec})), Options{})
+		if report.CodeLengthVariance > plain.CodeLengthVariance || math.Abs(report.BitsPerSymbol-plain.BitsPerSymbol) > 1e-9 {
+			t.Errorf("%s: variance %.3f (%.3f bits) against %.3f (%.3f bits)", codec,
+				report.CodeLengthVariance, report.BitsPerSymbol, plain.CodeLengthVariance, plain.BitsPerSymbol)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package huffman
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// VerifyReport resume la verificación de un contenedor .huff.
+type VerifyReport struct {
+	Version   int  `json:"version"`
+	Parity    bool `json:"parity"`
+	Encrypted bool `json:"encrypted"`
+	Signed    bool `json:"signed"`
+	// Signer es la clave pública Ed25519 (hex) cuya firma se verificó.
+	Signer         string        `json:"signer,omitempty"`
+	SignatureError string        `json:"signatureError,omitempty"`
+	Blocks         int           `json:"blocks"`
+	OriginalSize   int64         `json:"originalSize"`
+	CorruptBlocks  []CorruptInfo `json:"corruptBlocks"`
+	// Truncated indica que no se pudo leer hasta el marcador de fin, por ejemplo
+	// porque el encabezado de un bloque estaba dañado o el archivo está cortado.
+	Truncated bool `json:"truncated"`
+}
+
+// CorruptInfo identifica un bloque dañado dentro del reporte.
+type CorruptInfo struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Error  string `json:"error"`
+	// Repaired indica que el bloque se reconstruyó con la paridad del grupo.
+	Repaired bool `json:"repaired"`
+}
+
+// OK indica si todos los bloques se pudieron leer y verificar, reparando con la paridad si hizo falta.
+func (r *VerifyReport) OK() bool {
+	for _, block := range r.CorruptBlocks {
+		if !block.Repaired {
+			return false
+		}
+	}
+	return !r.Truncated && r.SignatureError == ""
+}
+
+// Verify recorre todos los bloques de un contenedor comprobando sus CRC y
+// decodificándolos, sin escribir la salida. A diferencia de Decompress no se
+// detiene en el primer bloque dañado: los reporta todos mientras las longitudes
+// de los bloques sean confiables. Los bloques reconstruidos con la paridad se
+// reportan con Repaired en true. En un contenedor cifrado solo se comprueban
+// los CRC del texto cifrado, ya que decodificar requiere la contraseña. Solo devuelve error si r no es un contenedor
+// válido o falla la lectura.
+func Verify(r io.Reader) (*VerifyReport, error) {
+	return VerifyWithOptions(r, Options{})
+}
+
+// VerifyWithOptions es como Verify pero, con opts.VerifyKey, además exige que
+// el contenedor esté firmado con esa clave. Los problemas de firma se informan
+// en SignatureError.
+func VerifyWithOptions(r io.Reader, opts Options) (*VerifyReport, error) {
+	verifyKey := opts.VerifyKey
+	zr, err := newReader(r, Options{}, readerMode{allowLocked: true})
+	if err != nil {
+		return nil, err
+	}
+	zr.verifyKey = verifyKey
+	report := &VerifyReport{
+		Version:       int(zr.version),
+		Parity:        zr.flags&flagParity != 0,
+		Encrypted:     zr.flags&flagEncrypted != 0,
+		Signed:        zr.flags&flagSigned != 0,
+		CorruptBlocks: []CorruptInfo{},
+	}
+	if verifyKey != nil && !report.Signed {
+		report.SignatureError = ErrNotSigned.Error()
+	}
+
+	for {
+		frame, err := zr.readFrame()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, ErrSignature) {
+			report.SignatureError = err.Error()
+			break
+		}
+		if err != nil {
+			var blockErr *BlockError
+			if !errors.As(err, &blockErr) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, err
+			}
+			if blockErr != nil {
+				report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(blockErr, false))
+			}
+			report.Truncated = true
+			break
+		}
+
+		report.Blocks++
+		report.OriginalSize += int64(frame.rawLen)
+		if frame.repaired != nil {
+			report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(&BlockError{Index: frame.index, Offset: frame.offset, Err: frame.repaired}, true))
+		}
+		if report.Encrypted {
+			// Sin la contraseña solo se puede comprobar el CRC del texto cifrado.
+			if frame.err == nil && !frame.payloadOK() {
+				frame.err = fmt.Errorf("%w: compressed data", ErrChecksum)
+			}
+			if frame.err != nil {
+				report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(&BlockError{Index: frame.index, Offset: frame.offset, Err: frame.err}, false))
+			}
+			continue
+		}
+		if _, err := zr.decodeFrame(frame); err != nil {
+			report.CorruptBlocks = append(report.CorruptBlocks, corruptInfo(&BlockError{Index: frame.index, Offset: frame.offset, Err: err}, false))
+		}
+	}
+	if signer := zr.Signer(); signer != nil {
+		report.Signer = hex.EncodeToString(signer)
+	}
+	return report, nil
+}
+
+func corruptInfo(e *BlockError, repaired bool) CorruptInfo {
+	return CorruptInfo{Index: e.Index, Offset: e.Offset, Error: fmt.Sprint(e.Err), Repaired: repaired}
+}
@@ -0,0 +1,106 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// compressBlocks comprime data en bloques pequeños y devuelve el contenedor
+// junto con el desplazamiento del encabezado de cada bloque.
+func compressBlocks(t *testing.T, data []byte, blockSize int) ([]byte, []int) {
+	t.Helper()
+	compressed := roundTrip(t, data, Options{BlockSize: blockSize})
+	var offsets []int
+	for pos := containerHeaderLen; compressed[pos] != methodEnd; {
+		offsets = append(offsets, pos)
+		pos += frameHeaderLen + int(binary.BigEndian.Uint32(compressed[pos+5:]))
+	}
+	return compressed, offsets
+}
+
+func TestVerifyDetectsCorruptBlocks(t *testing.T) {
+	data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 1000)
+	compressed, offsets := compressBlocks(t, data, 4096)
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() || report.Blocks != len(offsets) || report.OriginalSize != int64(len(data)) {
+		t.Fatalf("Unexpected report for intact file: %+v", report)
+	}
+
+	// Dañar el contenido de los bloques 1 y 3
+	for _, i := range []int{1, 3} {
+		compressed[offsets[i]+frameHeaderLen+10] ^= 0x40
+	}
+	report, err = Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Truncated || len(report.CorruptBlocks) != 2 {
+		t.Fatalf("Expected two corrupt blocks, got %+v", report)
+	}
+	for n, i := range []int{1, 3} {
+		if got := report.CorruptBlocks[n]; got.Index != i || got.Offset != int64(offsets[i]) {
+			t.Errorf("Corrupt block %d reported as %+v, expected index %d at offset %d", n, got, i, offsets[i])
+		}
+	}
+	t.Logf("Report: %+v", report)
+}
+
+func TestVerifyCorruptBlockHeader(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 4096)
+	compressed, offsets := compressBlocks(t, data, 4096)
+
+	// Dañar la longitud del bloque 2: no se puede seguir leyendo después de él
+	compressed[offsets[2]+6] ^= 0x01
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.Truncated || len(report.CorruptBlocks) != 1 || report.CorruptBlocks[0].Index != 2 {
+		t.Fatalf("Expected truncated report at block 2, got %+v", report)
+	}
+}
+
+func TestDecompressSkipCorrupt(t *testing.T) {
+	blockSize := 4096
+	data := make([]byte, 5*blockSize)
+	for i := range data {
+		data[i] = byte('a' + i/blockSize)
+	}
+	compressed, offsets := compressBlocks(t, data, blockSize)
+	compressed[offsets[2]+frameHeaderLen+4] ^= 0xFF
+
+	// Sin SkipCorrupt la lectura falla en el bloque dañado
+	zr, err := NewReader(bytes.NewReader(compressed), Options{})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	_, err = io.ReadAll(zr)
+	var blockErr *BlockError
+	if !errors.As(err, &blockErr) || blockErr.Index != 2 || !errors.Is(err, ErrChecksum) {
+		t.Fatalf("Expected checksum error in block 2, got %v", err)
+	}
+
+	// Con SkipCorrupt se omite el bloque y se sigue con el resto
+	zr, err = NewReader(bytes.NewReader(compressed), Options{SkipCorrupt: true})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Decompression with SkipCorrupt failed: %v", err)
+	}
+	expected := append(append([]byte(nil), data[:2*blockSize]...), data[3*blockSize:]...)
+	if !bytes.Equal(decompressed, expected) {
+		t.Errorf("Decompressed data does not match the original without block 2")
+	}
+	if skipped := zr.Skipped(); len(skipped) != 1 || skipped[0].Index != 2 {
+		t.Errorf("Expected block 2 to be skipped, got %v", skipped)
+	}
+}
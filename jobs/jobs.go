@@ -0,0 +1,337 @@
+// Package jobs runs long operations in the background on a bounded pool of
+// workers so HTTP handlers can return immediately and let clients poll.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State is the lifecycle stage of a job.
+type State string
+
+const (
+	Queued   State = "queued"
+	Running  State = "running"
+	Done     State = "done"
+	Failed   State = "failed"
+	Canceled State = "canceled"
+)
+
+// DefaultRetention is how long finished jobs stay visible to Get.
+const DefaultRetention = time.Hour
+
+var (
+	// ErrQueueFull is returned by Submit when every queue slot is taken.
+	ErrQueueFull = errors.New("jobs: queue is full")
+	// ErrNotFound is returned for unknown or expired job IDs.
+	ErrNotFound = errors.New("jobs: job not found")
+	// ErrFinished is returned when canceling a job that already finished.
+	ErrFinished = errors.New("jobs: job already finished")
+	// ErrClosed is returned by Submit after Close.
+	ErrClosed = errors.New("jobs: queue is closed")
+)
+
+// Func does the work of a job. It must return promptly once ctx is canceled
+// and may call progress to publish how far along it is. The returned string
+// is exposed as the job result.
+type Func func(ctx context.Context, progress func(Progress)) (string, error)
+
+// Progress is reported by a running job.
+type Progress struct {
+	Fraction     float64 // between 0 and 1
+	Phase        string
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Status is a snapshot of a job, safe to serialize.
+type Status struct {
+	ID           string     `json:"id"`
+	Kind         string     `json:"kind"`
+	State        State      `json:"state"`
+	Progress     float64    `json:"progress"`
+	Phase        string     `json:"phase,omitempty"`
+	BytesRead    int64      `json:"bytesRead"`
+	BytesWritten int64      `json:"bytesWritten"`
+	Result       string     `json:"result,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Finished reports whether the job reached a final state.
+func (s Status) Finished() bool {
+	return s.State == Done || s.State == Failed || s.State == Canceled
+}
+
+type job struct {
+	status      Status
+	fn          Func
+	cleanup     func() // runs if the job finishes without calling fn
+	ctx         context.Context
+	cancel      context.CancelFunc
+	subscribers []chan Status
+}
+
+// Queue is a bounded job queue served by a fixed number of workers.
+type Queue struct {
+	// Retention is how long finished jobs are kept; set it before submitting.
+	Retention time.Duration
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending chan *job
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewQueue starts workers goroutines that run at most size queued jobs.
+func NewQueue(workers, size int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 1 {
+		size = 1
+	}
+	q := &Queue{
+		Retention: DefaultRetention,
+		jobs:      make(map[string]*job),
+		pending:   make(chan *job, size),
+	}
+	q.wg.Add(workers)
+	for range workers {
+		go q.worker()
+	}
+	return q
+}
+
+// Submit enqueues fn and returns the new job's status.
+func (q *Queue) Submit(kind string, fn Func) (Status, error) {
+	return q.SubmitWithCleanup(kind, fn, nil)
+}
+
+// SubmitWithCleanup is like Submit, but if the job is canceled before fn
+// starts, or the queue closes first, cleanup runs instead so the job can
+// release what it holds. It is not called when Submit fails or once fn ran.
+func (q *Queue) SubmitWithCleanup(kind string, fn Func, cleanup func()) (Status, error) {
+	id, err := newID()
+	if err != nil {
+		return Status{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status:  Status{ID: id, Kind: kind, State: Queued, CreatedAt: time.Now()},
+		fn:      fn,
+		cleanup: cleanup,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		cancel()
+		return Status{}, ErrClosed
+	}
+	q.prune()
+	select {
+	case q.pending <- j:
+	default:
+		cancel()
+		return Status{}, ErrQueueFull
+	}
+	q.jobs[id] = j
+	return j.status, nil
+}
+
+// Get returns the current status of a job.
+func (q *Queue) Get(id string) (Status, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return j.status, nil
+}
+
+// Cancel stops a queued or running job. A queued job is marked canceled at
+// once; a running job is canceled through its context and reaches the
+// Canceled state when its Func returns.
+func (q *Queue) Cancel(id string) (Status, error) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return Status{}, ErrNotFound
+	}
+	if j.status.Finished() {
+		q.mu.Unlock()
+		return j.status, ErrFinished
+	}
+	j.cancel()
+	queued := j.status.State == Queued
+	if queued {
+		q.finish(j, Canceled, "", context.Canceled)
+	}
+	status := j.status
+	q.mu.Unlock()
+
+	// The worker skips the job later, so its cleanup runs here
+	if queued && j.cleanup != nil {
+		j.cleanup()
+	}
+	return status, nil
+}
+
+// Close cancels every job, stops accepting new ones and waits for the workers.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for _, j := range q.jobs {
+		j.cancel()
+	}
+	close(q.pending)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for j := range q.pending {
+		q.run(j)
+	}
+}
+
+func (q *Queue) run(j *job) {
+	q.mu.Lock()
+	if j.status.State != Queued {
+		// Canceled while waiting in the queue
+		q.mu.Unlock()
+		return
+	}
+	if j.ctx.Err() != nil {
+		// Canceled by Close
+		q.finish(j, Canceled, "", j.ctx.Err())
+		q.mu.Unlock()
+		if j.cleanup != nil {
+			j.cleanup()
+		}
+		return
+	}
+	now := time.Now()
+	j.status.State = Running
+	j.status.StartedAt = &now
+	q.notify(j)
+	q.mu.Unlock()
+
+	result, err := j.fn(j.ctx, func(p Progress) {
+		q.mu.Lock()
+		j.status.Progress = min(max(p.Fraction, 0), 1)
+		j.status.Phase = p.Phase
+		j.status.BytesRead = p.BytesRead
+		j.status.BytesWritten = p.BytesWritten
+		q.notify(j)
+		q.mu.Unlock()
+	})
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case err == nil:
+		j.status.Progress = 1
+		q.finish(j, Done, result, nil)
+	case j.ctx.Err() != nil:
+		q.finish(j, Canceled, "", j.ctx.Err())
+	default:
+		q.finish(j, Failed, "", err)
+	}
+	j.cancel()
+}
+
+// finish moves j to a final state. q.mu must be held.
+func (q *Queue) finish(j *job, state State, result string, err error) {
+	now := time.Now()
+	j.status.State = state
+	j.status.Result = result
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+	j.status.FinishedAt = &now
+	q.notify(j)
+	for _, ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// Subscribe returns a channel that receives the job status every time it
+// changes, starting with the current one. Slow readers only see the latest
+// status. The channel is closed once the job finishes; call unsubscribe to
+// stop listening earlier.
+func (q *Queue) Subscribe(id string) (updates <-chan Status, unsubscribe func(), err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	ch := make(chan Status, 1)
+	ch <- j.status
+	if j.status.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	j.subscribers = append(j.subscribers, ch)
+	return ch, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for i, sub := range j.subscribers {
+			if sub == ch {
+				j.subscribers = append(j.subscribers[:i], j.subscribers[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}, nil
+}
+
+// notify sends the current status to every subscriber, replacing a status
+// they have not read yet. q.mu must be held.
+func (q *Queue) notify(j *job) {
+	for _, ch := range j.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.status
+	}
+}
+
+// prune drops finished jobs older than the retention. q.mu must be held.
+func (q *Queue) prune() {
+	cutoff := time.Now().Add(-q.Retention)
+	for id, j := range q.jobs {
+		if j.status.Finished() && j.status.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
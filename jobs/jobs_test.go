@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls the job until it reaches a final state.
+func waitFor(t *testing.T, q *Queue, id string) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := q.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if status.Finished() {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return Status{}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	q := NewQueue(2, 4)
+	defer q.Close()
+
+	status, err := q.Submit("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		progress(Progress{Fraction: 0.5, Phase: "encoding"})
+		return "out.huff", nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if status.State != Queued || status.ID == "" {
+		t.Fatalf("Unexpected initial status: %+v", status)
+	}
+
+	status = waitFor(t, q, status.ID)
+	if status.State != Done || status.Result != "out.huff" || status.Progress != 1 || status.StartedAt == nil {
+		t.Errorf("Unexpected final status: %+v", status)
+	}
+
+	failed, _ := q.Submit("decompress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		return "", errors.New("boom")
+	})
+	failed = waitFor(t, q, failed.ID)
+	if failed.State != Failed || failed.Error != "boom" {
+		t.Errorf("Unexpected failed status: %+v", failed)
+	}
+
+	if _, err := q.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := q.Cancel(status.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished, got %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	q := NewQueue(1, 2)
+	defer q.Close()
+
+	started := make(chan struct{})
+	running, _ := q.Submit("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	cleaned := make(chan struct{})
+	queued, _ := q.SubmitWithCleanup("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		t.Error("Canceled job must not run")
+		return "", nil
+	}, func() { close(cleaned) })
+	<-started
+
+	// The single worker is busy, so the second job is still waiting
+	status, err := q.Cancel(queued.ID)
+	if err != nil || status.State != Canceled {
+		t.Fatalf("Expected queued job to be canceled at once, got %+v, %v", status, err)
+	}
+	select {
+	case <-cleaned:
+	default:
+		t.Error("Expected the cleanup of the canceled job to run")
+	}
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if status := waitFor(t, q, running.ID); status.State != Canceled {
+		t.Errorf("Expected running job to be canceled, got %+v", status)
+	}
+}
+
+func TestQueueBounded(t *testing.T) {
+	q := NewQueue(1, 1)
+	defer q.Close()
+
+	block := func(ctx context.Context, progress func(Progress)) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	first, _ := q.Submit("compress", block)
+	for {
+		// Wait until the worker took the first job so the queue slot is free
+		if status, _ := q.Get(first.ID); status.State == Running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var cleanups int
+	if _, err := q.SubmitWithCleanup("compress", block, func() { cleanups++ }); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if _, err := q.SubmitWithCleanup("compress", block, func() { cleanups++ }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	// The job still waiting when the queue closes never runs and is cleaned up
+	q.Close()
+	if cleanups != 1 {
+		t.Errorf("Expected one cleanup, got %d", cleanups)
+	}
+	if _, err := q.Submit("compress", block); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	q := NewQueue(1, 4)
+	defer q.Close()
+	q.Retention = 0
+
+	done, _ := q.Submit("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		return "", nil
+	})
+	waitFor(t, q, done.ID)
+	q.Submit("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		return "", nil
+	})
+	if _, err := q.Get(done.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired job to be dropped, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	q := NewQueue(1, 1)
+	defer q.Close()
+
+	release := make(chan struct{})
+	status, _ := q.Submit("compress", func(ctx context.Context, progress func(Progress)) (string, error) {
+		<-release
+		progress(Progress{Fraction: 0.5, Phase: "encoding", BytesRead: 10, BytesWritten: 4})
+		return "out.huff", nil
+	})
+	updates, unsubscribe, err := q.Subscribe(status.ID)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+	close(release)
+
+	var last Status
+	for update := range updates {
+		last = update
+	}
+	if last.State != Done || last.Result != "out.huff" || last.BytesRead != 10 || last.Phase != "encoding" {
+		t.Errorf("Unexpected last update: %+v", last)
+	}
+
+	// Subscribing to a finished job yields its final status only
+	updates, _, err = q.Subscribe(status.ID)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if final, ok := <-updates; !ok || final.State != Done {
+		t.Errorf("Expected final status, got %+v", final)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("Expected channel to be closed")
+	}
+	if _, _, err := q.Subscribe("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -1,43 +1,93 @@
 package main
 
 import (
-	"Compression_Upc/routes"
-	"flag"
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-func main() {
-	// Define flags properly
-	port := flag.String("p", "8080", "port to serve on")
-	help := flag.Bool("h", false, "display help")
-
-	// Parse flags
-	flag.Parse()
-
-	// Show help if requested
-	if *help {
-		fmt.Println("Uso: go run main.go [opciones]")
-		fmt.Println("Opciones:")
-		fmt.Println("  -p, --port <puerto>   Especifica el puerto en el que se ejecutará el servidor (por defecto es 8080)")
-		fmt.Println("  -h, --help            Muestra esta ayuda")
-		return
+// progName is how the program calls itself in messages.
+const progName = "huff"
+
+// Exit codes, as in gzip: usage errors are told apart from failed files.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// streams are the standard streams of a command, replaced in tests.
+type streams struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string, s streams) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"compress", "Comprime archivos o la entrada estándar", runCompress},
+		{"decompress", "Descomprime archivos .huff o la entrada estándar", runDecompress},
+		{"verify", "Comprueba la integridad y la firma de archivos .huff", runVerify},
+		{"inspect", "Muestra la estructura y la tabla de códigos de archivos .huff", runInspect},
+		{"table", "Exporta la tabla de frecuencias o de códigos, o construye los códigos de una tabla", runTable},
+		{"repair", "Repara un archivo .huff usando su paridad Reed-Solomon", runRepair},
+		{"bench", "Mide cada codec sobre un corpus generado", runBench},
+		{"keygen", "Genera un par de claves Ed25519 para firmar", runKeygen},
+		{"serve", "Inicia el servidor web (por defecto)", runServe},
 	}
+}
 
-	// Format port string
-	cobraPort := ":" + *port
+func main() {
+	// Ctrl+C stops the current file at the next block and removes its partial output
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], streams{os.Stdin, os.Stdout, os.Stderr})
+	stop()
+	os.Exit(code)
+}
 
-	// Create and configure server
-	s := http.Server{
-		Addr:    cobraPort,
-		Handler: routes.MuxRoutes(),
+// run dispatches to the command named by args[0]. Without a command, or when
+// args start with a flag, it serves as older versions did.
+func run(ctx context.Context, args []string, s streams) int {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelp(args[0])) {
+		return runServe(ctx, args, s)
+	}
+	if isHelp(args[0]) || args[0] == "help" {
+		printUsage(s.stdout)
+		return exitOK
 	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(ctx, args[1:], s)
+		}
+	}
+	fmt.Fprintf(s.stderr, "%s: comando desconocido %q\n", progName, args[0])
+	printUsage(s.stderr)
+	return exitUsage
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
 
-	fmt.Printf("Servidor iniciando en http://localhost%s\n", cobraPort)
-	err := s.ListenAndServe()
-	if err != nil {
-		fmt.Printf("Error al iniciar el servidor: %v\n", err)
-		return
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Uso: %s <comando> [opciones] [archivos]\n", progName)
+	fmt.Fprintln(w, "Comandos:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
 	}
-	fmt.Println("Servidor detenido")
+	fmt.Fprintf(w, "Use \"%s <comando> -h\" para ver las opciones de cada comando.\n", progName)
+	fmt.Fprintln(w, "Sin comando se inicia el servidor web con las opciones de serve.")
+	fmt.Fprintln(w, "Códigos de salida: 0 éxito, 1 error en algún archivo, 2 uso incorrecto.")
 }
@@ -0,0 +1,289 @@
+package routes
+
+import (
+	"Compression_Upc/storage"
+	_ "embed"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The versioned API speaks JSON only: results carry sizes, timing and
+// checksums, and every error is an object with a stable code. Routes are
+// listed in apiEndpoints, which the tests check against openapi.json.
+const apiPrefix = "/api/v1"
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+type apiEndpoint struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+}
+
+var apiEndpoints = []apiEndpoint{
+	{http.MethodPost, apiPrefix + "/compress", apiCompressHandler},
+	{http.MethodPost, apiPrefix + "/decompress", apiDecompressHandler},
+	{http.MethodPost, apiPrefix + "/verify", apiVerifyHandler},
+	{http.MethodPost, apiPrefix + "/inspect", apiInspectHandler},
+	{http.MethodPost, apiPrefix + "/compare", apiCompareHandler},
+	{http.MethodPost, apiPrefix + "/tree", apiTreeHandler},
+	{http.MethodPost, apiPrefix + "/tables/export", apiTableExportHandler},
+	{http.MethodPost, apiPrefix + "/tables/import", apiTableImportHandler},
+	{http.MethodPost, apiPrefix + "/encode-text", apiEncodeTextHandler},
+	{http.MethodPost, apiPrefix + "/decode-text", apiDecodeTextHandler},
+	{http.MethodGet, apiPrefix + "/results/{id}", apiGetResultHandler},
+	{http.MethodDelete, apiPrefix + "/results/{id}", apiDeleteResultHandler},
+	{http.MethodPost, apiPrefix + "/jobs", apiCreateJobHandler},
+	{http.MethodGet, apiPrefix + "/jobs/{id}", apiJobHandler},
+	{http.MethodDelete, apiPrefix + "/jobs/{id}", apiJobHandler},
+	{http.MethodGet, apiPrefix + "/jobs/{id}/events", apiJobEventsHandler},
+	{http.MethodGet, apiPrefix + "/openapi.json", apiOpenAPIHandler},
+}
+
+// registerAPI adds the /api/v1 routes to mux. Wrong methods and unknown
+// paths get JSON errors too.
+func registerAPI(mux *http.ServeMux) {
+	var paths []string
+	byPath := map[string]map[string]http.HandlerFunc{}
+	for _, e := range apiEndpoints {
+		if byPath[e.path] == nil {
+			byPath[e.path] = map[string]http.HandlerFunc{}
+			paths = append(paths, e.path)
+		}
+		byPath[e.path][e.method] = e.handler
+	}
+	for _, path := range paths {
+		methods := byPath[path]
+		var allow []string
+		for method := range methods {
+			allow = append(allow, method)
+		}
+		slices.Sort(allow)
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			handler, ok := methods[r.Method]
+			if !ok {
+				w.Header().Set("Allow", strings.Join(allow, ", "))
+				writeAPIError(w, newAPIError(http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed"))
+				return
+			}
+			handler(w, r)
+		})
+	}
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, newAPIError(http.StatusNotFound, "not_found", "Unknown endpoint"))
+	})
+}
+
+// writeAPIError answers with {"error": {"code": ..., "message": ...}}.
+func writeAPIError(w http.ResponseWriter, err *apiError) {
+	if err == errClientGone {
+		return
+	}
+	writeJSON(w, err.Status, struct {
+		Error *apiError `json:"error"`
+	}{err})
+}
+
+// apiResult describes the output of /api/v1/compress and /api/v1/decompress.
+// Ratio is always compressed size over original size.
+type apiResult struct {
+	ID             string       `json:"id"`
+	Operation      string       `json:"operation"`
+	FileName       string       `json:"fileName"`
+	Algorithm      string       `json:"algorithm,omitempty"`
+	OriginalSize   int64        `json:"originalSize"`
+	CompressedSize int64        `json:"compressedSize"`
+	Ratio          float64      `json:"ratio"`
+	DurationMs     float64      `json:"durationMs"`
+	Checksums      apiChecksums `json:"checksums"`
+	Download       string       `json:"download"`
+	ExpiresAt      time.Time    `json:"expiresAt"`
+}
+
+// apiChecksums holds the hex SHA-256 of the uploaded file and the result.
+type apiChecksums struct {
+	Algorithm string `json:"algorithm"`
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+}
+
+func apiCompressHandler(w http.ResponseWriter, r *http.Request) {
+	apiOperationHandler(w, r, "compress")
+}
+
+func apiDecompressHandler(w http.ResponseWriter, r *http.Request) {
+	apiOperationHandler(w, r, "decompress")
+}
+
+func apiOperationHandler(w http.ResponseWriter, r *http.Request, operation string) {
+	op, apiErr := parseOperation(w, r, operation)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	defer op.file.Close()
+
+	out, apiErr := op.run(r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	result := apiResult{
+		ID:             out.ID,
+		Operation:      operation,
+		FileName:       op.outputName,
+		OriginalSize:   out.InputSize,
+		CompressedSize: out.OutputSize,
+		DurationMs:     float64(out.Duration.Microseconds()) / 1000,
+		Checksums:      apiChecksums{Algorithm: "sha256", Input: out.InputSHA256, Output: out.OutputSHA256},
+		Download:       apiPrefix + "/results/" + out.ID,
+		ExpiresAt:      out.ExpiresAt,
+	}
+	if operation == "compress" {
+		result.Algorithm = op.opts.Codec
+		if result.Algorithm == "" {
+			result.Algorithm = "huffman"
+		}
+	} else {
+		result.OriginalSize, result.CompressedSize = out.OutputSize, out.InputSize
+	}
+	if result.OriginalSize > 0 {
+		result.Ratio = float64(result.CompressedSize) / float64(result.OriginalSize)
+	}
+	w.Header().Set("Location", result.Download)
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func apiVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	report, apiErr := verifyUpload(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func apiInspectHandler(w http.ResponseWriter, r *http.Request) {
+	report, apiErr := inspectUpload(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func apiCompareHandler(w http.ResponseWriter, r *http.Request) {
+	result, apiErr := compareUpload(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func apiTreeHandler(w http.ResponseWriter, r *http.Request) {
+	trace, apiErr := traceUpload(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, trace)
+}
+
+func apiTableExportHandler(w http.ResponseWriter, r *http.Request) {
+	export, apiErr := exportTable(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, export)
+}
+
+func apiTableImportHandler(w http.ResponseWriter, r *http.Request) {
+	codes, apiErr := importTable(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func apiEncodeTextHandler(w http.ResponseWriter, r *http.Request) {
+	encoding, apiErr := encodeText(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, encoding)
+}
+
+func apiDecodeTextHandler(w http.ResponseWriter, r *http.Request) {
+	decoding, apiErr := decodeText(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, decoding)
+}
+
+func apiGetResultHandler(w http.ResponseWriter, r *http.Request) {
+	if apiErr := serveResult(w, r, r.PathValue("id")); apiErr != nil {
+		writeAPIError(w, apiErr)
+	}
+}
+
+func apiDeleteResultHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !isWorkspaceID(id) {
+		writeAPIError(w, errResultNotFound)
+		return
+	}
+	err := resultStore.Delete(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeAPIError(w, errResultNotFound)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, newAPIError(http.StatusInternalServerError, "internal", "Error deleting result"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiCreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	status, apiErr := enqueueJob(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/jobs/"+status.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func apiJobHandler(w http.ResponseWriter, r *http.Request) {
+	status, apiErr := jobRequest(r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func apiJobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	updates, unsubscribe, err := jobQueue.Subscribe(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, errJobNotFound)
+		return
+	}
+	defer unsubscribe()
+	streamJobEvents(w, r, updates)
+}
+
+func apiOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
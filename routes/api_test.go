@@ -0,0 +1,472 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAPISpec is the decoded openapi.json.
+type openAPISpec map[string]any
+
+func loadOpenAPI(t *testing.T) openAPISpec {
+	t.Helper()
+	var spec openAPISpec
+	if err := json.Unmarshal(openAPIDocument, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return spec
+}
+
+// operation returns the spec of method on path.
+func (s openAPISpec) operation(method, path string) map[string]any {
+	paths, _ := s["paths"].(map[string]any)
+	item, _ := paths[path].(map[string]any)
+	op, _ := item[strings.ToLower(method)].(map[string]any)
+	return op
+}
+
+// resolve follows a local $ref.
+func (s openAPISpec) resolve(schema map[string]any) (map[string]any, error) {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema, nil
+	}
+	var node any = map[string]any(s)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		node = m[part]
+	}
+	resolved, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %s", ref)
+	}
+	return resolved, nil
+}
+
+// validate checks value, decoded from JSON, against the subset of JSON
+// Schema used by openapi.json.
+func (s openAPISpec) validate(schema map[string]any, value any, at string) error {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return err
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, value)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: undocumented property %s", at, name)
+				}
+				continue
+			}
+			if err := s.validate(prop, v, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, value)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, v := range arr {
+			if err := s.validate(items, v, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, value)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected a number, got %T", at, value)
+		}
+		if schema["type"] == "integer" && n != float64(int64(n)) {
+			return fmt.Errorf("%s: %v is not an integer", at, n)
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%s: %v is below %v", at, n, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("%s: %v is above %v", at, n, max)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, value)
+		}
+	}
+	return nil
+}
+
+// checkResponse validates a JSON response against the schema documented for
+// its status code and returns the decoded body.
+func (s openAPISpec) checkResponse(t *testing.T, method, path string, rr *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	op := s.operation(method, path)
+	if op == nil {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	responses, _ := op["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(rr.Code)].(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s: status %d is not documented: %s", method, path, rr.Code, rr.Body.String())
+	}
+	content, _ := response["content"].(map[string]any)
+	media, ok := content["application/json"].(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s: status %d has no JSON schema", method, path, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: unexpected Content-Type %q", method, path, ct)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: invalid JSON %q", method, path, rr.Body.String())
+	}
+	if err := s.validate(media["schema"].(map[string]any), body, "body"); err != nil {
+		t.Errorf("%s %s: %v", method, path, err)
+	}
+	return body
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	spec := loadOpenAPI(t)
+	if v, _ := spec["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Fatalf("Expected an OpenAPI 3 document, got version %q", v)
+	}
+
+	// Routes and document match both ways
+	routes := map[string]bool{}
+	for _, e := range apiEndpoints {
+		routes[strings.ToLower(e.method)+" "+e.path] = true
+		if spec.operation(e.method, e.path) == nil {
+			t.Errorf("%s %s is not documented", e.method, e.path)
+		}
+	}
+	for path, item := range spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			if method != "parameters" && !routes[method+" "+path] {
+				t.Errorf("%s %s is documented but not routed", method, path)
+			}
+		}
+	}
+
+	// Every $ref resolves
+	var walk func(node any)
+	walk = func(node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			if _, err := spec.resolve(n); err != nil {
+				t.Error(err)
+			}
+			for _, v := range n {
+				walk(v)
+			}
+		case []any:
+			for _, v := range n {
+				walk(v)
+			}
+		}
+	}
+	walk(map[string]any(spec))
+
+	// The method enum lists every codec
+	want := []any{huffman.CodecAuto}
+	for _, c := range huffman.Codecs() {
+		want = append(want, c.Name())
+	}
+	method := spec["components"].(map[string]any)["schemas"].(map[string]any)["CompressRequest"].(map[string]any)["properties"].(map[string]any)["method"].(map[string]any)
+	got := method["enum"].([]any)
+	if len(got) != len(want) {
+		t.Errorf("Method enum %v does not match codecs %v", got, want)
+	}
+	for _, name := range want {
+		if !slices.Contains(got, name) {
+			t.Errorf("Codec %v missing from the method enum", name)
+		}
+	}
+
+	// The document is served
+	rr := httptest.NewRecorder()
+	MuxRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), openAPIDocument) {
+		t.Errorf("Unexpected openapi.json response %d", rr.Code)
+	}
+}
+
+func TestAPICompressDecompress(t *testing.T) {
+	spec := loadOpenAPI(t)
+	mux := MuxRoutes()
+	content := []byte(strings.Repeat("versioned api ", 3000))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/compress", "api.txt", content, map[string]string{"method": "rle-huffman"}))
+	result := spec.checkResponse(t, "POST", "/api/v1/compress", rr)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rr.Code)
+	}
+	sum := sha256.Sum256(content)
+	checksums := result["checksums"].(map[string]any)
+	if result["algorithm"] != "rle-huffman" || result["fileName"] != "api.txt.huff" ||
+		result["originalSize"] != float64(len(content)) || checksums["input"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected result %v", result)
+	}
+	if ratio := result["ratio"].(float64); ratio <= 0 || ratio >= 1 || ratio != result["compressedSize"].(float64)/result["originalSize"].(float64) {
+		t.Errorf("Unexpected ratio %v", ratio)
+	}
+	if rr.Header().Get("Location") != result["download"] {
+		t.Errorf("Location %q does not match download %q", rr.Header().Get("Location"), result["download"])
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, result["download"].(string), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 downloading, got %d", rr.Code)
+	}
+	compressed := rr.Body.Bytes()
+	if sum := sha256.Sum256(compressed); checksums["output"] != hex.EncodeToString(sum[:]) {
+		t.Error("Output checksum does not match the download")
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/decompress", "api.txt.huff", compressed, nil))
+	result = spec.checkResponse(t, "POST", "/api/v1/decompress", rr)
+	if result["fileName"] != "api.txt" || result["originalSize"] != float64(len(content)) ||
+		result["compressedSize"] != float64(len(compressed)) || result["checksums"].(map[string]any)["output"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	// Results can be deleted without downloading them
+	path := result["download"].(string)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, path, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 deleting, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if body := spec.checkResponse(t, "GET", "/api/v1/results/{id}", rr); rr.Code != http.StatusNotFound || body["error"].(map[string]any)["code"] != "not_found" {
+		t.Errorf("Expected not_found after deleting, got %d %v", rr.Code, body)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/verify", "api.txt.huff", compressed, nil))
+	if report := spec.checkResponse(t, "POST", "/api/v1/verify", rr); report["blocks"].(float64) < 1 {
+		t.Errorf("Unexpected report %v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/inspect", "api.txt.huff", compressed, nil))
+	if report := spec.checkResponse(t, "POST", "/api/v1/inspect", rr); report["compressedSize"] != float64(len(compressed)) {
+		t.Errorf("Unexpected inspection %v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/compare", "api.txt", content, nil))
+	if result := spec.checkResponse(t, "POST", "/api/v1/compare", rr); result["best"] == "" || len(result["results"].([]any)) < 2 {
+		t.Errorf("Unexpected comparison %v", result)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/tree", "api.txt", content, nil))
+	if trace := spec.checkResponse(t, "POST", "/api/v1/tree", rr); len(trace["steps"].([]any)) == 0 {
+		t.Errorf("Unexpected trace %v", trace)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/tables/export", "api.txt", content, nil))
+	if export := spec.checkResponse(t, "POST", "/api/v1/tables/export", rr); export["codes"] == nil {
+		t.Errorf("Unexpected tables %v", export)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/tables/import", "", nil, map[string]string{"table": "symbol,probability\n97,0.5\n98,0.5\n"}))
+	if codes := spec.checkResponse(t, "POST", "/api/v1/tables/import", rr); codes["bitsPerSymbol"] != 1.0 {
+		t.Errorf("Unexpected codes %v", codes)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/encode-text", "", nil, map[string]string{"text": "abracadabra"}))
+	if encoding := spec.checkResponse(t, "POST", "/api/v1/encode-text", rr); encoding["totalBits"] != 23.0 {
+		t.Errorf("Unexpected encoding %v", encoding)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/decode-text", "", nil, map[string]string{"bits": "0110", "codes": `[{"symbol": 104, "code": "0"}, {"symbol": 105, "code": "1"}]`}))
+	if decoding := spec.checkResponse(t, "POST", "/api/v1/decode-text", rr); decoding["text"] != "hiih" {
+		t.Errorf("Unexpected decoding %v", decoding)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	spec := loadOpenAPI(t)
+	mux := MuxRoutes()
+	compressed := compressTestFile(t)
+
+	noFile := httptest.NewRequest(http.MethodPost, "/api/v1/compress", strings.NewReader(""))
+	bomb := newUploadRequest(t, "/api/v1/decompress", "bomb.huff", zeroBomb(t), nil)
+	Configure(Config{MaxUploadSize: 1 << 20, MaxDecompressedSize: 1 << 20})
+	t.Cleanup(func() { Configure(Config{}) })
+	cases := []struct {
+		name, method, path string
+		req                *http.Request
+		code               int
+		errCode            string
+	}{
+		{"no file", "POST", "/api/v1/compress", noFile, http.StatusBadRequest, "file_required"},
+		{"unknown method", "POST", "/api/v1/compress",
+			newUploadRequest(t, "/api/v1/compress", "a.txt", []byte("data"), map[string]string{"method": "zip"}), http.StatusBadRequest, "unknown_method"},
+		{"bad parity", "POST", "/api/v1/compress",
+			newUploadRequest(t, "/api/v1/compress", "a.txt", []byte("data"), map[string]string{"parity": "99"}), http.StatusBadRequest, "invalid_parity"},
+		{"bad extension", "POST", "/api/v1/decompress",
+			newUploadRequest(t, "/api/v1/decompress", "a.txt", compressed, nil), http.StatusBadRequest, "invalid_extension"},
+		{"not signed", "POST", "/api/v1/decompress",
+			newUploadRequest(t, "/api/v1/decompress", "a.txt.huff", compressed, map[string]string{"publicKey": testPublicKeyPEM(t)}), http.StatusUnprocessableEntity, "not_signed"},
+		{"not a container", "POST", "/api/v1/verify",
+			newUploadRequest(t, "/api/v1/verify", "a.huff", []byte("garbage"), nil), http.StatusBadRequest, "invalid_format"},
+		{"inspect not a container", "POST", "/api/v1/inspect",
+			newUploadRequest(t, "/api/v1/inspect", "a.huff", []byte("garbage"), nil), http.StatusBadRequest, "invalid_format"},
+		{"tree without input", "POST", "/api/v1/tree",
+			newUploadRequest(t, "/api/v1/tree", "", nil, map[string]string{"text": ""}), http.StatusBadRequest, "file_required"},
+		{"table without input", "POST", "/api/v1/tables/export",
+			newUploadRequest(t, "/api/v1/tables/export", "", nil, nil), http.StatusBadRequest, "file_required"},
+		{"invalid table", "POST", "/api/v1/tables/import",
+			newUploadRequest(t, "/api/v1/tables/import", "", nil, map[string]string{"table": "symbol,frequency\n300,1\n"}), http.StatusBadRequest, "invalid_table"},
+		{"encode without text", "POST", "/api/v1/encode-text",
+			newUploadRequest(t, "/api/v1/encode-text", "", nil, nil), http.StatusBadRequest, "text_required"},
+		{"invalid code table", "POST", "/api/v1/decode-text",
+			newUploadRequest(t, "/api/v1/decode-text", "", nil, map[string]string{"bits": "0", "codes": "symbol\n97\n"}), http.StatusBadRequest, "invalid_code_table"},
+		{"invalid bits", "POST", "/api/v1/decode-text",
+			newUploadRequest(t, "/api/v1/decode-text", "", nil, map[string]string{"bits": "1", "codes": "symbol,code\n97,0\n"}), http.StatusUnprocessableEntity, "invalid_bits"},
+		{"wrong method", "POST", "/api/v1/compress",
+			httptest.NewRequest(http.MethodGet, "/api/v1/compress", nil), http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"unknown job", "GET", "/api/v1/jobs/{id}",
+			httptest.NewRequest(http.MethodGet, "/api/v1/jobs/nope", nil), http.StatusNotFound, "not_found"},
+		{"bad operation", "POST", "/api/v1/jobs",
+			newUploadRequest(t, "/api/v1/jobs", "a.txt", []byte("data"), map[string]string{"operation": "zip"}), http.StatusBadRequest, "invalid_operation"},
+		{"too large", "POST", "/api/v1/compress",
+			newUploadRequest(t, "/api/v1/compress", "a.txt", make([]byte, 2<<20), nil), http.StatusRequestEntityTooLarge, "file_too_large"},
+		{"bomb", "POST", "/api/v1/decompress", bomb, http.StatusUnprocessableEntity, "limit_exceeded"},
+		{"bad result id", "GET", "/api/v1/results/{id}",
+			httptest.NewRequest(http.MethodGet, "/api/v1/results/..%2Fsecret", nil), http.StatusNotFound, "not_found"},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, c.req)
+		body := spec.checkResponse(t, c.method, c.path, rr)
+		if rr.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.code, rr.Code)
+		}
+		if e, _ := body["error"].(map[string]any); e == nil || e["code"] != c.errCode {
+			t.Errorf("%s: expected error code %s, got %v", c.name, c.errCode, body)
+		}
+	}
+
+	// Unknown endpoints answer in JSON too
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"not_found"`) {
+		t.Errorf("Unexpected answer for an unknown endpoint: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAPIJobs(t *testing.T) {
+	spec := loadOpenAPI(t)
+	mux := MuxRoutes()
+	content := []byte(strings.Repeat("api job ", 4000))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/api/v1/jobs", "job.txt", content, map[string]string{"operation": "compress"}))
+	status := spec.checkResponse(t, "POST", "/api/v1/jobs", rr)
+	id := status["id"].(string)
+	if rr.Header().Get("Location") != "/api/v1/jobs/"+id {
+		t.Errorf("Unexpected Location %q", rr.Header().Get("Location"))
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for status["state"] != "done" {
+		if time.Now().After(deadline) || status["state"] == "failed" {
+			t.Fatalf("Job did not finish: %v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+id, nil))
+		status = spec.checkResponse(t, "GET", "/api/v1/jobs/{id}", rr)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/v1/jobs/"+id, nil))
+	if body := spec.checkResponse(t, "DELETE", "/api/v1/jobs/{id}", rr); rr.Code != http.StatusConflict || body["error"].(map[string]any)["code"] != "job_finished" {
+		t.Errorf("Expected job_finished, got %d %v", rr.Code, body)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/results/"+status["result"].(string), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 downloading the job result, got %d", rr.Code)
+	}
+}
+
+// zeroBomb returns a small container that expands to 4 MiB of zeros.
+func zeroBomb(t *testing.T) []byte {
+	var bomb bytes.Buffer
+	zw, err := huffman.NewWriter(&bomb, huffman.Options{Codec: "rle-huffman"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(make([]byte, 4<<20))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bomb.Bytes()
+}
+
+func testPublicKeyPEM(t *testing.T) string {
+	t.Helper()
+	pub, _, err := huffman.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := huffman.MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"Compression_Upc/bench"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// compareResult is the answer of /compare: how every codec does on the upload.
+type compareResult struct {
+	FileName string `json:"fileName"`
+	*bench.CompareReport
+}
+
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result, apiErr := compareUpload(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// compareUpload compresses the upload with every registered codec in
+// parallel. The file is kept in memory, which the upload limit bounds.
+func compareUpload(w http.ResponseWriter, r *http.Request) (*compareResult, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	fileName := sanitizeFilename(header.Filename)
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	start := time.Now()
+	report, err := bench.Compare(ctx, data, nil, 0)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("Comparison of %s timed out", fileName)
+		return nil, newAPIError(http.StatusGatewayTimeout, "timeout", "Comparison timed out")
+	case errors.Is(err, context.Canceled):
+		return nil, errClientGone
+	case err != nil:
+		log.Printf("Comparison of %s failed: %v", fileName, err)
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error comparing methods")
+	}
+	log.Printf("Comparison of %s took %v", fileName, time.Since(start))
+	return &compareResult{FileName: fileName, CompareReport: report}, nil
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareHandler(t *testing.T) {
+	content := []byte(strings.Repeat("aaaaaaaabbbbcc compare every codec ", 2000))
+	rr := httptest.NewRecorder()
+	compareHandler(rr, newUploadRequest(t, "/compare", "compare.txt", content, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		FileName     string  `json:"fileName"`
+		OriginalSize int64   `json:"originalSize"`
+		Entropy      float64 `json:"entropy"`
+		Best         string  `json:"best"`
+		Results      []struct {
+			Codec          string  `json:"codec"`
+			CompressedSize int64   `json:"compressedSize"`
+			BitsPerSymbol  float64 `json:"bitsPerSymbol"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if result.FileName != "compare.txt" || result.OriginalSize != int64(len(content)) || result.Entropy <= 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	codecs := make(map[string]float64)
+	for _, r := range result.Results {
+		codecs[r.Codec] = r.BitsPerSymbol
+	}
+	for _, name := range []string{"stored", "huffman", "rle", "rle-huffman", "auto"} {
+		if _, ok := codecs[name]; !ok {
+			t.Errorf("Missing codec %s in %+v", name, result.Results)
+		}
+	}
+	if codecs["huffman"] >= codecs["stored"] || codecs[result.Best] > codecs["huffman"] {
+		t.Errorf("Unexpected bits per symbol %v, best %s", codecs, result.Best)
+	}
+
+	rr = httptest.NewRecorder()
+	compareHandler(rr, httptest.NewRequest(http.MethodGet, "/compare", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"Compression_Upc/jobs"
+	"Compression_Upc/storage"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Config holds the server-wide settings that handlers read.
+type Config struct {
+	// SigningKey, when set, signs every file produced by /compress.
+	SigningKey ed25519.PrivateKey
+	// Workers is the number of background jobs run at once (default: number of CPUs).
+	Workers int
+	// QueueSize is the number of jobs that can wait for a worker (default 64).
+	QueueSize int
+	// Store keeps results until they are downloaded (default: in memory).
+	Store storage.Store
+	// ResultTTL is how long an undownloaded result is kept (default 1h).
+	ResultTTL time.Duration
+	// JanitorInterval is how often expired results are purged (default 5m).
+	JanitorInterval time.Duration
+	// SpoolMemory is how much of an upload to /compress/stream is buffered in
+	// memory before spilling to a temporary file (default 8 MiB).
+	SpoolMemory int64
+	// MaxUploadSize bounds the request body of every upload (default 100 MiB).
+	MaxUploadSize int64
+	// MaxDecompressedSize bounds the output of a decompression (default 1 GiB).
+	MaxDecompressedSize int64
+	// MaxRatio bounds how much a file may expand when decompressed, measured
+	// as output size over input size (default 1000).
+	MaxRatio float64
+}
+
+const (
+	defaultQueueSize       = 64
+	defaultResultTTL       = time.Hour
+	defaultJanitorInterval = 5 * time.Minute
+	defaultSpoolMemory     = 8 << 20
+	defaultMaxUploadSize   = 100 << 20
+	defaultMaxDecompressed = 1 << 30
+	defaultMaxRatio        = 1000
+)
+
+func (c Config) resultTTL() time.Duration {
+	if c.ResultTTL <= 0 {
+		return defaultResultTTL
+	}
+	return c.ResultTTL
+}
+
+func (c Config) spoolMemory() int64 {
+	if c.SpoolMemory <= 0 {
+		return defaultSpoolMemory
+	}
+	return c.SpoolMemory
+}
+
+func (c Config) maxUploadSize() int64 {
+	if c.MaxUploadSize <= 0 {
+		return defaultMaxUploadSize
+	}
+	return c.MaxUploadSize
+}
+
+func (c Config) maxDecompressedSize() int64 {
+	if c.MaxDecompressedSize <= 0 {
+		return defaultMaxDecompressed
+	}
+	return c.MaxDecompressedSize
+}
+
+func (c Config) maxRatio() float64 {
+	if c.MaxRatio <= 0 {
+		return defaultMaxRatio
+	}
+	return c.MaxRatio
+}
+
+// requestTimeout bounds the synchronous /compress, /decompress and /compare handlers.
+var requestTimeout = 60 * time.Second
+
+var (
+	config      Config
+	jobQueue                  = newJobQueue(Config{})
+	resultStore storage.Store = storage.NewMemory()
+	stopJanitor               = func() {}
+)
+
+// Configure sets the server configuration and starts the janitor that purges
+// expired results. Call it before serving requests.
+func Configure(cfg Config) {
+	config = cfg
+	old := jobQueue
+	jobQueue = newJobQueue(cfg)
+	old.Close()
+
+	resultStore = cfg.Store
+	if resultStore == nil {
+		resultStore = storage.NewMemory()
+	}
+	stopJanitor()
+	interval := cfg.JanitorInterval
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopJanitor = cancel
+	go storage.RunJanitor(ctx, resultStore, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removeStaleWorkspaces(cfg.resultTTL())
+			}
+		}
+	}()
+}
+
+func newJobQueue(cfg Config) *jobs.Queue {
+	workers, size := cfg.Workers, cfg.QueueSize
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	return jobs.NewQueue(workers, size)
+}
+
+var errInvalidPublicKey = errors.New("invalid public key")
+
+// publicKeyFromForm reads the optional PEM "publicKey" form field used to
+// require a signature from a specific key.
+func publicKeyFromForm(r *http.Request) (ed25519.PublicKey, error) {
+	value := strings.TrimSpace(r.FormValue("publicKey"))
+	if value == "" {
+		return nil, nil
+	}
+	key, err := huffman.ParsePublicKey([]byte(value))
+	if err != nil {
+		return nil, errInvalidPublicKey
+	}
+	return key, nil
+}
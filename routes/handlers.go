@@ -2,12 +2,12 @@ package routes
 
 import (
 	"Compression_Upc/huffman"
+	"Compression_Upc/storage"
+	"errors"
 	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -29,8 +29,21 @@ func MuxRoutes() *http.ServeMux {
 	})
 
 	mux.HandleFunc("/compress", compressHandler)
+	mux.HandleFunc("/compress/stream", compressStreamHandler)
 	mux.HandleFunc("/decompress", decompressHandler)
 	mux.HandleFunc("/download", downloadHandler)
+	mux.HandleFunc("/verify", verifyHandler)
+	mux.HandleFunc("/inspect", inspectHandler)
+	mux.HandleFunc("/compare", compareHandler)
+	mux.HandleFunc("/tree", treeHandler)
+	mux.HandleFunc("/tables/export", tableExportHandler)
+	mux.HandleFunc("/tables/import", tableImportHandler)
+	mux.HandleFunc("/encode-text", encodeTextHandler)
+	mux.HandleFunc("/decode-text", decodeTextHandler)
+	mux.HandleFunc("/jobs", jobsHandler)
+	mux.HandleFunc("/jobs/{id}", jobHandler)
+	mux.HandleFunc("/jobs/{id}/events", jobEventsHandler)
+	registerAPI(mux)
 
 	return mux
 }
@@ -40,46 +53,70 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	fileName := r.URL.Query().Get("file")
-	if fileName == "" {
-		http.Error(w, "File name is required", http.StatusBadRequest)
-		return
+	if err := serveResult(w, r, r.URL.Query().Get("id")); err != nil {
+		writeError(w, err)
 	}
+}
 
-	// Sanitize filename to prevent path traversal
-	fileName = filepath.Base(fileName)
+var errResultNotFound = newAPIError(http.StatusNotFound, "not_found", "File not found")
 
-	// Ensure the file is in the process directory
-	filePath := filepath.Join("process", fileName)
-	if !strings.HasPrefix(filePath, filepath.Join("process")) {
-		http.Error(w, "Invalid file path", http.StatusBadRequest)
-		return
+// serveResult sends the result stored under id and deletes it. Errors are
+// returned before anything is written.
+func serveResult(w http.ResponseWriter, r *http.Request, id string) *apiError {
+	// Results are only reachable through the ID returned by the operation
+	if !isWorkspaceID(id) {
+		return errResultNotFound
+	}
+
+	// Stores that can presign links serve the file themselves. The result is
+	// then left to expire, since there is no way to tell when it was fetched.
+	if presigner, ok := resultStore.(storage.Presigner); ok {
+		return presignDownload(w, r, presigner, id)
 	}
 
-	file, err := os.Open(filePath)
+	file, meta, err := resultStore.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "File not found", http.StatusNotFound)
-		return
+		return errResultNotFound
 	}
 	defer file.Close()
 
 	// Set headers with sanitized filename
-	w.Header().Set("Content-Disposition", "attachment; filename="+sanitizeFilename(fileName))
+	w.Header().Set("Content-Disposition", "attachment; filename="+sanitizeFilename(meta.Name))
 	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
 
 	// Copy file to response
 	_, err = io.Copy(w, file)
 	if err != nil {
-		http.Error(w, "Error sending file", http.StatusInternalServerError)
-		return
+		log.Printf("Error sending result %s: %v", id, err)
+		return nil
 	}
 
-	// Delete file after sending
-	if err := os.Remove(filePath); err != nil {
+	// Delete the result after sending
+	if err := resultStore.Delete(r.Context(), id); err != nil {
 		// Log the error but don't return it to the client
-		log.Printf("Error removing file %s: %v", filePath, err)
+		log.Printf("Error removing result %s: %v", id, err)
+	}
+	return nil
+}
+
+// presignDownloadTTL bounds how long a download link stays valid.
+const presignDownloadTTL = 5 * time.Minute
+
+// presignDownload redirects to a temporary link to the result.
+func presignDownload(w http.ResponseWriter, r *http.Request, presigner storage.Presigner, id string) *apiError {
+	// Stat checks that the result exists and has not expired
+	meta, err := resultStore.Stat(r.Context(), id)
+	if err != nil {
+		return errResultNotFound
+	}
+	link, err := presigner.PresignGet(id, sanitizeFilename(meta.Name), presignDownloadTTL)
+	if err != nil {
+		log.Printf("Error presigning result %s: %v", id, err)
+		return newAPIError(http.StatusInternalServerError, "internal", "Error preparing download")
 	}
+	http.Redirect(w, r, link, http.StatusFound)
+	return nil
 }
 
 // Add this helper function
@@ -98,132 +135,175 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
+	operationHandler(w, r, "compress")
+}
 
-	// Handle file upload
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Error reading file", http.StatusBadRequest)
+func decompressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	defer file.Close()
+	operationHandler(w, r, "decompress")
+}
 
-	// Get and validate filename
-	fileName := strings.TrimSpace(r.FormValue("fileName"))
-	if fileName == "" {
-		http.Error(w, "File name is required", http.StatusBadRequest)
+// operationHandler runs a synchronous compress or decompress request and
+// answers with the ID of the result.
+func operationHandler(w http.ResponseWriter, r *http.Request, operation string) {
+	op, apiErr := parseOperation(w, r, operation)
+	if apiErr != nil {
+		writeError(w, apiErr)
 		return
 	}
+	defer op.file.Close()
 
-	// Sanitize filename to prevent path traversal
-	fileName = filepath.Base(fileName)
-
-	// Create process directory if it doesn't exist
-	if err := os.MkdirAll("process", 0755); err != nil {
-		http.Error(w, "Error creating process directory", http.StatusInternalServerError)
+	out, apiErr := op.run(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
 		return
 	}
+	writeJSON(w, http.StatusOK, operationResult{
+		ID:       out.ID,
+		FileName: op.outputName,
+		Download: "/download?id=" + out.ID,
+	})
+}
 
-	// Save file
-	inputPath := filepath.Join("process", fileName)
-	if err := saveFile(file, fileName); err != nil {
-		http.Error(w, "Error saving file", http.StatusInternalServerError)
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	defer os.Remove(inputPath)
+	report, apiErr := verifyUpload(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
 
-	// Compress file with timing
-	outputPath := inputPath + ".huff"
-	start := time.Now()
-	resultCh := make(chan error)
-	go func(inputPath, outputPath string, result chan<- error) {
-		result <- huffman.Compress(inputPath, outputPath)
-	}(inputPath, outputPath, resultCh)
+// verifyUpload checks every block and the signature of the uploaded file
+// without writing the output.
+func verifyUpload(w http.ResponseWriter, r *http.Request) (*huffman.VerifyReport, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	defer file.Close()
 
-	select {
-	case err := <-resultCh:
-		if err != nil {
-			http.Error(w, "Error compressing file", http.StatusInternalServerError)
-			return
-		}
-		duration := time.Since(start)
-		log.Printf("Compression of %s took %v", fileName, duration)
-		w.Write([]byte(fileName + ".huff"))
-	case <-time.After(60 * time.Second):
-		http.Error(w, "Compression timed out", http.StatusGatewayTimeout)
-		return
+	// Optional public key the file must be signed with
+	verifyKey, err := publicKeyFromForm(r)
+	if err != nil {
+		return nil, optionError(errPublicKey)
+	}
+
+	report, err := huffman.VerifyWithOptions(file, huffman.Options{VerifyKey: verifyKey})
+	if errors.Is(err, huffman.ErrFormat) {
+		return nil, newAPIError(http.StatusBadRequest, "invalid_format", "File is not a valid .huff container")
 	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error verifying file")
+	}
+	return report, nil
 }
 
-func decompressHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		// Handle file upload
-		file, _, err := r.FormFile("file")
-		if err != nil {
-			http.Error(w, "Error reading file", http.StatusBadRequest)
-			return
-		}
-		defer file.Close()
+func inspectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report, apiErr := inspectUpload(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
 
-		// Get filename from form
-		fileName := r.FormValue("fileName")
-		if fileName == "" {
-			http.Error(w, "File name is required", http.StatusBadRequest)
-			return
-		}
+// inspectUpload describes the header, blocks and code table of the uploaded
+// file. The password is optional: without it an encrypted file shows only
+// its structure.
+func inspectUpload(w http.ResponseWriter, r *http.Request) (*huffman.InspectReport, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	defer file.Close()
 
-		// Remove spaces and verify .huff extension
-		fileName = strings.TrimSpace(fileName)
-		if !strings.HasSuffix(fileName, ".huff") {
-			http.Error(w, "File must have .huff extension", http.StatusBadRequest)
-			return
-		}
+	report, err := huffman.Inspect(file, huffman.Options{Password: r.FormValue("password")})
+	var blockErr *huffman.BlockError
+	switch {
+	case err == nil:
+		return report, nil
+	case errors.Is(err, huffman.ErrFormat):
+		return nil, newAPIError(http.StatusBadRequest, "invalid_format", "File is not a valid .huff file")
+	case errors.Is(err, huffman.ErrWrongPassword):
+		return nil, newAPIError(http.StatusUnauthorized, "wrong_password", "Wrong password")
+	case errors.As(err, &blockErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, newAPIError(http.StatusUnprocessableEntity, "corrupt_file", "Compressed file is corrupt, use /verify for details")
+	}
+	log.Printf("Inspecting upload failed: %v", err)
+	return nil, newAPIError(http.StatusInternalServerError, "internal", "Error inspecting file")
+}
 
-		// Save uploaded file
-		err = saveFile(file, fileName)
-		if err != nil {
-			http.Error(w, "Error saving file", http.StatusInternalServerError)
-			return
+// compressOptions reads the compression settings shared by /compress and /jobs.
+// Errors are meant to be shown to the client.
+func compressOptions(r *http.Request) (huffman.Options, error) {
+	// Compression method: a registered codec name or "auto" (default huffman)
+	opts := huffman.Options{Codec: strings.TrimSpace(r.FormValue("method"))}
+	if opts.Codec != "" && opts.Codec != huffman.CodecAuto {
+		if _, ok := huffman.CodecByName(opts.Codec); !ok {
+			return opts, errUnknownMethod
 		}
+	}
 
-		// Remove .huff extension for output file
-		outputName := strings.TrimSuffix(fileName, ".huff")
-
-		// Decompress file with timing using goroutine and channel
-		start := time.Now()
-		resultCh := make(chan error)
-		go func(inputPath, outputPath string, result chan<- error) {
-			result <- huffman.Decompress("process/"+fileName, "process/"+outputName)
-		}("process/"+fileName, "process/"+outputName, resultCh)
-
-		select {
-		case err := <-resultCh:
-			if err != nil {
-				http.Error(w, "Error decompressing file", http.StatusInternalServerError)
-				return
-			}
-			duration := time.Since(start)
-			log.Printf("Decompression of %s took %v", fileName, duration)
-			w.Write([]byte(outputName))
-		case <-time.After(60 * time.Second):
-			http.Error(w, "Decompression timed out", http.StatusGatewayTimeout)
-			return
+	// Optional Reed-Solomon parity shards per group of blocks
+	if parity := r.FormValue("parity"); parity != "" {
+		n, err := strconv.Atoi(parity)
+		if err != nil || n < 0 || n > huffman.MaxParity {
+			return opts, errInvalidParity
 		}
-		return
+		opts.Parity = n
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	// Optional minimum-variance trees: same size, more even code lengths
+	opts.MinVariance = r.FormValue("minVariance") == "true"
+
+	// Optional password: blocks are encrypted after compression
+	opts.Password = r.FormValue("password")
+
+	// Sign the output when the server has a signing key
+	opts.SigningKey = config.SigningKey
+	return opts, nil
 }
 
-func saveFile(file multipart.File, fileName string) error {
-	outFile, err := os.Create("process/" + fileName)
+// decompressOptions reads the decompression settings shared by /decompress and /jobs.
+func decompressOptions(r *http.Request) (huffman.Options, error) {
+	// Optional public key the file must be signed with
+	verifyKey, err := publicKeyFromForm(r)
 	if err != nil {
-		return err
+		return huffman.Options{}, errPublicKey
 	}
-	defer outFile.Close()
 
-	// Copy the uploaded file to the new file
-	if _, err := io.Copy(outFile, file); err != nil {
-		return err
-	}
-	return nil
+	// Optionally drop corrupt blocks instead of failing. The limits stop
+	// decompression bombs before they fill the disk.
+	return huffman.Options{
+		SkipCorrupt: r.FormValue("skipCorrupt") == "true",
+		Password:    r.FormValue("password"),
+		VerifyKey:   verifyKey,
+		MaxOutput:   config.maxDecompressedSize(),
+		MaxRatio:    config.maxRatio(),
+	}, nil
+}
+
+// operationResult tells the client how to fetch the output of /compress or /decompress.
+type operationResult struct {
+	ID       string `json:"id"`
+	FileName string `json:"fileName"`
+	Download string `json:"download"`
 }
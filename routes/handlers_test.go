@@ -1,11 +1,16 @@
 package routes
 
 import (
+	"Compression_Upc/huffman"
+	"Compression_Upc/storage"
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
@@ -26,6 +31,12 @@ func createTestFile(t *testing.T, name, content string) {
 }
 
 func TestCompressHandler(t *testing.T) {
+	compressTestFile(t)
+}
+
+// compressTestFile compresses test.txt through the handler and returns the result
+func compressTestFile(t *testing.T) []byte {
+	t.Helper()
 	// Create test file with more substantial content for better analysis
 	content := strings.Repeat("hello world ", 10000)
 	createTestFile(t, "test.txt", content)
@@ -69,11 +80,8 @@ func TestCompressHandler(t *testing.T) {
 	executionTime := time.Since(start)
 
 	// Get compressed file size
-	compressedFileInfo, err := os.Stat("process/test.txt.huff")
-	if err != nil {
-		t.Fatalf("Failed to get compressed file info: %v", err)
-	}
-	compressedSize := compressedFileInfo.Size()
+	compressed := resultData(t, rr)
+	compressedSize := int64(len(compressed))
 
 	// Calculate and print metrics
 	compressionRatio := calculateCompressionRatio(originalSize, compressedSize)
@@ -90,28 +98,23 @@ func TestCompressHandler(t *testing.T) {
 	if !strings.Contains(rr.Body.String(), ".huff") {
 		t.Errorf("Expected .huff in response, got %s", rr.Body.String())
 	}
+	return compressed
 }
 
 func TestDecompressHandler(t *testing.T) {
 	// First, compress a file so we have a .huff file to decompress
-	TestCompressHandler(t)
+	compressed := compressTestFile(t)
 
 	// Start timing
 	start := time.Now()
 
-	file, err := os.Open("process/test.txt.huff")
-	if err != nil {
-		t.Fatalf("Failed to open compressed file: %v", err)
-	}
-	defer file.Close()
-
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 	part, err := writer.CreateFormFile("file", "test.txt.huff")
 	if err != nil {
 		t.Fatalf("Failed to create form file: %v", err)
 	}
-	_, err = io.Copy(part, file)
+	_, err = part.Write(compressed)
 	if err != nil {
 		t.Fatalf("Failed to copy file: %v", err)
 	}
@@ -128,11 +131,7 @@ func TestDecompressHandler(t *testing.T) {
 	executionTime := time.Since(start)
 
 	// Get decompressed file size
-	decompressedFileInfo, err := os.Stat("process/test.txt")
-	if err != nil {
-		t.Fatalf("Failed to get decompressed file info: %v", err)
-	}
-	decompressedSize := decompressedFileInfo.Size()
+	decompressedSize := len(resultData(t, rr))
 
 	t.Logf("\nDecompression Analysis:")
 	t.Logf("Decompressed Size: %d bytes", decompressedSize)
@@ -145,3 +144,432 @@ func TestDecompressHandler(t *testing.T) {
 		t.Errorf("Expected test.txt in response, got %s", rr.Body.String())
 	}
 }
+
+// resultData returns the file produced by a /compress or /decompress response
+func resultData(t *testing.T, rr *httptest.ResponseRecorder) []byte {
+	t.Helper()
+	var result operationResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", rr.Body.String(), err)
+	}
+	return storedResult(t, result.ID)
+}
+
+// storedResult reads a result from the result store
+func storedResult(t *testing.T, id string) []byte {
+	t.Helper()
+	rc, _, err := resultStore.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("No result %q: %v", id, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Failed to read result %q: %v", id, err)
+	}
+	return data
+}
+
+// newUploadRequest builds a multipart request with the given file and extra form fields
+func newUploadRequest(t *testing.T, target, fileName string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("Failed to write form file: %v", err)
+	}
+	writer.WriteField("fileName", fileName)
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// newFormRequest builds a urlencoded POST request with fields.
+func newFormRequest(target string, fields url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(fields.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestCompressHandlerMethods(t *testing.T) {
+	content := []byte(strings.Repeat("aaaaaaaabbbbcc", 2000))
+
+	for _, method := range []string{"auto", "rle-huffman", "stored"} {
+		rr := httptest.NewRecorder()
+		compressHandler(rr, newUploadRequest(t, "/compress", "methods.txt", content, map[string]string{"method": method}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Method %s: expected status 200, got %d", method, rr.Code)
+		}
+		t.Logf("Method %s: %d -> %d bytes", method, len(content), len(resultData(t, rr)))
+	}
+
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "methods.txt", content, map[string]string{"method": "lzma"}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown method, got %d", rr.Code)
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	var compressed bytes.Buffer
+	zw, err := huffman.NewWriter(&compressed, huffman.Options{BlockSize: 1024})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	zw.Write([]byte(strings.Repeat("verify me please ", 500)))
+	zw.Close()
+
+	data := compressed.Bytes()
+	// Flip a bit near the end, inside the last block's payload
+	data[len(data)-10] ^= 0x10
+
+	rr := httptest.NewRecorder()
+	verifyHandler(rr, newUploadRequest(t, "/verify", "corrupt.huff", data, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var report huffman.VerifyReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.CorruptBlocks) != 1 || report.CorruptBlocks[0].Index != report.Blocks-1 {
+		t.Errorf("Expected the last block to be reported corrupt, got %+v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	verifyHandler(rr, newUploadRequest(t, "/verify", "plain.txt", []byte("not compressed"), nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-container file, got %d", rr.Code)
+	}
+}
+
+func TestInspectHandler(t *testing.T) {
+	content := []byte(strings.Repeat("inspect me please ", 500))
+	var compressed bytes.Buffer
+	zw, err := huffman.NewWriter(&compressed, huffman.Options{BlockSize: 1024, Password: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	zw.Write(content)
+	zw.Close()
+
+	rr := httptest.NewRecorder()
+	inspectHandler(rr, newUploadRequest(t, "/inspect", "data.huff", compressed.Bytes(), map[string]string{"password": "secret"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report huffman.InspectReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if !report.Encrypted || report.OriginalSize != int64(len(content)) || report.CompressedSize != int64(compressed.Len()) || len(report.CodeTable) == 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// Without the password only the structure is shown
+	rr = httptest.NewRecorder()
+	inspectHandler(rr, newUploadRequest(t, "/inspect", "data.huff", compressed.Bytes(), nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"codeTable":[]`) {
+		t.Errorf("Expected a report without code table, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for name, c := range map[string]struct {
+		data     []byte
+		password string
+		want     int
+	}{
+		"wrong password": {compressed.Bytes(), "wrong", http.StatusUnauthorized},
+		"not compressed": {[]byte("not compressed"), "", http.StatusBadRequest},
+	} {
+		rr = httptest.NewRecorder()
+		inspectHandler(rr, newUploadRequest(t, "/inspect", "data.huff", c.data, map[string]string{"password": c.password}))
+		if rr.Code != c.want {
+			t.Errorf("%s: expected status %d, got %d", name, c.want, rr.Code)
+		}
+	}
+}
+
+func TestDecompressHandlerPassword(t *testing.T) {
+	content := []byte(strings.Repeat("private document ", 1000))
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "private.txt", content, map[string]string{"password": "correct horse"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	compressed := resultData(t, rr)
+	if bytes.Contains(compressed, []byte("private document")) {
+		t.Fatal("Compressed file is not encrypted")
+	}
+
+	for password, want := range map[string]int{"": http.StatusUnauthorized, "battery staple": http.StatusUnauthorized} {
+		rr = httptest.NewRecorder()
+		decompressHandler(rr, newUploadRequest(t, "/decompress", "private.txt.huff", compressed, map[string]string{"password": password}))
+		if rr.Code != want {
+			t.Errorf("Password %q: expected status %d, got %d", password, want, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	decompressHandler(rr, newUploadRequest(t, "/decompress", "private.txt.huff", compressed, map[string]string{"password": "correct horse"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	decompressed := resultData(t, rr)
+	if !bytes.Equal(decompressed, content) {
+		t.Error("Decompressed data does not match original data")
+	}
+}
+
+func TestSignedCompression(t *testing.T) {
+	pub, priv, err := huffman.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	Configure(Config{SigningKey: priv})
+	t.Cleanup(func() { Configure(Config{}) })
+
+	content := []byte(strings.Repeat("published dataset ", 1000))
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "dataset.txt", content, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	compressed := resultData(t, rr)
+	pubPEM, err := huffman.MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	fields := map[string]string{"publicKey": string(pubPEM)}
+
+	rr = httptest.NewRecorder()
+	decompressHandler(rr, newUploadRequest(t, "/decompress", "dataset.txt.huff", compressed, fields))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	verifyHandler(rr, newUploadRequest(t, "/verify", "dataset.txt.huff", compressed, fields))
+	var report huffman.VerifyReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if !report.OK() || !report.Signed {
+		t.Errorf("Expected a valid signature, got %+v", report)
+	}
+
+	// A different key must be rejected
+	otherPub, _, _ := huffman.GenerateKey()
+	otherPEM, _ := huffman.MarshalPublicKey(otherPub)
+	rr = httptest.NewRecorder()
+	decompressHandler(rr, newUploadRequest(t, "/decompress", "dataset.txt.huff", compressed, map[string]string{"publicKey": string(otherPEM)}))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for another signer, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	decompressHandler(rr, newUploadRequest(t, "/decompress", "dataset.txt.huff", compressed, map[string]string{"publicKey": "garbage"}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid key, got %d", rr.Code)
+	}
+}
+
+func TestCompressHandlerTimeout(t *testing.T) {
+	timeout := requestTimeout
+	requestTimeout = time.Nanosecond
+	t.Cleanup(func() { requestTimeout = timeout })
+
+	content := []byte(strings.Repeat("too slow ", 10000))
+	entries, _ := os.ReadDir(processDir)
+	before := len(entries)
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "slow.txt", content, nil))
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status 504, got %d", rr.Code)
+	}
+	if entries, _ := os.ReadDir(processDir); len(entries) != before {
+		t.Errorf("Expected the workspace to be removed, found %d entries instead of %d", len(entries), before)
+	}
+}
+
+func TestDownloadByID(t *testing.T) {
+	content := []byte(strings.Repeat("report ", 1000))
+
+	// Two uploads with the same name get separate workspaces
+	var results [2]operationResult
+	for i := range results {
+		rr := httptest.NewRecorder()
+		compressHandler(rr, newUploadRequest(t, "/compress", "report.txt", append(content, byte(i)), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		json.Unmarshal(rr.Body.Bytes(), &results[i])
+	}
+	if results[0].ID == results[1].ID || len(results[0].ID) != 32 {
+		t.Fatalf("Expected distinct random IDs, got %q and %q", results[0].ID, results[1].ID)
+	}
+
+	for _, target := range []string{"/download?file=report.txt.huff", "/download?id=../process", "/download?id=00000000000000000000000000000000"} {
+		rr := httptest.NewRecorder()
+		downloadHandler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", target, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	downloadHandler(rr, httptest.NewRequest(http.MethodGet, results[1].Download, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Header().Get("Content-Disposition"), "report.txt.huff") {
+		t.Errorf("Unexpected Content-Disposition %q", rr.Header().Get("Content-Disposition"))
+	}
+	zr, err := huffman.NewReader(rr.Body, huffman.Options{})
+	if err != nil {
+		t.Fatalf("Downloaded file is not a container: %v", err)
+	}
+	decompressed, _ := io.ReadAll(zr)
+	if !bytes.Equal(decompressed, append(content, 1)) {
+		t.Error("Downloaded the wrong file")
+	}
+
+	// A result can be downloaded only once
+	rr = httptest.NewRecorder()
+	downloadHandler(rr, httptest.NewRequest(http.MethodGet, results[1].Download, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 on second download, got %d", rr.Code)
+	}
+}
+
+func TestResultExpires(t *testing.T) {
+	store := storage.NewMemory()
+	Configure(Config{Store: store, ResultTTL: time.Millisecond, JanitorInterval: 10 * time.Millisecond})
+	t.Cleanup(func() { Configure(Config{}) })
+
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "abandoned.txt", []byte("nobody downloads this"), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var result operationResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	time.Sleep(5 * time.Millisecond)
+	rr = httptest.NewRecorder()
+	downloadHandler(rr, httptest.NewRequest(http.MethodGet, result.Download, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an expired result, got %d", rr.Code)
+	}
+
+	// The janitor deletes it from the store
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		objects, _ := store.List(context.Background())
+		if len(objects) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expired result was not purged: %+v", objects)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// presigningStore hands out fake links to test the redirect.
+type presigningStore struct{ *storage.Memory }
+
+func (presigningStore) PresignGet(key, fileName string, ttl time.Duration) (string, error) {
+	return "https://bucket.example/" + key + "?name=" + fileName, nil
+}
+
+func TestDownloadPresigned(t *testing.T) {
+	Configure(Config{Store: presigningStore{storage.NewMemory()}})
+	t.Cleanup(func() { Configure(Config{}) })
+
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "big.txt", []byte("served from the bucket"), nil))
+	var result operationResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	rr = httptest.NewRecorder()
+	downloadHandler(rr, httptest.NewRequest(http.MethodGet, result.Download, nil))
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://bucket.example/"+result.ID+"?name=big.txt.huff" {
+		t.Errorf("Expected a redirect to the presigned link, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// Unknown results are not presigned
+	rr = httptest.NewRecorder()
+	downloadHandler(rr, httptest.NewRequest(http.MethodGet, "/download?id=00000000000000000000000000000000", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestUploadLimit(t *testing.T) {
+	Configure(Config{MaxUploadSize: 64 << 10})
+	t.Cleanup(func() { Configure(Config{}) })
+
+	content := bytes.Repeat([]byte("x"), 128<<10)
+	for _, c := range []struct {
+		target  string
+		handler http.HandlerFunc
+	}{
+		{"/compress", compressHandler},
+		{"/decompress", decompressHandler},
+		{"/verify", verifyHandler},
+		{"/compress/stream", compressStreamHandler},
+	} {
+		rr := httptest.NewRecorder()
+		c.handler(rr, newUploadRequest(t, c.target, "big.huff", content, nil))
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status 413, got %d", c.target, rr.Code)
+		}
+	}
+
+	// Uploads under the limit still work
+	rr := httptest.NewRecorder()
+	compressHandler(rr, newUploadRequest(t, "/compress", "small.txt", content[:32<<10], nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 under the limit, got %d", rr.Code)
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	// 4 MiB of zeros compress to a few KiB
+	bomb := zeroBomb(t)
+
+	for name, cfg := range map[string]Config{
+		"size":  {MaxDecompressedSize: 1 << 20},
+		"ratio": {MaxRatio: 50},
+	} {
+		Configure(cfg)
+		entries, _ := os.ReadDir(processDir)
+		before := len(entries)
+		rr := httptest.NewRecorder()
+		decompressHandler(rr, newUploadRequest(t, "/decompress", "bomb.bin.huff", bomb, nil))
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+		if entries, _ := os.ReadDir(processDir); len(entries) != before {
+			t.Errorf("%s: expected the workspace to be removed", name)
+		}
+	}
+	Configure(Config{})
+
+	// The default limits let it through
+	rr := httptest.NewRecorder()
+	decompressHandler(rr, newUploadRequest(t, "/decompress", "bomb.bin.huff", bomb, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the default limits, got %d", rr.Code)
+	}
+}
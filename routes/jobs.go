@@ -0,0 +1,177 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"Compression_Upc/jobs"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// jobsHandler enqueues a compress or decompress job and returns its ID
+// right away. Clients poll GET /jobs/{id}; the result of a finished job is
+// the ID to pass to /download.
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status, apiErr := enqueueJob(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+status.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// enqueueJob validates a job request, saves its upload in a workspace and
+// submits it to the queue.
+func enqueueJob(w http.ResponseWriter, r *http.Request) (jobs.Status, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return jobs.Status{}, apiErr
+	}
+	operation := r.FormValue("operation")
+	if operation != "compress" && operation != "decompress" {
+		return jobs.Status{}, newAPIError(http.StatusBadRequest, "invalid_operation", "Operation must be compress or decompress")
+	}
+	op, apiErr := parseOperation(w, r, operation)
+	if apiErr != nil {
+		return jobs.Status{}, apiErr
+	}
+	defer op.file.Close()
+
+	// Each job works in its own workspace; its ID is the job result
+	ws, apiErr := op.prepare()
+	if apiErr != nil {
+		return jobs.Status{}, apiErr
+	}
+	fn := func(ctx context.Context, progress func(jobs.Progress)) (string, error) {
+		defer ws.remove()
+		op.opts.Progress = jobProgress(ws.inputPath(op.fileName), progress)
+		if _, err := op.execute(ctx, ws); err != nil {
+			return "", err
+		}
+		return ws.id, nil
+	}
+
+	// A job canceled before it starts never runs fn, so the workspace is
+	// removed by the cleanup instead
+	status, err := jobQueue.SubmitWithCleanup(operation, fn, func() { ws.remove() })
+	if err != nil {
+		ws.remove()
+		if errors.Is(err, jobs.ErrQueueFull) {
+			return jobs.Status{}, newAPIError(http.StatusServiceUnavailable, "queue_full", "Job queue is full, try again later")
+		}
+		return jobs.Status{}, newAPIError(http.StatusInternalServerError, "internal", "Error creating job")
+	}
+	log.Printf("Queued %s job %s for %s", operation, status.ID, op.fileName)
+	return status, nil
+}
+
+// jobHandler reports the status of a job (GET) or cancels it (DELETE).
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status, apiErr := jobRequest(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// jobRequest reads (GET) or cancels (DELETE) the job named in the path.
+func jobRequest(r *http.Request) (jobs.Status, *apiError) {
+	var status jobs.Status
+	var err error
+	if r.Method == http.MethodDelete {
+		status, err = jobQueue.Cancel(r.PathValue("id"))
+	} else {
+		status, err = jobQueue.Get(r.PathValue("id"))
+	}
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		return status, errJobNotFound
+	case errors.Is(err, jobs.ErrFinished):
+		return status, newAPIError(http.StatusConflict, "job_finished", "Job already finished")
+	case err != nil:
+		return status, newAPIError(http.StatusInternalServerError, "internal", "Error reading job")
+	}
+	return status, nil
+}
+
+var errJobNotFound = newAPIError(http.StatusNotFound, "not_found", "Job not found")
+
+// jobEventsHandler streams the status of a job as Server-Sent Events until it
+// finishes or the client goes away. Every event is a "status" event whose
+// data is the same JSON returned by GET /jobs/{id}.
+func jobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	updates, unsubscribe, err := jobQueue.Subscribe(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errJobNotFound)
+		return
+	}
+	defer unsubscribe()
+	streamJobEvents(w, r, updates)
+}
+
+func streamJobEvents(w http.ResponseWriter, r *http.Request, updates <-chan jobs.Status) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+	for {
+		select {
+		case status, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(status)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// jobProgress forwards huffman progress to a job. Both compression and
+// decompression read inputPath from start to end, so the fraction done is
+// the share of it already consumed.
+func jobProgress(inputPath string, progress func(jobs.Progress)) func(huffman.Progress) {
+	var total int64
+	if info, err := os.Stat(inputPath); err == nil {
+		total = info.Size()
+	}
+	return func(p huffman.Progress) {
+		update := jobs.Progress{Phase: string(p.Phase), BytesRead: p.BytesRead, BytesWritten: p.BytesWritten}
+		if total > 0 {
+			update.Fraction = float64(p.BytesRead) / float64(total)
+		}
+		progress(update)
+	}
+}
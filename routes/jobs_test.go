@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"Compression_Upc/jobs"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pollJob requests GET /jobs/{id} until the job finishes.
+func pollJob(t *testing.T, mux http.Handler, id string) jobs.Status {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		var status jobs.Status
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		if status.Finished() {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return jobs.Status{}
+}
+
+func submitJob(t *testing.T, mux http.Handler, fileName string, content []byte, fields map[string]string) jobs.Status {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/jobs", fileName, content, fields))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var status jobs.Status
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if rr.Header().Get("Location") != "/jobs/"+status.ID {
+		t.Errorf("Unexpected Location header %q", rr.Header().Get("Location"))
+	}
+	return status
+}
+
+func TestJobsCompressDecompress(t *testing.T) {
+	mux := MuxRoutes()
+	content := []byte(strings.Repeat("background job ", 5000))
+
+	status := pollJob(t, mux, submitJob(t, mux, "job.txt", content, map[string]string{"operation": "compress", "parity": "1"}).ID)
+	if status.State != jobs.Done || status.Progress != 1 {
+		t.Fatalf("Unexpected compress job status: %+v", status)
+	}
+	compressed := storedResult(t, status.Result)
+
+	status = pollJob(t, mux, submitJob(t, mux, "job.txt.huff", compressed, map[string]string{"operation": "decompress"}).ID)
+	if status.State != jobs.Done {
+		t.Fatalf("Unexpected decompress job status: %+v", status)
+	}
+	decompressed := storedResult(t, status.Result)
+	if !bytes.Equal(decompressed, content) {
+		t.Error("Decompressed data does not match original data")
+	}
+
+	// A failing job reports its error instead of hanging the request
+	status = pollJob(t, mux, submitJob(t, mux, "bad.huff", []byte("not compressed"), map[string]string{"operation": "decompress"}).ID)
+	if status.State != jobs.Failed || status.Error == "" {
+		t.Errorf("Expected failed job, got %+v", status)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, newUploadRequest(t, "/jobs", "job.txt", content, map[string]string{"operation": "zip"}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown operation, got %d", rr.Code)
+	}
+}
+
+func TestJobsCancel(t *testing.T) {
+	mux := MuxRoutes()
+	started := make(chan struct{})
+	status, err := jobQueue.Submit("compress", func(ctx context.Context, progress func(jobs.Progress)) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/jobs/"+status.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if status := pollJob(t, mux, status.ID); status.State != jobs.Canceled {
+		t.Errorf("Expected canceled job, got %+v", status)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/jobs/"+status.ID, nil))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a finished job, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestJobsCancelQueuedRemovesWorkspace(t *testing.T) {
+	Configure(Config{Workers: 1})
+	t.Cleanup(func() { Configure(Config{}) })
+	mux := MuxRoutes()
+
+	// Keep the only worker busy so the next job stays queued
+	started, release := make(chan struct{}), make(chan struct{})
+	if _, err := jobQueue.Submit("compress", func(ctx context.Context, progress func(jobs.Progress)) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	defer close(release)
+	<-started
+
+	entries, _ := os.ReadDir(processDir)
+	before := len(entries)
+	status := submitJob(t, mux, "queued.txt", []byte("waiting for a worker"), map[string]string{"operation": "compress"})
+	if entries, _ := os.ReadDir(processDir); len(entries) != before+1 {
+		t.Fatalf("Expected the queued job to have a workspace, found %d entries instead of %d", len(entries), before+1)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/jobs/"+status.ID, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"canceled"`) {
+		t.Fatalf("Expected the queued job to be canceled, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if entries, _ := os.ReadDir(processDir); len(entries) != before {
+		t.Errorf("Expected the workspace to be removed, found %d entries instead of %d", len(entries), before)
+	}
+}
+
+func TestJobEvents(t *testing.T) {
+	server := httptest.NewServer(MuxRoutes())
+	defer server.Close()
+
+	release := make(chan struct{})
+	status, err := jobQueue.Submit("compress", func(ctx context.Context, progress func(jobs.Progress)) (string, error) {
+		<-release
+		progress(jobs.Progress{Fraction: 0.5, Phase: "encoding", BytesRead: 100, BytesWritten: 40})
+		return "events.txt.huff", nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/jobs/" + status.ID + "/events")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type %q", ct)
+	}
+	close(release)
+
+	// The stream ends when the job finishes
+	var events []jobs.Status
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event jobs.Status
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Failed to decode event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		t.Fatal("No events received")
+	}
+	last := events[len(events)-1]
+	if last.State != jobs.Done || last.Result != "events.txt.huff" || last.BytesWritten != 40 {
+		t.Errorf("Unexpected final event: %+v", last)
+	}
+
+	resp, err = http.Get(server.URL + "/jobs/unknown/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+}
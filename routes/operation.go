@@ -0,0 +1,289 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiError is an error reported to the client. Code is a stable identifier
+// for programs; Message is meant for people. The plain endpoints send only
+// the message, /api/v1 sends both as JSON.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+// errClientGone reports an operation stopped because the client
+// disconnected; there is nobody left to answer.
+var errClientGone = &apiError{Code: "canceled", Message: "Client disconnected"}
+
+// writeError answers a plain endpoint with the message of err.
+func writeError(w http.ResponseWriter, err *apiError) {
+	if err == errClientGone {
+		return
+	}
+	http.Error(w, err.Message, err.Status)
+}
+
+// Option errors, shown to the client as is
+var (
+	errUnknownMethod = errors.New("Unknown compression method")
+	errInvalidParity = errors.New("Invalid parity")
+	errPublicKey     = errors.New("Invalid public key")
+)
+
+func optionError(err error) *apiError {
+	code := "invalid_request"
+	switch {
+	case errors.Is(err, errUnknownMethod):
+		code = "unknown_method"
+	case errors.Is(err, errInvalidParity):
+		code = "invalid_parity"
+	case errors.Is(err, errPublicKey):
+		code = "invalid_public_key"
+	}
+	return newAPIError(http.StatusBadRequest, code, err.Error())
+}
+
+// operationRequest is a validated compress or decompress upload.
+type operationRequest struct {
+	operation  string // "compress" or "decompress"
+	file       multipart.File
+	fileName   string
+	outputName string
+	opts       huffman.Options
+}
+
+// multipartMemory is how much of a multipart form is kept in memory; the
+// rest goes to temporary files.
+const multipartMemory = 32 << 20
+
+// readUploadForm parses the multipart form of r, refusing bodies larger than
+// config.MaxUploadSize.
+func readUploadForm(w http.ResponseWriter, r *http.Request) *apiError {
+	r.Body = http.MaxBytesReader(w, r.Body, config.maxUploadSize())
+	err := r.ParseMultipartForm(multipartMemory)
+	if tooLarge := uploadTooLarge(err); tooLarge != nil {
+		return tooLarge
+	}
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	return nil
+}
+
+// readForm is readUploadForm for the endpoints that can take all their input
+// as text fields, which also accept a urlencoded body.
+func readForm(w http.ResponseWriter, r *http.Request) *apiError {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		return readUploadForm(w, r)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, config.maxUploadSize())
+	err := r.ParseForm()
+	if tooLarge := uploadTooLarge(err); tooLarge != nil {
+		return tooLarge
+	}
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid_request", "Error reading form")
+	}
+	return nil
+}
+
+// uploadTooLarge returns the answer for err if it comes from the upload limit.
+func uploadTooLarge(err error) *apiError {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return nil
+	}
+	return newAPIError(http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("Upload exceeds the limit of %d bytes", maxErr.Limit))
+}
+
+// parseOperation reads the upload and settings shared by /compress,
+// /decompress, /jobs and their /api/v1 versions. On success the caller
+// closes op.file.
+func parseOperation(w http.ResponseWriter, r *http.Request, operation string) (*operationRequest, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	op := &operationRequest{operation: operation, file: file}
+	fail := func(e *apiError) (*operationRequest, *apiError) {
+		file.Close()
+		return nil, e
+	}
+
+	// Sanitize filename to prevent path traversal
+	op.fileName = filepath.Base(strings.TrimSpace(r.FormValue("fileName")))
+	if op.fileName == "." || op.fileName == "/" {
+		return fail(newAPIError(http.StatusBadRequest, "file_name_required", "File name is required"))
+	}
+
+	if operation == "compress" {
+		op.outputName = op.fileName + ".huff"
+		op.opts, err = compressOptions(r)
+	} else {
+		if !strings.HasSuffix(op.fileName, ".huff") {
+			return fail(newAPIError(http.StatusBadRequest, "invalid_extension", "File must have .huff extension"))
+		}
+		op.outputName = strings.TrimSuffix(op.fileName, ".huff")
+		op.opts, err = decompressOptions(r)
+	}
+	if err != nil {
+		return fail(optionError(err))
+	}
+	return op, nil
+}
+
+// prepare saves the upload in a new workspace. The caller removes it.
+func (op *operationRequest) prepare() (*workspace, *apiError) {
+	ws, err := newWorkspace()
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error creating workspace")
+	}
+	if err := ws.save(op.file, op.fileName); err != nil {
+		ws.remove()
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error saving file")
+	}
+	return ws, nil
+}
+
+// operationOutcome describes a finished operation whose output is in the
+// result store under ID.
+type operationOutcome struct {
+	ID           string
+	InputSize    int64
+	OutputSize   int64
+	InputSHA256  string
+	OutputSHA256 string
+	Duration     time.Duration
+	ExpiresAt    time.Time
+}
+
+// execute runs the operation in ws and moves the output to the result store.
+// Errors come straight from the huffman package, see operationError.
+func (op *operationRequest) execute(ctx context.Context, ws *workspace) (*operationOutcome, error) {
+	run := huffman.CompressContext
+	if op.operation == "decompress" {
+		run = huffman.DecompressContext
+	}
+	inputPath, outputPath := ws.inputPath(op.fileName), ws.outputPath(op.outputName)
+	start := time.Now()
+	if err := run(ctx, inputPath, outputPath, op.opts); err != nil {
+		return nil, err
+	}
+	out := &operationOutcome{ID: ws.id, Duration: time.Since(start)}
+
+	var err error
+	if out.InputSize, out.InputSHA256, err = fileSHA256(inputPath); err != nil {
+		return nil, err
+	}
+	if out.OutputSize, out.OutputSHA256, err = fileSHA256(outputPath); err != nil {
+		return nil, err
+	}
+	meta, err := ws.keep(ctx, op.outputName)
+	if err != nil {
+		return nil, err
+	}
+	out.ExpiresAt = meta.ExpiresAt
+	return out, nil
+}
+
+// run executes the operation in a workspace of its own, bounded by
+// requestTimeout. The request context is canceled when the client
+// disconnects, which stops the work at the next block.
+func (op *operationRequest) run(r *http.Request) (*operationOutcome, *apiError) {
+	ws, apiErr := op.prepare()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	defer ws.remove()
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	out, err := op.execute(ctx, ws)
+	if err != nil {
+		return nil, operationError(op.operation, op.fileName, err)
+	}
+	log.Printf("%s of %s (method %q) took %v", operationNoun(op.operation), op.fileName, op.opts.Codec, out.Duration)
+	return out, nil
+}
+
+func operationNoun(operation string) string {
+	if operation == "decompress" {
+		return "Decompression"
+	}
+	return "Compression"
+}
+
+// operationError turns an error from the huffman package into the answer
+// for the client. The huffman package has already removed the partial output.
+func operationError(operation, fileName string, err error) *apiError {
+	noun := operationNoun(operation)
+	var blockErr *huffman.BlockError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("%s of %s timed out", noun, fileName)
+		return newAPIError(http.StatusGatewayTimeout, "timeout", noun+" timed out")
+	case errors.Is(err, context.Canceled):
+		log.Printf("%s of %s canceled: client disconnected", noun, fileName)
+		return errClientGone
+	case errors.Is(err, huffman.ErrPasswordRequired):
+		return newAPIError(http.StatusUnauthorized, "password_required", "Password required")
+	case errors.Is(err, huffman.ErrWrongPassword):
+		return newAPIError(http.StatusUnauthorized, "wrong_password", "Wrong password")
+	case errors.Is(err, huffman.ErrNotSigned):
+		return newAPIError(http.StatusUnprocessableEntity, "not_signed", "File is not signed")
+	case errors.Is(err, huffman.ErrSignature):
+		log.Printf("%s of %s failed: %v", noun, fileName, err)
+		return newAPIError(http.StatusUnprocessableEntity, "bad_signature", "Signature verification failed, the file was modified or signed by another key")
+	case errors.Is(err, huffman.ErrLimitExceeded):
+		log.Printf("%s of %s stopped: %v", noun, fileName, err)
+		return newAPIError(http.StatusUnprocessableEntity, "limit_exceeded", "Decompressed output exceeds the server limits")
+	case errors.As(err, &blockErr):
+		log.Printf("%s of %s failed: %v", noun, fileName, err)
+		return newAPIError(http.StatusUnprocessableEntity, "corrupt_file", "Compressed file is corrupt, use /verify for details")
+	}
+	log.Printf("%s of %s failed: %v", noun, fileName, err)
+	if operation == "decompress" {
+		return newAPIError(http.StatusInternalServerError, "internal", "Error decompressing file")
+	}
+	return newAPIError(http.StatusInternalServerError, "internal", "Error compressing file")
+}
+
+// fileSHA256 returns the size and hex SHA-256 of the file at path.
+func fileSHA256(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,209 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxFieldSize bounds the form fields other than the file read by
+// /compress/stream, which are kept in memory.
+const maxFieldSize = 64 << 10
+
+// compressStreamHandler compresses the upload and sends the result in the
+// same response, with chunked encoding. The upload is read once into a spool
+// (memory up to config.SpoolMemory, then a temporary file) and the output is
+// never stored: there is no workspace and nothing to download afterwards.
+func compressStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	src, partName, err := spoolUpload(w, r)
+	if tooLarge := uploadTooLarge(err); tooLarge != nil {
+		writeError(w, tooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer src.Close()
+
+	fileName := strings.TrimSpace(r.FormValue("fileName"))
+	if fileName == "" {
+		fileName = partName
+	}
+	fileName = filepath.Base(fileName)
+	if fileName == "" || fileName == "." || fileName == "/" {
+		http.Error(w, "File name is required", http.StatusBadRequest)
+		return
+	}
+	opts, err := compressOptions(r)
+	if err != nil {
+		writeError(w, optionError(err))
+		return
+	}
+	input, err := src.reader()
+	if err != nil {
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+
+	outputName := fileName + ".huff"
+	w.Header().Set("Content-Disposition", "attachment; filename="+sanitizeFilename(outputName))
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	start := time.Now()
+	out := &responseCounter{w: w}
+	zw, err := huffman.NewWriterContext(ctx, out, opts)
+	if err == nil {
+		_, err = io.Copy(zw, input)
+		if err == nil {
+			err = zw.Close()
+		}
+	}
+	if err != nil {
+		// Until the first byte is sent the client can still get a status
+		if out.n == 0 {
+			w.Header().Del("Content-Disposition")
+			writeError(w, operationError("compress", fileName, err))
+			return
+		}
+		// Otherwise break the chunked stream so the output is seen as truncated
+		log.Printf("Streaming compression of %s failed after %d bytes: %v", fileName, out.n, err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("Streaming compression of %s (method %q) took %v", fileName, opts.Codec, time.Since(start))
+}
+
+// spoolUpload reads the multipart body of r, spooling the "file" part and
+// storing the other fields in r.Form so that r.FormValue works as usual.
+// A body larger than config.MaxUploadSize fails with *http.MaxBytesError.
+func spoolUpload(w http.ResponseWriter, r *http.Request) (*spool, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, config.maxUploadSize())
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", errors.New("Expected a multipart form")
+	}
+	form := r.URL.Query()
+	var (
+		src      *spool
+		partName string
+	)
+	fail := func(err error, cause error) (*spool, string, error) {
+		if src != nil {
+			src.Close()
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(cause, &maxErr) {
+			return nil, "", maxErr
+		}
+		return nil, "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(errors.New("Error reading form"), err)
+		}
+		if part.FormName() == "file" {
+			if src != nil {
+				return fail(errors.New("Only one file can be compressed"), nil)
+			}
+			src = newSpool(config.spoolMemory())
+			partName = part.FileName()
+			if _, err := io.Copy(src, part); err != nil {
+				return fail(errors.New("Error reading file"), err)
+			}
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+		if err != nil || len(value) > maxFieldSize {
+			return fail(errors.New("Form field too large"), err)
+		}
+		form.Add(part.FormName(), string(value))
+	}
+	if src == nil {
+		return nil, "", errors.New("Error reading file")
+	}
+	r.Form = form
+	r.PostForm = form
+	return src, partName, nil
+}
+
+// spool buffers data in memory up to limit bytes and in a temporary file
+// beyond that.
+type spool struct {
+	limit int64
+	buf   bytes.Buffer
+	file  *os.File
+}
+
+func newSpool(limit int64) *spool {
+	return &spool{limit: limit}
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && int64(s.buf.Len()+len(p)) > s.limit {
+		f, err := os.CreateTemp("", "huff-spool-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := f.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf = bytes.Buffer{}
+	}
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// reader returns the spooled data from the start.
+func (s *spool) reader() (io.Reader, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// Close removes the temporary file, if any.
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// responseCounter counts the bytes written to the response.
+type responseCounter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *responseCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
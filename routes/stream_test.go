@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressStream(t *testing.T) {
+	srv := httptest.NewServer(MuxRoutes())
+	defer srv.Close()
+	content := []byte(strings.Repeat("streamed straight to the client ", 20000))
+
+	before, _ := os.ReadDir(processDir)
+	req := newUploadRequest(t, "/compress/stream", "stream.txt", content, map[string]string{"method": "rle-huffman"})
+	req.RequestURI = ""
+	req.URL.Scheme, req.URL.Host = "http", strings.TrimPrefix(srv.URL, "http://")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Expected a chunked response, got %v", resp.TransferEncoding)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "stream.txt.huff") {
+		t.Errorf("Unexpected Content-Disposition %q", cd)
+	}
+
+	zr, err := huffman.NewReader(resp.Body, huffman.Options{})
+	if err != nil {
+		t.Fatalf("Response is not a container: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil || !bytes.Equal(decompressed, content) {
+		t.Fatalf("Round trip failed: %v", err)
+	}
+
+	// No workspace was created
+	after, _ := os.ReadDir(processDir)
+	if len(after) != len(before) {
+		t.Errorf("Expected no workspace, found %d entries instead of %d", len(after), len(before))
+	}
+}
+
+func TestCompressStreamErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		req  *http.Request
+		code int
+	}{
+		{"GET", httptest.NewRequest(http.MethodGet, "/compress/stream", nil), http.StatusMethodNotAllowed},
+		{"not multipart", httptest.NewRequest(http.MethodPost, "/compress/stream", strings.NewReader("raw")), http.StatusBadRequest},
+		{"bad method", newUploadRequest(t, "/compress/stream", "a.txt", []byte("data"), map[string]string{"method": "nope"}), http.StatusBadRequest},
+		{"big field", newUploadRequest(t, "/compress/stream", "a.txt", []byte("data"), map[string]string{"password": strings.Repeat("x", maxFieldSize+1)}), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		compressStreamHandler(rr, c.req)
+		if rr.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.code, rr.Code)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	s := newSpool(16)
+	s.Write([]byte("0123456789"))
+	if s.file != nil {
+		t.Fatal("Expected the spool to stay in memory under the limit")
+	}
+	s.Write([]byte("abcdefghij"))
+	if s.file == nil {
+		t.Fatal("Expected the spool to spill to a file over the limit")
+	}
+	r, err := s.reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	if string(data) != "0123456789abcdefghij" {
+		t.Errorf("Unexpected spooled data %q", data)
+	}
+	name := s.file.Name()
+	s.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// huffmanTable is a frequency or code table that can be written as csv or json.
+type huffmanTable interface {
+	Write(w io.Writer, format string) error
+}
+
+// tableExport holds both tables of an upload.
+type tableExport struct {
+	Frequencies *huffman.FrequencyTable `json:"frequencies"`
+	Codes       *huffman.CodeTable      `json:"codes"`
+}
+
+// tableExportHandler answers with the frequency table of the upload or, with
+// table=codes, its code table, as json or, with format=csv, as a csv download.
+func tableExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	export, apiErr := exportTable(w, r)
+	if apiErr == nil {
+		apiErr = checkTableFormat(r)
+	}
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	switch r.FormValue("table") {
+	case "", "frequencies":
+		writeTable(w, r, export.Frequencies, "frequencies")
+	case "codes":
+		writeTable(w, r, export.Codes, "codes")
+	default:
+		writeError(w, newAPIError(http.StatusBadRequest, "invalid_request", "table must be frequencies or codes"))
+	}
+}
+
+// tableImportHandler answers with the codes built from a supplied table.
+func tableImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	codes, apiErr := importTable(w, r)
+	if apiErr == nil {
+		apiErr = checkTableFormat(r)
+	}
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeTable(w, r, codes, "codes")
+}
+
+// exportTable counts the symbols of the "text" field or the uploaded "file"
+// and builds their codes, from a minimum-variance tree with minVariance=true.
+func exportTable(w http.ResponseWriter, r *http.Request) (*tableExport, *apiError) {
+	if apiErr := readForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	data, apiErr := textOrFile(r, "text")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	frequencies := huffman.Frequencies(data)
+	codes := frequencies.Codes(huffman.Options{MinVariance: r.FormValue("minVariance") == "true"})
+	return &tableExport{Frequencies: frequencies, Codes: codes}, nil
+}
+
+// importTable builds the codes of the frequency or probability table in the
+// "table" field or the uploaded "file", in csv or json.
+func importTable(w http.ResponseWriter, r *http.Request) (*huffman.CodeTable, *apiError) {
+	if apiErr := readForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	data, apiErr := textOrFile(r, "table")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	table, err := huffman.ReadFrequencyTable(bytes.NewReader(data), "")
+	if errors.Is(err, huffman.ErrFrequencyTable) {
+		return nil, newAPIError(http.StatusBadRequest, "invalid_table", err.Error())
+	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error reading table")
+	}
+	return table.Codes(huffman.Options{MinVariance: r.FormValue("minVariance") == "true"}), nil
+}
+
+// checkTableFormat validates the optional "format" field of the response of
+// the plain endpoints. The /api/v1 versions always answer with json.
+func checkTableFormat(r *http.Request) *apiError {
+	if format := r.FormValue("format"); format != "" && !slices.Contains(huffman.TableFormats, format) {
+		return newAPIError(http.StatusBadRequest, "invalid_request", "format must be csv or json")
+	}
+	return nil
+}
+
+// writeTable writes table as json or, with format=csv, as name.csv.
+func writeTable(w http.ResponseWriter, r *http.Request, table huffmanTable, name string) {
+	if r.FormValue("format") != "csv" {
+		writeJSON(w, http.StatusOK, table)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename="+name+".csv")
+	table.Write(w, "csv")
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTableExportHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	tableExportHandler(rr, newUploadRequest(t, "/tables/export", "", nil, map[string]string{"text": "abracadabra"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var frequencies huffman.FrequencyTable
+	if err := json.Unmarshal(rr.Body.Bytes(), &frequencies); err != nil || frequencies.Symbols != 11 || len(frequencies.Frequencies) != 5 {
+		t.Errorf("Unexpected frequency table %+v: %v", frequencies, err)
+	}
+
+	rr = httptest.NewRecorder()
+	tableExportHandler(rr, newUploadRequest(t, "/tables/export", "data.txt", []byte("aab"), map[string]string{"table": "codes", "format": "csv"}))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") || !strings.Contains(rr.Header().Get("Content-Disposition"), "codes.csv") {
+		t.Errorf("Unexpected headers %v", rr.Header())
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil || len(rows) != 3 || rows[1][0] != "97" || rows[1][3] != "1" || rows[2][3] != "0" {
+		t.Errorf("Unexpected code table %v: %v", rows, err)
+	}
+
+	rr = httptest.NewRecorder()
+	tableExportHandler(rr, newFormRequest("/tables/export", url.Values{"text": {"aab"}, "table": {"codes"}}))
+	var codes huffman.CodeTable
+	if err := json.Unmarshal(rr.Body.Bytes(), &codes); rr.Code != http.StatusOK || err != nil || codes.Symbols != 3 || len(codes.Codes) != 2 {
+		t.Errorf("Unexpected urlencoded export %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	tableExportHandler(rr, newFormRequest("/tables/export", url.Values{}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty urlencoded form, got %d", rr.Code)
+	}
+
+	for _, fields := range []map[string]string{{"text": "a", "table": "tree"}, {"text": "a", "format": "xml"}} {
+		rr = httptest.NewRecorder()
+		tableExportHandler(rr, newUploadRequest(t, "/tables/export", "", nil, fields))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected status 400, got %d", fields, rr.Code)
+		}
+	}
+}
+
+func TestTableImportHandler(t *testing.T) {
+	// An exported table builds the same codes as the text it came from
+	rr := httptest.NewRecorder()
+	tableExportHandler(rr, newUploadRequest(t, "/tables/export", "", nil, map[string]string{"text": "aaaabbccde", "format": "csv"}))
+	exported := rr.Body.Bytes()
+
+	rr = httptest.NewRecorder()
+	tableImportHandler(rr, newUploadRequest(t, "/tables/import", "table.csv", exported, map[string]string{"minVariance": "true"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var codes huffman.CodeTable
+	if err := json.Unmarshal(rr.Body.Bytes(), &codes); err != nil {
+		t.Fatal(err)
+	}
+	trace := huffman.TraceTree([]byte("aaaabbccde"), huffman.Options{MinVariance: true})
+	if !codes.MinVariance || codes.CodeLengthVariance != trace.CodeLengthVariance || len(codes.Codes) != len(trace.Codes) {
+		t.Errorf("Imported codes %+v differ from the trace %+v", codes, trace.Codes)
+	}
+
+	rr = httptest.NewRecorder()
+	tableImportHandler(rr, newUploadRequest(t, "/tables/import", "", nil, map[string]string{"table": `[{"symbol": 97, "probability": 0.7}]`}))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "add up") {
+		t.Errorf("Expected status 400 for probabilities that do not add up, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The tables can also be sent urlencoded
+	rr = httptest.NewRecorder()
+	tableImportHandler(rr, newFormRequest("/tables/import", url.Values{"table": {string(exported)}, "minVariance": {"true"}}))
+	var urlencoded huffman.CodeTable
+	if err := json.Unmarshal(rr.Body.Bytes(), &urlencoded); rr.Code != http.StatusOK || err != nil || urlencoded.CodeLengthVariance != codes.CodeLengthVariance {
+		t.Errorf("Unexpected urlencoded import %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	tableImportHandler(rr, httptest.NewRequest(http.MethodGet, "/tables/import", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// textDecoding is the answer of /decode-text.
+type textDecoding struct {
+	Text      string `json:"text"`
+	Symbols   int    `json:"symbols"`
+	TotalBits int    `json:"totalBits"`
+}
+
+func encodeTextHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	encoding, apiErr := encodeText(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, encoding)
+}
+
+func decodeTextHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	decoding, apiErr := decodeText(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, decoding)
+}
+
+// encodeText encodes the "text" field with its own Huffman tree, a
+// minimum-variance one with minVariance=true.
+func encodeText(w http.ResponseWriter, r *http.Request) (*huffman.TextEncoding, *apiError) {
+	if apiErr := readForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	text := r.FormValue("text")
+	if text == "" {
+		return nil, newAPIError(http.StatusBadRequest, "text_required", "A text is required")
+	}
+	return huffman.EncodeText([]byte(text), huffman.Options{MinVariance: r.FormValue("minVariance") == "true"}), nil
+}
+
+// decodeText decodes the "bits" field with the code table in the "codes"
+// field, as csv or json. Spaces between the bits are ignored.
+func decodeText(w http.ResponseWriter, r *http.Request) (*textDecoding, *apiError) {
+	if apiErr := readForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	table := r.FormValue("codes")
+	if strings.TrimSpace(table) == "" {
+		return nil, newAPIError(http.StatusBadRequest, "invalid_code_table", "A code table is required")
+	}
+	codes, err := huffman.ReadCodes(strings.NewReader(table), "")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "invalid_code_table", err.Error())
+	}
+	bits := r.FormValue("bits")
+	text, err := huffman.DecodeBits(bits, codes)
+	switch {
+	case errors.Is(err, huffman.ErrCodeTable):
+		return nil, newAPIError(http.StatusBadRequest, "invalid_code_table", err.Error())
+	case errors.Is(err, huffman.ErrBits):
+		return nil, newAPIError(http.StatusUnprocessableEntity, "invalid_bits", err.Error())
+	case err != nil:
+		return nil, newAPIError(http.StatusInternalServerError, "internal", "Error decoding bits")
+	}
+	return &textDecoding{
+		Text:      string(text),
+		Symbols:   len(text),
+		TotalBits: strings.Count(bits, "0") + strings.Count(bits, "1"),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEncodeTextHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	encodeTextHandler(rr, newUploadRequest(t, "/encode-text", "", nil, map[string]string{"text": "hola mundo"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var encoding huffman.TextEncoding
+	if err := json.Unmarshal(rr.Body.Bytes(), &encoding); err != nil {
+		t.Fatal(err)
+	}
+	if encoding.ASCIIBits != 80 || encoding.TotalBits >= encoding.ASCIIBits || len(strings.Fields(encoding.Grouped)) != 10 {
+		t.Errorf("Unexpected encoding %+v", encoding)
+	}
+
+	// The table of the answer decodes its own bits
+	table, _ := json.Marshal(encoding.Table)
+	rr = httptest.NewRecorder()
+	decodeTextHandler(rr, newUploadRequest(t, "/decode-text", "", nil, map[string]string{"bits": encoding.Grouped, "codes": string(table)}))
+	var decoding textDecoding
+	if err := json.Unmarshal(rr.Body.Bytes(), &decoding); err != nil || decoding.Text != "hola mundo" || decoding.TotalBits != encoding.TotalBits {
+		t.Errorf("Unexpected decoding %+v: %v", decoding, err)
+	}
+
+	// Both endpoints also take a urlencoded form
+	rr = httptest.NewRecorder()
+	encodeTextHandler(rr, newFormRequest("/encode-text", url.Values{"text": {"hola mundo"}}))
+	var urlencoded huffman.TextEncoding
+	if err := json.Unmarshal(rr.Body.Bytes(), &urlencoded); rr.Code != http.StatusOK || err != nil || urlencoded.Bits != encoding.Bits {
+		t.Errorf("Unexpected urlencoded encoding %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	decodeTextHandler(rr, newFormRequest("/decode-text", url.Values{"bits": {encoding.Bits}, "codes": {string(table)}}))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"text":"hola mundo"`) {
+		t.Errorf("Unexpected urlencoded decoding %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	encodeTextHandler(rr, newUploadRequest(t, "/encode-text", "", nil, nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without text, got %d", rr.Code)
+	}
+}
+
+func TestDecodeTextHandler(t *testing.T) {
+	codes := "symbol,code\n97,0\n98,10\n"
+	tests := []struct {
+		name, bits, codes string
+		code              int
+		body              string
+	}{
+		{"valid", "0 10 0", codes, http.StatusOK, `"text":"aba"`},
+		{"invalid prefix", "011", codes, http.StatusUnprocessableEntity, "no code starts with 11"},
+		{"incomplete code", "01", codes, http.StatusUnprocessableEntity, "not a complete code"},
+		{"not a bit", "0a", codes, http.StatusUnprocessableEntity, "only 0 and 1"},
+		{"no table", "0", "", http.StatusBadRequest, "code table is required"},
+		{"prefix table", "0", "symbol,code\n97,0\n98,01\n", http.StatusBadRequest, "prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			decodeTextHandler(rr, newUploadRequest(t, "/decode-text", "", nil, map[string]string{"bits": tt.bits, "codes": tt.codes}))
+			if rr.Code != tt.code || !strings.Contains(rr.Body.String(), tt.body) {
+				t.Errorf("Expected %d with %q, got %d: %s", tt.code, tt.body, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"io"
+	"net/http"
+)
+
+func treeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	trace, apiErr := traceUpload(w, r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, trace)
+}
+
+// traceUpload records how the Huffman tree of the upload is built. The input
+// is the "text" field or, if it is empty, the uploaded "file".
+func traceUpload(w http.ResponseWriter, r *http.Request) (*huffman.TreeTrace, *apiError) {
+	if apiErr := readUploadForm(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	data, apiErr := textOrFile(r, "text")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return huffman.TraceTree(data, huffman.Options{MinVariance: r.FormValue("minVariance") == "true"}), nil
+}
+
+// textOrFile returns the form field textField or, if it is empty, the
+// contents of the uploaded "file". The form must already be parsed.
+func textOrFile(r *http.Request, textField string) ([]byte, *apiError) {
+	if text := r.FormValue(textField); text != "" {
+		return []byte(text), nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "A file or text is required")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "file_required", "Error reading file")
+	}
+	return data, nil
+}
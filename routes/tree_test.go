@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"Compression_Upc/huffman"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	treeHandler(rr, newUploadRequest(t, "/tree", "ignored.txt", []byte("ignored"), map[string]string{"text": "abracadabra"}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var trace huffman.TreeTrace
+	if err := json.Unmarshal(rr.Body.Bytes(), &trace); err != nil {
+		t.Fatalf("Failed to decode trace: %v", err)
+	}
+	if trace.Symbols != 11 || len(trace.Steps) != 4 {
+		t.Errorf("Expected the trace of the text field, got %+v", trace)
+	}
+
+	// Without text the file is used
+	rr = httptest.NewRecorder()
+	treeHandler(rr, newUploadRequest(t, "/tree", "data.txt", []byte("aab"), nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &trace); err != nil || trace.Symbols != 3 || len(trace.Leaves) != 2 {
+		t.Errorf("Expected the trace of the file, got %+v: %v", trace, err)
+	}
+
+	// "aaaabbccde" has a tie between a merged node and a leaf
+	rr = httptest.NewRecorder()
+	treeHandler(rr, newUploadRequest(t, "/tree", "", nil, map[string]string{"text": "aaaabbccde", "minVariance": "true"}))
+	if err := json.Unmarshal(rr.Body.Bytes(), &trace); err != nil || !trace.MinVariance || trace.CodeLengthVariance > 0.2 {
+		t.Errorf("Expected a minimum-variance trace, got %+v: %v", trace, err)
+	}
+
+	rr = httptest.NewRecorder()
+	treeHandler(rr, httptest.NewRequest(http.MethodGet, "/tree", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"Compression_Upc/storage"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Every operation works in its own directory under process/, named by a
+// random 128-bit ID:
+//
+//	process/<id>/input/<name>   uploaded file
+//	process/<id>/output/<name>  result being written
+//
+// A finished result moves to the result store under the same ID, which is the
+// only way to reach it, so users cannot overwrite or guess each other's files.
+// The workspace is removed once the operation ends.
+const processDir = "process"
+
+type workspace struct {
+	id  string
+	dir string
+}
+
+// newWorkspace creates an empty workspace with a fresh ID.
+func newWorkspace() (*workspace, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ws := &workspace{id: hex.EncodeToString(b)}
+	ws.dir = filepath.Join(processDir, ws.id)
+	for _, sub := range []string{"input", "output"} {
+		if err := os.MkdirAll(filepath.Join(ws.dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return ws, nil
+}
+
+// isWorkspaceID reports whether id looks like an ID created by newWorkspace.
+func isWorkspaceID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func (ws *workspace) inputPath(name string) string {
+	return filepath.Join(ws.dir, "input", filepath.Base(name))
+}
+
+func (ws *workspace) outputPath(name string) string {
+	return filepath.Join(ws.dir, "output", filepath.Base(name))
+}
+
+// save stores an uploaded file as the workspace input.
+func (ws *workspace) save(src io.Reader, name string) error {
+	outFile, err := os.Create(ws.inputPath(name))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	if _, err := io.Copy(outFile, src); err != nil {
+		return err
+	}
+	return outFile.Close()
+}
+
+// keep moves the output to the result store, where it stays until it is
+// downloaded or config.ResultTTL elapses.
+func (ws *workspace) keep(ctx context.Context, name string) (storage.Metadata, error) {
+	f, err := os.Open(ws.outputPath(name))
+	if err != nil {
+		return storage.Metadata{}, err
+	}
+	defer f.Close()
+	meta := storage.Metadata{Name: name, ExpiresAt: time.Now().Add(config.resultTTL())}
+	return meta, resultStore.Put(ctx, ws.id, f, meta)
+}
+
+func (ws *workspace) remove() error {
+	return os.RemoveAll(ws.dir)
+}
+
+// removeStaleWorkspaces deletes workspaces older than maxAge, left behind by
+// a crash.
+func removeStaleWorkspaces(maxAge time.Duration) {
+	entries, err := os.ReadDir(processDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if isWorkspaceID(entry.Name()) {
+			os.RemoveAll(filepath.Join(processDir, entry.Name()))
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"Compression_Upc/huffman"
+	"Compression_Upc/routes"
+	"Compression_Upc/storage"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// shutdownTimeout bounds how long the server waits for open requests when
+// it is interrupted.
+const shutdownTimeout = 10 * time.Second
+
+// runServe starts the web server. It is also what runs when no command is
+// given, so the old "main -p 8080" invocations keep working.
+func runServe(ctx context.Context, args []string, s streams) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	port := fs.String("p", "8080", "port to serve on")
+	help := fs.Bool("h", false, "display help")
+	repair := fs.String("repair", "", "repair a .huff file using its parity and exit (same as the repair command)")
+	genKey := fs.String("genkey", "", "generate an Ed25519 keypair and exit (same as the keygen command)")
+	signKey := fs.String("signkey", "", "private key file used to sign compressed files")
+	workers := fs.Int("workers", 0, "number of background jobs run at once (default: number of CPUs)")
+	storageKind := fs.String("storage", "disk", "where results are kept until downloaded: disk, memory or s3")
+	storageDir := fs.String("storage-dir", "results", "directory for the disk storage")
+	s3Endpoint := fs.String("s3-endpoint", "", "S3-compatible endpoint URL for the s3 storage")
+	s3Bucket := fs.String("s3-bucket", "", "bucket for the s3 storage")
+	s3Region := fs.String("s3-region", "us-east-1", "region for the s3 storage")
+	resultTTL := fs.Duration("ttl", time.Hour, "how long undownloaded results are kept")
+	janitorInterval := fs.Duration("janitor", 5*time.Minute, "how often expired results are purged")
+	maxUploadMB := fs.Int64("max-upload-mb", 100, "largest upload accepted, in MiB")
+	maxOutputMB := fs.Int64("max-output-mb", 1024, "largest decompressed output allowed, in MiB")
+	maxRatio := fs.Float64("max-ratio", 1000, "largest expansion ratio allowed when decompressing")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(s.stderr, "%s serve: %v\n", progName, err)
+		printServeHelp(s.stderr)
+		return exitUsage
+	}
+
+	// Show help if requested
+	if *help {
+		printServeHelp(s.stdout)
+		return exitOK
+	}
+
+	// Older versions repaired and generated keys through flags
+	if *repair != "" {
+		return runRepair(ctx, []string{*repair}, s)
+	}
+	if *genKey != "" {
+		return runKeygen(ctx, []string{*genKey}, s)
+	}
+
+	cfg := routes.Config{
+		Workers:             *workers,
+		ResultTTL:           *resultTTL,
+		JanitorInterval:     *janitorInterval,
+		MaxUploadSize:       *maxUploadMB << 20,
+		MaxDecompressedSize: *maxOutputMB << 20,
+		MaxRatio:            *maxRatio,
+	}
+
+	// Load the signing key, if any
+	if *signKey != "" {
+		key, err := loadSigningKey(*signKey)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "Error al leer la clave %s: %v\n", *signKey, err)
+			return exitError
+		}
+		cfg.SigningKey = key
+	}
+
+	// Choose where results are kept
+	switch *storageKind {
+	case "disk":
+		store, err := storage.NewDisk(*storageDir)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "Error al abrir el almacenamiento %s: %v\n", *storageDir, err)
+			return exitError
+		}
+		cfg.Store = store
+	case "memory":
+		cfg.Store = storage.NewMemory()
+	case "s3":
+		// Credentials come from the environment to keep them out of ps
+		store, err := storage.NewS3(storage.S3Config{
+			Endpoint:  *s3Endpoint,
+			Region:    *s3Region,
+			Bucket:    *s3Bucket,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		})
+		if err != nil {
+			fmt.Fprintf(s.stderr, "Error al configurar el almacenamiento S3: %v\n", err)
+			return exitError
+		}
+		cfg.Store = store
+	default:
+		fmt.Fprintf(s.stderr, "Almacenamiento desconocido: %s\n", *storageKind)
+		return exitUsage
+	}
+	routes.Configure(cfg)
+
+	// Format port string
+	cobraPort := ":" + *port
+
+	// Create and configure server
+	server := http.Server{
+		Addr:    cobraPort,
+		Handler: routes.MuxRoutes(),
+	}
+
+	// Stop accepting requests on Ctrl+C and let the open ones finish
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(s.stdout, "Servidor iniciando en http://localhost%s\n", cobraPort)
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(s.stderr, "Error al iniciar el servidor: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintln(s.stdout, "Servidor detenido")
+	return exitOK
+}
+
+func printServeHelp(w io.Writer) {
+	fmt.Fprintf(w, "Uso: %s serve [opciones]\n", progName)
+	fmt.Fprintln(w, "Opciones:")
+	fmt.Fprintln(w, "  -p, --port <puerto>   Especifica el puerto en el que se ejecutará el servidor (por defecto es 8080)")
+	fmt.Fprintln(w, "  -h, --help            Muestra esta ayuda")
+	fmt.Fprintln(w, "  -signkey <archivo>    Firma los archivos comprimidos con la clave privada indicada")
+	fmt.Fprintln(w, "  -workers <n>          Número de trabajos en segundo plano simultáneos (por defecto, uno por CPU)")
+	fmt.Fprintln(w, "  -storage <tipo>       Dónde se guardan los resultados hasta descargarlos: disk, memory o s3 (por defecto disk)")
+	fmt.Fprintln(w, "  -storage-dir <dir>    Directorio del almacenamiento en disco (por defecto results)")
+	fmt.Fprintln(w, "  -s3-endpoint <url>    URL del servicio compatible con S3 (las credenciales se leen de")
+	fmt.Fprintln(w, "                        AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY)")
+	fmt.Fprintln(w, "  -s3-bucket <nombre>   Bucket donde se guardan los resultados")
+	fmt.Fprintln(w, "  -s3-region <región>   Región del bucket (por defecto us-east-1)")
+	fmt.Fprintln(w, "  -ttl <duración>       Tiempo que se guardan los resultados no descargados (por defecto 1h)")
+	fmt.Fprintln(w, "  -janitor <duración>   Cada cuánto se borran los resultados vencidos (por defecto 5m)")
+	fmt.Fprintln(w, "  -max-upload-mb <n>    Tamaño máximo de un archivo subido, en MiB (por defecto 100)")
+	fmt.Fprintln(w, "  -max-output-mb <n>    Tamaño máximo de un archivo descomprimido, en MiB (por defecto 1024)")
+	fmt.Fprintln(w, "  -max-ratio <n>        Razón de expansión máxima al descomprimir (por defecto 1000)")
+	fmt.Fprintln(w, "  -repair <archivo>     Igual que el comando repair")
+	fmt.Fprintln(w, "  -genkey <prefijo>     Igual que el comando keygen")
+}
+
+// loadSigningKey reads a PEM Ed25519 private key written by keygen.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return huffman.ParsePrivateKey(data)
+}
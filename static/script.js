@@ -1,60 +1,449 @@
-let processedFileName = '';
-
-async function handleCompression(event) {
-    event.preventDefault();
-    const formData = new FormData(event.target);
-    const fileInput = event.target.querySelector('input[type="file"]');
-    if (!fileInput.files[0]) {
-        alert('Por favor seleccione un archivo');
-        return;
-    }
-    formData.append('fileName', fileInput.files[0].name);
-    
-    try {
-        const response = await fetch('/compress', {
-            method: 'POST',
-            body: formData
-        });
-        
-        if (!response.ok) throw new Error('Compression failed');
-        processedFileName = await response.text();
-        document.getElementById('downloadBtn').style.display = 'block';
-    } catch (error) {
-        alert('Error: ' + error.message);
-    }
-}
-
-async function handleDecompression(event) {
-    event.preventDefault();
-    const formData = new FormData(event.target);
-    const fileInput = event.target.querySelector('input[type="file"]');
-    if (!fileInput.files[0]) {
-        alert('Por favor seleccione un archivo');
-        return;
-    }
-    formData.append('fileName', fileInput.files[0].name);
-    
-    try {
-        const response = await fetch('/decompress', {
-            method: 'POST',
-            body: formData
-        });
-        
-        if (!response.ok) throw new Error('Decompression failed');
-        processedFileName = await response.text();
-        document.getElementById('downloadBtn').style.display = 'block';
-    } catch (error) {
-        alert('Error: ' + error.message);
-    }
-}
-
-function downloadFile() {
-    if (processedFileName) {
-        window.location.href = `/download?file=${encodeURIComponent(processedFileName)}`;
-    }
-}
-
-// Asignar los event listeners
-document.getElementById('compressForm')?.addEventListener('submit', handleCompression);
-document.getElementById('decompressForm')?.addEventListener('submit', handleDecompression);
-document.getElementById('downloadBtn')?.addEventListener('click', downloadFile);
+let resultId = '';
+
+const phaseNames = {
+    'counting': 'Contando frecuencias',
+    'building tree': 'Construyendo árbol',
+    'encoding': 'Codificando',
+    'decoding': 'Decodificando',
+    'done': 'Terminando'
+};
+
+// Envía el formulario como trabajo en segundo plano y sigue su progreso por SSE
+async function submitJob(event, operation) {
+    event.preventDefault();
+    const formData = new FormData(event.target);
+    const fileInput = event.target.querySelector('input[type="file"]');
+    if (!fileInput.files[0]) {
+        alert('Por favor seleccione un archivo');
+        return;
+    }
+    formData.append('fileName', fileInput.files[0].name);
+    formData.append('operation', operation);
+    document.getElementById('downloadBtn').style.display = 'none';
+
+    try {
+        const response = await fetch('/jobs', {
+            method: 'POST',
+            body: formData
+        });
+        if (!response.ok) throw new Error(await response.text());
+        const job = await response.json();
+        // El resultado del trabajo es el identificador de la descarga
+        resultId = await followJob(job.id, operation);
+        document.getElementById('downloadBtn').style.display = 'block';
+    } catch (error) {
+        alert('Error: ' + error.message);
+    } finally {
+        document.getElementById('progress').classList.add('hidden');
+    }
+}
+
+// followJob muestra la barra de progreso y la tasa de compresión en vivo hasta que termina el trabajo
+function followJob(id, operation) {
+    const panel = document.getElementById('progress');
+    const bar = document.getElementById('progressBar');
+    const text = document.getElementById('progressText');
+    bar.value = 0;
+    text.textContent = 'En cola...';
+    panel.classList.remove('hidden');
+
+    return new Promise((resolve, reject) => {
+        const source = new EventSource(`/jobs/${id}/events`);
+        source.addEventListener('status', (event) => {
+            const job = JSON.parse(event.data);
+            bar.value = Math.round(job.progress * 100);
+            if (job.state === 'running') {
+                let line = `${phaseNames[job.phase] || 'Procesando'}: ${bar.value}%`;
+                // La tasa es siempre comprimido / original
+                const [original, compressed] = operation === 'compress'
+                    ? [job.bytesRead, job.bytesWritten]
+                    : [job.bytesWritten, job.bytesRead];
+                if (original > 0) {
+                    line += ` · tasa ${(compressed / original * 100).toFixed(1)}%`;
+                }
+                text.textContent = line;
+            }
+            if (job.state === 'done') {
+                source.close();
+                resolve(job.result);
+            } else if (job.state === 'failed' || job.state === 'canceled') {
+                source.close();
+                reject(new Error(job.error || job.state));
+            }
+        });
+        source.onerror = () => {
+            source.close();
+            reject(new Error('Se perdió la conexión con el servidor'));
+        };
+    });
+}
+
+const handleCompression = (event) => submitJob(event, 'compress');
+const handleDecompression = (event) => submitJob(event, 'decompress');
+
+function downloadFile() {
+    if (resultId) {
+        window.location.href = `/download?id=${encodeURIComponent(resultId)}`;
+        // El servidor borra el resultado después de la primera descarga
+        resultId = '';
+        document.getElementById('downloadBtn').style.display = 'none';
+    }
+}
+
+// Comprime el archivo con todos los métodos y muestra los resultados
+async function handleComparison(event) {
+    event.preventDefault();
+    const fileInput = event.target.querySelector('input[type="file"]');
+    if (!fileInput.files[0]) {
+        alert('Por favor seleccione un archivo');
+        return;
+    }
+    const button = event.target.querySelector('button');
+    button.disabled = true;
+    button.textContent = 'Comparando...';
+    try {
+        const response = await fetch('/compare', {
+            method: 'POST',
+            body: new FormData(event.target)
+        });
+        if (!response.ok) throw new Error(await response.text());
+        showComparison(await response.json());
+    } catch (error) {
+        alert('Error: ' + error.message);
+    } finally {
+        button.disabled = false;
+        button.textContent = 'Comparar';
+    }
+}
+
+// showComparison dibuja una barra de bits por símbolo por método, con la
+// entropía como línea de referencia, y la tabla con todos los valores
+function showComparison(report) {
+    document.getElementById('comparisonSummary').textContent =
+        `${report.fileName}: ${report.originalSize} bytes, entropía ${report.entropy.toFixed(3)} bits/símbolo (línea naranja) ` +
+        `(mínimo ${report.entropyBound} bytes). Mejor método: ${report.best}`;
+
+    const scale = Math.max(8, ...report.results.map(r => r.bitsPerSymbol));
+    const chart = document.getElementById('comparisonChart');
+    chart.replaceChildren();
+    for (const result of report.results) {
+        const row = document.createElement('div');
+        row.className = 'chart-row';
+        const label = document.createElement('span');
+        label.className = 'chart-label';
+        label.textContent = result.codec;
+        const track = document.createElement('div');
+        track.className = 'chart-track';
+        const bar = document.createElement('div');
+        bar.className = result.codec === report.best ? 'chart-bar best' : 'chart-bar';
+        bar.style.width = `${result.bitsPerSymbol / scale * 100}%`;
+        const entropy = document.createElement('div');
+        entropy.className = 'chart-entropy';
+        entropy.style.left = `${report.entropy / scale * 100}%`;
+        entropy.title = 'Entropía';
+        const value = document.createElement('span');
+        value.className = 'chart-value';
+        value.textContent = result.bitsPerSymbol.toFixed(2);
+        track.append(bar, entropy);
+        row.append(label, track, value);
+        chart.append(row);
+    }
+
+    const body = document.querySelector('#comparisonTable tbody');
+    body.replaceChildren();
+    for (const result of report.results) {
+        const row = body.insertRow();
+        for (const cell of [
+            result.codec,
+            `${result.compressedSize} bytes`,
+            `${(result.ratio * 100).toFixed(1)}%`,
+            result.bitsPerSymbol.toFixed(3),
+            `${result.compressMs.toFixed(1)} ms`,
+            `${result.decompressMs.toFixed(1)} ms`
+        ]) {
+            row.insertCell().textContent = cell;
+        }
+    }
+    document.getElementById('comparison').classList.remove('hidden');
+}
+
+// Traza de la construcción del árbol que se está mostrando
+let treeTrace = null;
+let treeStep = 0;
+let treeTimer = null;
+
+const svgNS = 'http://www.w3.org/2000/svg';
+const treeDelay = 1200;
+
+// Pide al servidor cada paso de la construcción del árbol y lo reproduce
+async function handleTree(event) {
+    event.preventDefault();
+    const formData = new FormData(event.target);
+    if (!formData.get('text') && !event.target.querySelector('input[type="file"]').files[0]) {
+        alert('Escriba un texto o seleccione un archivo');
+        return;
+    }
+    try {
+        const response = await fetch('/tree', {
+            method: 'POST',
+            body: formData
+        });
+        if (!response.ok) throw new Error(await response.text());
+        treeTrace = await response.json();
+    } catch (error) {
+        alert('Error: ' + error.message);
+        return;
+    }
+    const slider = document.getElementById('treeSlider');
+    slider.max = treeTrace.steps.length;
+    document.getElementById('treePanel').classList.remove('hidden');
+    showTreeStep(0);
+    playTree();
+}
+
+function symbolLabel(symbol) {
+    const names = { 32: '␣', 10: '⏎', 9: '⇥', 13: '␍' };
+    if (names[symbol]) return names[symbol];
+    if (symbol > 32 && symbol < 127) return String.fromCharCode(symbol);
+    return '0x' + symbol.toString(16).padStart(2, '0');
+}
+
+function nodeLabel(node) {
+    return node.leaf ? `'${symbolLabel(node.symbol)}' (${node.frequency})` : `nodo ${node.id} (${node.frequency})`;
+}
+
+// showTreeStep dibuja la cola y el bosque que hay después de `step` uniones
+function showTreeStep(step) {
+    treeStep = step;
+    const steps = treeTrace.steps;
+    document.getElementById('treeSlider').value = step;
+
+    // Todos los nodos creados hasta este paso
+    const nodes = new Map(treeTrace.leaves.map(n => [n.id, n]));
+    for (const s of steps.slice(0, step)) nodes.set(s.merged.id, s.merged);
+    const queue = step === 0 ? treeTrace.leaves : steps[step - 1].queue;
+    const current = step > 0 ? steps[step - 1] : null;
+
+    const caption = document.getElementById('treeCaption');
+    if (treeTrace.leaves.length === 0) {
+        caption.textContent = 'La entrada está vacía.';
+    } else if (!current) {
+        caption.textContent = `Cola inicial: ${treeTrace.leaves.length} hojas ordenadas por frecuencia.`;
+    } else {
+        caption.textContent = `Paso ${step} de ${steps.length}: se sacan ${nodeLabel(current.left)} y ` +
+            `${nodeLabel(current.right)}, y entra un nodo de frecuencia ${current.merged.frequency}.`;
+    }
+    if (step === steps.length && treeTrace.leaves.length > 0) {
+        caption.textContent += ` Árbol completo: ${treeTrace.bitsPerSymbol.toFixed(3)} bits por símbolo, ` +
+            `varianza ${treeTrace.codeLengthVariance.toFixed(3)}, entropía ${treeTrace.entropy.toFixed(3)}.`;
+    }
+
+    const queueDiv = document.getElementById('treeQueue');
+    queueDiv.replaceChildren(...queue.map(n => {
+        const chip = document.createElement('span');
+        chip.className = current && n.id === current.merged.id ? 'chip new' : 'chip';
+        chip.textContent = n.leaf ? `${symbolLabel(n.symbol)}:${n.frequency}` : `#${n.id}:${n.frequency}`;
+        return chip;
+    }));
+
+    drawForest(queue.map(n => n.id), nodes, current);
+    showTreeCodes(step === steps.length);
+}
+
+// drawForest dibuja cada árbol de la cola uno al lado del otro. Las hojas
+// ocupan columnas consecutivas y cada nodo interno queda sobre sus hijos.
+function drawForest(roots, nodes, current) {
+    const svg = document.getElementById('treeCanvas');
+    svg.replaceChildren();
+    const pos = new Map();
+    let column = 0;
+    let depth = 0;
+    const place = (id, level) => {
+        const node = nodes.get(id);
+        depth = Math.max(depth, level);
+        if (node.leaf) {
+            pos.set(id, { x: column++, y: level });
+        } else {
+            place(node.left, level + 1);
+            place(node.right, level + 1);
+            pos.set(id, { x: (pos.get(node.left).x + pos.get(node.right).x) / 2, y: level });
+        }
+    };
+    for (const root of roots) {
+        place(root, 0);
+        column += 0.5;
+    }
+
+    const dx = 44, dy = 56, margin = 28;
+    const at = (id) => ({ x: margin + pos.get(id).x * dx, y: margin + pos.get(id).y * dy });
+    svg.setAttribute('viewBox', `0 0 ${2 * margin + Math.max(column - 1.5, 0) * dx} ${2 * margin + depth * dy + 20}`);
+
+    const highlighted = current ? [current.left.id, current.right.id, current.merged.id] : [];
+    for (const [id] of pos) {
+        const node = nodes.get(id);
+        if (node.leaf) continue;
+        [[node.left, '0'], [node.right, '1']].forEach(([child, bit]) => {
+            const from = at(id), to = at(child);
+            const line = document.createElementNS(svgNS, 'line');
+            line.setAttribute('x1', from.x);
+            line.setAttribute('y1', from.y);
+            line.setAttribute('x2', to.x);
+            line.setAttribute('y2', to.y);
+            line.setAttribute('class', id === highlighted[2] ? 'edge new' : 'edge');
+            const label = document.createElementNS(svgNS, 'text');
+            label.setAttribute('x', (from.x + to.x) / 2 + (bit === '0' ? -8 : 8));
+            label.setAttribute('y', (from.y + to.y) / 2);
+            label.setAttribute('class', 'bit');
+            label.textContent = bit;
+            svg.append(line, label);
+        });
+    }
+    for (const [id] of pos) {
+        const node = nodes.get(id), p = at(id);
+        const group = document.createElementNS(svgNS, 'g');
+        let cls = node.leaf ? 'node leaf' : 'node';
+        if (id === highlighted[2]) cls += ' new';
+        else if (highlighted.includes(id)) cls += ' picked';
+        group.setAttribute('class', cls);
+        const circle = document.createElementNS(svgNS, 'circle');
+        circle.setAttribute('cx', p.x);
+        circle.setAttribute('cy', p.y);
+        circle.setAttribute('r', 16);
+        const freq = document.createElementNS(svgNS, 'text');
+        freq.setAttribute('x', p.x);
+        freq.setAttribute('y', p.y + 4);
+        freq.textContent = node.frequency;
+        group.append(circle, freq);
+        if (node.leaf) {
+            const symbol = document.createElementNS(svgNS, 'text');
+            symbol.setAttribute('x', p.x);
+            symbol.setAttribute('y', p.y + 32);
+            symbol.setAttribute('class', 'symbol');
+            symbol.textContent = symbolLabel(node.symbol);
+            group.append(symbol);
+        }
+        svg.append(group);
+    }
+}
+
+function showTreeCodes(visible) {
+    const table = document.getElementById('treeCodes');
+    table.classList.toggle('hidden', !visible || treeTrace.codes.length === 0);
+    const body = table.querySelector('tbody');
+    body.replaceChildren();
+    for (const entry of treeTrace.codes) {
+        const row = body.insertRow();
+        for (const cell of [symbolLabel(entry.symbol), entry.frequency, entry.code]) {
+            row.insertCell().textContent = cell;
+        }
+    }
+}
+
+function playTree() {
+    pauseTree();
+    if (treeStep >= treeTrace.steps.length) showTreeStep(0);
+    document.getElementById('treePlay').innerHTML = '&#10074;&#10074;';
+    treeTimer = setInterval(() => {
+        if (treeStep >= treeTrace.steps.length) {
+            pauseTree();
+            return;
+        }
+        showTreeStep(treeStep + 1);
+    }, treeDelay);
+}
+
+function pauseTree() {
+    clearInterval(treeTimer);
+    treeTimer = null;
+    document.getElementById('treePlay').innerHTML = '&#9654;';
+}
+
+// Codifica el mensaje escrito y muestra el código de cada símbolo
+async function handleEncodeText(event) {
+    event.preventDefault();
+    const formData = new FormData(event.target);
+    if (!formData.get('text')) {
+        alert('Escriba un mensaje');
+        return;
+    }
+    let encoding;
+    try {
+        const response = await fetch('/encode-text', {
+            method: 'POST',
+            body: formData
+        });
+        if (!response.ok) throw new Error(await response.text());
+        encoding = await response.json();
+    } catch (error) {
+        alert('Error: ' + error.message);
+        return;
+    }
+
+    document.getElementById('textSummary').textContent =
+        `${encoding.totalBits} bits con Huffman frente a ${encoding.asciiBits} bits en ASCII de 8 bits ` +
+        `(${(encoding.ratio * 100).toFixed(1)}%), ${encoding.table.bitsPerSymbol.toFixed(3)} bits por símbolo.`;
+
+    const symbols = new TextEncoder().encode(formData.get('text'));
+    const groups = encoding.grouped.split(' ');
+    document.getElementById('textBits').replaceChildren(...groups.map((code, i) => {
+        const chip = document.createElement('span');
+        chip.className = 'chip';
+        chip.textContent = `${symbolLabel(symbols[i])} ${code}`;
+        return chip;
+    }));
+
+    const body = document.querySelector('#textCodes tbody');
+    body.replaceChildren();
+    for (const entry of encoding.table.codes) {
+        const row = body.insertRow();
+        for (const cell of [symbolLabel(entry.symbol), entry.frequency, entry.code]) {
+            row.insertCell().textContent = cell;
+        }
+    }
+    document.getElementById('textEncoding').classList.remove('hidden');
+
+    // Deja listos los bits y la tabla para probar la decodificación
+    const decodeForm = document.getElementById('decodeTextForm');
+    decodeForm.elements.bits.value = encoding.grouped;
+    decodeForm.elements.codes.value = ['symbol,code', ...encoding.table.codes.map(c => `${c.symbol},${c.code}`)].join('\n');
+    document.getElementById('decodedText').textContent = '';
+}
+
+// Decodifica los bits con la tabla escrita y muestra el texto o el error
+async function handleDecodeText(event) {
+    event.preventDefault();
+    const output = document.getElementById('decodedText');
+    try {
+        const response = await fetch('/decode-text', {
+            method: 'POST',
+            body: new FormData(event.target)
+        });
+        if (!response.ok) throw new Error(await response.text());
+        const decoding = await response.json();
+        output.textContent = `Texto: "${decoding.text}" (${decoding.symbols} símbolos, ${decoding.totalBits} bits)`;
+    } catch (error) {
+        output.textContent = 'Error: ' + error.message;
+    }
+}
+
+// Asignar los event listeners
+document.getElementById('compressForm')?.addEventListener('submit', handleCompression);
+document.getElementById('decompressForm')?.addEventListener('submit', handleDecompression);
+document.getElementById('compareForm')?.addEventListener('submit', handleComparison);
+document.getElementById('treeForm')?.addEventListener('submit', handleTree);
+document.getElementById('encodeTextForm')?.addEventListener('submit', handleEncodeText);
+document.getElementById('decodeTextForm')?.addEventListener('submit', handleDecodeText);
+document.getElementById('treePlay')?.addEventListener('click', () => treeTimer ? pauseTree() : playTree());
+document.getElementById('treePrev')?.addEventListener('click', () => {
+    pauseTree();
+    if (treeStep > 0) showTreeStep(treeStep - 1);
+});
+document.getElementById('treeNext')?.addEventListener('click', () => {
+    pauseTree();
+    if (treeStep < treeTrace.steps.length) showTreeStep(treeStep + 1);
+});
+document.getElementById('treeSlider')?.addEventListener('input', (event) => {
+    pauseTree();
+    showTreeStep(Number(event.target.value));
+});
+document.getElementById('downloadBtn')?.addEventListener('click', downloadFile);
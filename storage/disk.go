@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Disk is a Store that keeps each object as a file in a directory, next to
+// a <key>.meta.json file with its metadata.
+type Disk struct {
+	dir string
+}
+
+const metaSuffix = ".meta.json"
+
+// NewDisk returns a store rooted at dir, creating it if needed.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) dataPath(key string) string { return filepath.Join(d.dir, key) }
+func (d *Disk) metaPath(key string) string { return filepath.Join(d.dir, key+metaSuffix) }
+
+// Put writes to temporary files first so readers never see a partial object.
+func (d *Disk) Put(ctx context.Context, key string, r io.Reader, meta Metadata) (err error) {
+	if !ValidKey(key) || strings.HasSuffix(key, metaSuffix) {
+		return ErrInvalidKey
+	}
+	tmp, err := os.CreateTemp(d.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	meta.Size, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now()
+	}
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// The metadata goes last: an object without it is not listed nor served
+	os.Remove(d.metaPath(key))
+	if err := os.Rename(tmp.Name(), d.dataPath(key)); err != nil {
+		return err
+	}
+	return writeFileAtomic(d.metaPath(key), encoded)
+}
+
+func (d *Disk) Get(ctx context.Context, key string) (io.ReadCloser, Metadata, error) {
+	if !ValidKey(key) {
+		return nil, Metadata{}, ErrNotFound
+	}
+	meta, err := d.readMeta(key)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	if meta.Expired(time.Now()) {
+		return nil, Metadata{}, ErrNotFound
+	}
+	f, err := os.Open(d.dataPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Metadata{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return f, meta, nil
+}
+
+func (d *Disk) Stat(ctx context.Context, key string) (Metadata, error) {
+	if !ValidKey(key) {
+		return Metadata{}, ErrNotFound
+	}
+	meta, err := d.readMeta(key)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if meta.Expired(time.Now()) {
+		return Metadata{}, ErrNotFound
+	}
+	if _, err := os.Stat(d.dataPath(key)); errors.Is(err, fs.ErrNotExist) {
+		return Metadata{}, ErrNotFound
+	} else if err != nil {
+		return Metadata{}, err
+	}
+	return meta, nil
+}
+
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+	metaErr := os.Remove(d.metaPath(key))
+	dataErr := os.Remove(d.dataPath(key))
+	if errors.Is(metaErr, fs.ErrNotExist) && errors.Is(dataErr, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	for _, err := range []error{metaErr, dataErr} {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Disk) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, entry := range entries {
+		key, ok := strings.CutSuffix(entry.Name(), metaSuffix)
+		if !ok || !ValidKey(key) {
+			continue
+		}
+		meta, err := d.readMeta(key)
+		if err != nil {
+			continue // removed meanwhile or unreadable
+		}
+		objects = append(objects, Object{Key: key, Metadata: meta})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (d *Disk) readMeta(key string) (Metadata, error) {
+	var meta Metadata
+	data, err := os.ReadFile(d.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, ErrNotFound
+	}
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".meta-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that keeps objects in memory. It suits tests and
+// single-instance deployments with small results.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	meta Metadata
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, meta Metadata) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now()
+	}
+	meta.Size = int64(len(data))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, meta: meta}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok || obj.meta.Expired(time.Now()) {
+		return nil, Metadata{}, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.meta, nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok || obj.meta.Expired(time.Now()) {
+		return Metadata{}, ErrNotFound
+	}
+	return obj.meta, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context) ([]Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects := make([]Object, 0, len(m.objects))
+	for key, obj := range m.objects {
+		objects = append(objects, Object{Key: key, Metadata: obj.meta})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
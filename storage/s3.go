@@ -0,0 +1,532 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3 store. Any S3-compatible service (AWS, MinIO,
+// Ceph, R2...) works; requests use path-style URLs (<endpoint>/<bucket>/<key>).
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Region    string // default us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PartSize is the size of each part of a multipart upload. Objects smaller
+	// than PartSize are sent with a single PUT. Default 8 MiB; S3 requires at
+	// least 5 MiB for every part but the last.
+	PartSize int
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// DefaultPartSize is the multipart part size used when S3Config.PartSize is 0.
+const DefaultPartSize = 8 << 20
+
+// S3 is a Store backed by an S3 bucket. Metadata travels as x-amz-meta-*
+// headers. S3 also implements Presigner, so results can be downloaded
+// straight from the bucket.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// Presigner is implemented by stores that can hand out temporary URLs to
+// download an object without going through the server.
+type Presigner interface {
+	// PresignGet returns a URL valid for ttl that downloads key as fileName.
+	PresignGet(key, fileName string, ttl time.Duration) (string, error)
+}
+
+// NewS3 returns a store for the bucket described by cfg.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("storage: s3 endpoint and bucket are required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("storage: s3 credentials are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3{cfg: cfg, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, meta Metadata) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = s.now()
+	}
+	headers := metaHeaders(meta)
+
+	// Small objects go in a single request, without allocating a whole part
+	part, err := readPart(r, s.cfg.PartSize)
+	if err != nil {
+		return err
+	}
+	if len(part) < s.cfg.PartSize {
+		resp, err := s.do(ctx, http.MethodPut, key, nil, headers, part)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return s.putMultipart(ctx, key, r, headers, part)
+}
+
+// readPart reads up to size bytes of r. The buffer grows with the data, so a
+// small object costs about its own size.
+func readPart(r io.Reader, size int) ([]byte, error) {
+	buf := make([]byte, 0, min(size, 32<<10))
+	for len(buf) < size {
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, min(len(buf), size-len(buf)))
+		}
+		n, err := r.Read(buf[len(buf):min(cap(buf), size)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// putMultipart uploads first, a full part, and the rest of r as a multipart
+// upload, aborting it if anything fails so no orphan parts are billed.
+func (s *S3) putMultipart(ctx context.Context, key string, r io.Reader, headers http.Header, first []byte) (err error) {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, headers, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("storage: s3 initiate multipart upload of %s: bad response", key)
+	}
+	uploadID := initiated.UploadID
+	defer func() {
+		if err != nil {
+			// Use a fresh context: ctx may be the reason for the failure
+			if resp, abortErr := s.do(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil); abortErr == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	var parts []completedPart
+	part := first
+	for number := 1; ; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, nil, part)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		part = first[:s.cfg.PartSize]
+		n, err := io.ReadFull(r, part)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		part = part[:n]
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err = s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 may answer 200 and still report an error in the body
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := parseS3Error(result); err != nil {
+		return fmt.Errorf("storage: s3 complete multipart upload of %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Metadata, error) {
+	if !ValidKey(key) {
+		return nil, Metadata{}, ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	meta := parseMetaHeaders(resp)
+	if meta.Expired(s.now()) {
+		resp.Body.Close()
+		return nil, Metadata{}, ErrNotFound
+	}
+	return resp.Body, meta, nil
+}
+
+// Stat sends a HEAD request, so the object body is not transferred.
+func (s *S3) Stat(ctx context.Context, key string) (Metadata, error) {
+	if !ValidKey(key) {
+		return Metadata{}, ErrNotFound
+	}
+	meta, err := s.head(ctx, key)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if meta.Expired(s.now()) {
+		return Metadata{}, ErrNotFound
+	}
+	return meta, nil
+}
+
+// Delete checks that the object exists first, since S3 reports success for
+// missing keys.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+	if _, err := s.head(ctx, key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List pages through the bucket and reads the metadata of each object, which
+// ListObjectsV2 does not return.
+func (s *S3) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	query := url.Values{"list-type": {"2"}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage: s3 list: %w", err)
+		}
+		for _, c := range page.Contents {
+			if !ValidKey(c.Key) {
+				continue
+			}
+			meta, err := s.head(ctx, c.Key)
+			if errors.Is(err, ErrNotFound) {
+				continue // deleted meanwhile
+			}
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, Object{Key: c.Key, Metadata: meta})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// PresignGet signs a GET URL with query-string authentication. The response
+// carries a Content-Disposition header with fileName.
+func (s *S3) PresignGet(key, fileName string, ttl time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	seconds := int(ttl / time.Second)
+	if seconds < 1 || seconds > 7*24*3600 {
+		return "", fmt.Errorf("storage: presigned url lifetime %v out of range", ttl)
+	}
+	query := url.Values{}
+	if fileName != "" {
+		query.Set("response-content-disposition", "attachment; filename="+strconv.Quote(fileName))
+	}
+	return s.presign(http.MethodGet, s.objectPath(key), query, seconds, s.now()), nil
+}
+
+func (s *S3) head(ctx context.Context, key string) (Metadata, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	resp.Body.Close()
+	return parseMetaHeaders(resp), nil
+}
+
+// do sends a signed request for key (or the bucket if key is empty) and
+// turns error responses into errors. The caller closes the body.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = s.objectPath(key)
+	u.RawPath = ""
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	payload := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(payload[:]), s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" && method != http.MethodPost && query.Get("uploadId") == "" {
+		return nil, ErrNotFound
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := parseS3Error(data); err != nil {
+		return nil, fmt.Errorf("storage: s3 %s %s: %s: %w", method, key, resp.Status, err)
+	}
+	return nil, fmt.Errorf("storage: s3 %s %s: %s", method, key, resp.Status)
+}
+
+func (s *S3) objectPath(key string) string {
+	path := s.endpoint.Path + "/" + s.cfg.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	return path
+}
+
+// s3Error is the XML error document returned by S3.
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string { return e.Code + ": " + e.Message }
+
+func parseS3Error(data []byte) error {
+	var e s3Error
+	if xml.Unmarshal(data, &e) != nil || e.Code == "" {
+		return nil
+	}
+	return &e
+}
+
+func metaHeaders(meta Metadata) http.Header {
+	h := http.Header{}
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("X-Amz-Meta-Name", url.QueryEscape(meta.Name))
+	h.Set("X-Amz-Meta-Created-At", meta.CreatedAt.UTC().Format(time.RFC3339Nano))
+	if !meta.ExpiresAt.IsZero() {
+		h.Set("X-Amz-Meta-Expires-At", meta.ExpiresAt.UTC().Format(time.RFC3339Nano))
+	}
+	return h
+}
+
+func parseMetaHeaders(resp *http.Response) Metadata {
+	var meta Metadata
+	meta.Name, _ = url.QueryUnescape(resp.Header.Get("X-Amz-Meta-Name"))
+	meta.Size = resp.ContentLength
+	meta.CreatedAt, _ = time.Parse(time.RFC3339Nano, resp.Header.Get("X-Amz-Meta-Created-At"))
+	meta.ExpiresAt, _ = time.Parse(time.RFC3339Nano, resp.Header.Get("X-Amz-Meta-Expires-At"))
+	return meta
+}
+
+// AWS Signature Version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers.
+// It signs the host, every x-amz-* header and Content-Type, Content-MD5 and
+// Range if present.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign returns a URL for path authenticated through its query string.
+func (s *S3) presign(method, path string, query url.Values, expires int, now time.Time) string {
+	scope := s.scope(now)
+	q := url.Values{}
+	for name, values := range query {
+		q[name] = values
+	}
+	q.Set("X-Amz-Algorithm", sigAlgorithm)
+	q.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.UTC().Format(amzDateFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(expires))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	path = uriEncode(path, false)
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery(q),
+		"host:" + s.endpoint.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	q.Set("X-Amz-Signature", s.signature(now, scope, canonicalRequest))
+	return s.endpoint.Scheme + "://" + s.endpoint.Host + path + "?" + canonicalQuery(q)
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3) signature(now time.Time, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigAlgorithm + "\n" + now.UTC().Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name as SigV4 requires. The same
+// string is sent, so the server sees exactly what was signed.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and
+// '/' unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+// Package storage keeps the results of compress and decompress operations
+// until they are downloaded or expire.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for keys that do not exist or have expired.
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is returned for keys that are empty, start with '.' or
+	// contain characters other than letters, digits, '-', '_' and '.'.
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Metadata describes a stored object.
+type Metadata struct {
+	Name      string    `json:"name"` // file name shown to the user
+	Size      int64     `json:"size"` // set by Put
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"` // zero means never
+}
+
+// Expired reports whether the object is expired at now.
+func (m Metadata) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// Object is an entry returned by List.
+type Object struct {
+	Key string `json:"key"`
+	Metadata
+}
+
+// Store is a flat key/value store of files with metadata. Expired objects
+// behave as missing even before the janitor deletes them.
+type Store interface {
+	// Put stores the contents of r under key, replacing any previous object.
+	// CreatedAt defaults to the current time and Size is computed.
+	Put(ctx context.Context, key string, r io.Reader, meta Metadata) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, Metadata, error)
+	// Stat returns the metadata of the object stored under key without
+	// reading its contents.
+	Stat(ctx context.Context, key string) (Metadata, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// List returns every object, including expired ones not yet purged.
+	List(ctx context.Context) ([]Object, error)
+}
+
+// ValidKey reports whether key can be used with every Store.
+func ValidKey(key string) bool {
+	if key == "" || key[0] == '.' || len(key) > 255 {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Purge deletes the objects of s that are expired at now and returns how
+// many were removed.
+func Purge(ctx context.Context, s Store, now time.Time) (int, error) {
+	objects, err := s.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, obj := range objects {
+		if !obj.Expired(now) {
+			continue
+		}
+		if err := s.Delete(ctx, obj.Key); err != nil && !errors.Is(err, ErrNotFound) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunJanitor purges expired objects every interval until ctx is canceled.
+func RunJanitor(ctx context.Context, s Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := Purge(ctx, s, now)
+			if err != nil {
+				log.Printf("storage: purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("storage: purged %d expired objects", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStore checks the behavior every Store must share.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Put(ctx, "result-1", strings.NewReader("compressed bytes"), Metadata{Name: "report.txt.huff"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	rc, meta, err := s.Get(ctx, "result-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "compressed bytes" || meta.Name != "report.txt.huff" || meta.Size != 16 || meta.CreatedAt.IsZero() {
+		t.Errorf("Unexpected object %q %+v", data, meta)
+	}
+
+	if meta, err := s.Stat(ctx, "result-1"); err != nil || meta.Name != "report.txt.huff" || meta.Size != 16 {
+		t.Errorf("Unexpected Stat %+v, %v", meta, err)
+	}
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Stat of a missing object, got %v", err)
+	}
+
+	// Put replaces the previous object
+	s.Put(ctx, "result-1", strings.NewReader("v2"), Metadata{Name: "v2.huff"})
+	rc, meta, _ = s.Get(ctx, "result-1")
+	data, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "v2" || meta.Name != "v2.huff" {
+		t.Errorf("Put did not replace the object: %q %+v", data, meta)
+	}
+
+	// Expired objects behave as missing but are listed until purged
+	past := time.Now().Add(-time.Minute)
+	s.Put(ctx, "expired", strings.NewReader("old"), Metadata{ExpiresAt: past})
+	if _, _, err := s.Get(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an expired object, got %v", err)
+	}
+	if _, err := s.Stat(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Stat of an expired object, got %v", err)
+	}
+	objects, err := s.List(ctx)
+	if err != nil || len(objects) != 2 || objects[0].Key != "expired" || objects[1].Key != "result-1" {
+		t.Fatalf("Unexpected listing %+v, %v", objects, err)
+	}
+	if n, err := Purge(ctx, s, time.Now()); n != 1 || err != nil {
+		t.Errorf("Expected one purged object, got %d, %v", n, err)
+	}
+	if objects, _ := s.List(ctx); len(objects) != 1 {
+		t.Errorf("Expected one object after purge, got %+v", objects)
+	}
+
+	if err := s.Delete(ctx, "result-1"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := s.Delete(ctx, "result-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if _, _, err := s.Get(ctx, "result-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+
+	for _, key := range []string{"", "../escape", "a/b", ".hidden"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), Metadata{}); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Key %q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestDisk(t *testing.T) {
+	s, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDisk failed: %v", err)
+	}
+	testStore(t, s)
+}
+
+func TestRunJanitor(t *testing.T) {
+	s := NewMemory()
+	s.Put(context.Background(), "soon", strings.NewReader("x"), Metadata{ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunJanitor(ctx, s, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if objects, _ := s.List(context.Background()); len(objects) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Janitor did not purge the expired object")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"Compression_Upc/huffman"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// runTable writes the frequency or code table of a file, or the codes of a
+// table read with -from.
+func runTable(ctx context.Context, args []string, s streams) int {
+	fs := newFlagSet("table", "[archivo]", s)
+	codes := fs.Bool("codes", false, "escribe la tabla de códigos en lugar de la de frecuencias")
+	from := fs.String("from", "", "construye los códigos de la tabla de frecuencias o probabilidades de este `archivo` (csv o json)")
+	format := fs.String("format", "csv", "formato de la tabla: "+strings.Join(huffman.TableFormats, ", "))
+	minVariance := fs.Bool("min-variance", false, "construye el árbol de varianza mínima")
+	output := fs.String("o", "", "escribe la tabla en `archivo` en lugar de la salida estándar")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !slices.Contains(huffman.TableFormats, *format) {
+		return usageError(s, "table", "formato desconocido %q (use %s)", *format, strings.Join(huffman.TableFormats, ", "))
+	}
+	if fs.NArg() > 1 || (*from != "" && fs.NArg() > 0) {
+		return usageError(s, "table", "recibe un solo archivo, o ninguno con -from")
+	}
+	opts := huffman.Options{MinVariance: *minVariance}
+
+	var table interface {
+		Write(w io.Writer, format string) error
+	}
+	if *from != "" {
+		frequencies, err := readTableFile(*from, s.stdin)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %s\n", progName, displayName(*from), describeError(err))
+			return exitError
+		}
+		table = frequencies.Codes(opts)
+	} else {
+		input := "-"
+		if fs.NArg() == 1 {
+			input = fs.Arg(0)
+		}
+		data, err := readInput(input, s.stdin)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %s: %s\n", progName, displayName(input), describeError(err))
+			return exitError
+		}
+		frequencies := huffman.Frequencies(data)
+		table = frequencies
+		if *codes {
+			table = frequencies.Codes(opts)
+		}
+	}
+
+	out := s.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(s.stderr, "%s: %v\n", progName, err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := table.Write(out, *format); err != nil {
+		fmt.Fprintf(s.stderr, "%s: %v\n", progName, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// readTableFile reads a frequency table from path, or from stdin if path is
+// "-", detecting csv or json by its content.
+func readTableFile(path string, stdin io.Reader) (*huffman.FrequencyTable, error) {
+	if path == "-" {
+		return huffman.ReadFrequencyTable(stdin, "")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return huffman.ReadFrequencyTable(f, "")
+}
+
+func readInput(input string, stdin io.Reader) ([]byte, error) {
+	if input == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(input)
+}